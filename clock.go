@@ -0,0 +1,16 @@
+package metrics
+
+import "time"
+
+// Clocks provide the current time to metrics whose behavior depends on the
+// passage of time, allowing tests to control time deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+// WallClock is a Clock which reads the system's wall clock via time.Now.
+var WallClock Clock = wallClock{}
+
+type wallClock struct{}
+
+func (wallClock) Now() time.Time { return time.Now() }
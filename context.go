@@ -0,0 +1,24 @@
+//go:build go1.7
+// +build go1.7
+
+package metrics
+
+import "context"
+
+// registryKey is the context key for a Registry, unexported so that only
+// NewContext can set it.
+type registryKey struct{}
+
+// NewContext returns a copy of ctx carrying r, i.e. so that HTTP middleware
+// may pass a request-scoped PrefixedRegistry down to deep handlers, which
+// retrieve it with FromContext.
+func NewContext(ctx context.Context, r Registry) context.Context {
+	return context.WithValue(ctx, registryKey{}, r)
+}
+
+// FromContext returns the Registry carried by ctx and true, or nil and false
+// if it carries none.
+func FromContext(ctx context.Context) (Registry, bool) {
+	r, ok := ctx.Value(registryKey{}).(Registry)
+	return r, ok
+}
@@ -0,0 +1,27 @@
+//go:build go1.7
+// +build go1.7
+
+package metrics
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	r := NewPrefixedChildRegistry(NewRegistry(), "request.")
+	ctx := NewContext(context.Background(), r)
+	if fromCtx, ok := FromContext(ctx); !ok || r != fromCtx {
+		t.Fatal(fromCtx, ok)
+	}
+	if fromCtx, ok := FromContext(context.Background()); ok || nil != fromCtx {
+		t.Fatal(fromCtx, ok)
+	}
+}
+
+func TestFromContextAllocs(t *testing.T) {
+	ctx := NewContext(context.Background(), NewRegistry())
+	if n := testing.AllocsPerRun(100, func() { FromContext(ctx) }); 0 != n {
+		t.Errorf("FromContext allocations: 0 != %v\n", n)
+	}
+}
@@ -3,14 +3,27 @@ package metrics
 import "sync/atomic"
 
 // Counters hold an int64 value that can be incremented and decremented.
+// Clear sets the count to zero and returns the count it replaced, so that
+// a reporting loop may read and reset a counter without losing increments
+// made in between.
 type Counter interface {
-	Clear()
+	Clear() int64
 	Count() int64
 	Dec(int64)
 	Inc(int64)
 	Snapshot() Counter
 }
 
+// GetCounter returns the Counter registered under the given name, or nil if no
+// metric is registered under the name or the registered metric is not a Counter.
+func GetCounter(name string, r Registry) Counter {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	m, _ := r.Get(name).(Counter)
+	return m
+}
+
 // GetOrRegisterCounter returns an existing Counter or constructs and registers
 // a new StandardCounter.
 func GetOrRegisterCounter(name string, r Registry) Counter {
@@ -28,6 +41,25 @@ func NewCounter() Counter {
 	return &StandardCounter{0}
 }
 
+// NewFunctionalCounter constructs a new FunctionalCounter.
+func NewFunctionalCounter(f func() int64) Counter {
+	if UseNilMetrics {
+		return NilCounter{}
+	}
+	return &FunctionalCounter{count: f}
+}
+
+// NewRegisteredFunctionalCounter constructs and registers a new
+// FunctionalCounter.
+func NewRegisteredFunctionalCounter(name string, r Registry, f func() int64) Counter {
+	c := NewFunctionalCounter(f)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
 // NewRegisteredCounter constructs and registers a new StandardCounter.
 func NewRegisteredCounter(name string, r Registry) Counter {
 	c := NewCounter()
@@ -42,7 +74,7 @@ func NewRegisteredCounter(name string, r Registry) Counter {
 type CounterSnapshot int64
 
 // Clear panics.
-func (CounterSnapshot) Clear() {
+func (CounterSnapshot) Clear() int64 {
 	panic("Clear called on a CounterSnapshot")
 }
 
@@ -66,7 +98,7 @@ func (c CounterSnapshot) Snapshot() Counter { return c }
 type NilCounter struct{}
 
 // Clear is a no-op.
-func (NilCounter) Clear() {}
+func (NilCounter) Clear() int64 { return 0 }
 
 // Count is a no-op.
 func (NilCounter) Count() int64 { return 0 }
@@ -81,14 +113,17 @@ func (NilCounter) Inc(i int64) {}
 func (NilCounter) Snapshot() Counter { return NilCounter{} }
 
 // StandardCounter is the standard implementation of a Counter and uses the
-// sync/atomic package to manage a single int64 value.
+// sync/atomic package to manage a single int64 value.  Like int64 addition,
+// it wraps around on overflow, so a count incremented past math.MaxInt64
+// becomes negative.  See NewSaturatingCounter.
 type StandardCounter struct {
 	count int64
 }
 
-// Clear sets the counter to zero.
-func (c *StandardCounter) Clear() {
-	atomic.StoreInt64(&c.count, 0)
+// Clear sets the counter to zero and returns its previous count in a single
+// atomic operation.
+func (c *StandardCounter) Clear() int64 {
+	return atomic.SwapInt64(&c.count, 0)
 }
 
 // Count returns the current count.
@@ -110,3 +145,32 @@ func (c *StandardCounter) Inc(i int64) {
 func (c *StandardCounter) Snapshot() Counter {
 	return CounterSnapshot(c.Count())
 }
+
+// FunctionalCounter returns its count from the given function, which is
+// called on every read.
+type FunctionalCounter struct {
+	count func() int64
+}
+
+// Clear panics.
+func (FunctionalCounter) Clear() int64 {
+	panic("Clear called on a FunctionalCounter")
+}
+
+// Count returns the counter's current count.
+func (c FunctionalCounter) Count() int64 {
+	return c.count()
+}
+
+// Dec panics.
+func (FunctionalCounter) Dec(int64) {
+	panic("Dec called on a FunctionalCounter")
+}
+
+// Inc panics.
+func (FunctionalCounter) Inc(int64) {
+	panic("Inc called on a FunctionalCounter")
+}
+
+// Snapshot returns the snapshot.
+func (c FunctionalCounter) Snapshot() Counter { return CounterSnapshot(c.Count()) }
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// DecimalCounters hold a float64 value that can be added to, for counts which
+// are naturally fractional.  Negative amounts may be added.  Clear sets the
+// count to zero and returns the count it replaced, as Counter's does.
+type DecimalCounter interface {
+	Add(float64)
+	Clear() float64
+	Count() float64
+	Snapshot() DecimalCounter
+}
+
+// GetOrRegisterDecimalCounter returns an existing DecimalCounter or constructs
+// and registers a new StandardDecimalCounter.
+func GetOrRegisterDecimalCounter(name string, r Registry) DecimalCounter {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return r.GetOrRegister(name, NewDecimalCounter).(DecimalCounter)
+}
+
+// NewDecimalCounter constructs a new StandardDecimalCounter.
+func NewDecimalCounter() DecimalCounter {
+	if UseNilMetrics {
+		return NilDecimalCounter{}
+	}
+	return &StandardDecimalCounter{}
+}
+
+// NewRegisteredDecimalCounter constructs and registers a new
+// StandardDecimalCounter.
+func NewRegisteredDecimalCounter(name string, r Registry) DecimalCounter {
+	c := NewDecimalCounter()
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// DecimalCounterSnapshot is a read-only copy of another DecimalCounter.
+type DecimalCounterSnapshot float64
+
+// Add panics.
+func (DecimalCounterSnapshot) Add(float64) {
+	panic("Add called on a DecimalCounterSnapshot")
+}
+
+// Clear panics.
+func (DecimalCounterSnapshot) Clear() float64 {
+	panic("Clear called on a DecimalCounterSnapshot")
+}
+
+// Count returns the count at the time the snapshot was taken.
+func (c DecimalCounterSnapshot) Count() float64 { return float64(c) }
+
+// Snapshot returns the snapshot.
+func (c DecimalCounterSnapshot) Snapshot() DecimalCounter { return c }
+
+// NilDecimalCounter is a no-op DecimalCounter.
+type NilDecimalCounter struct{}
+
+// Add is a no-op.
+func (NilDecimalCounter) Add(float64) {}
+
+// Clear is a no-op.
+func (NilDecimalCounter) Clear() float64 { return 0.0 }
+
+// Count is a no-op.
+func (NilDecimalCounter) Count() float64 { return 0.0 }
+
+// Snapshot is a no-op.
+func (NilDecimalCounter) Snapshot() DecimalCounter { return NilDecimalCounter{} }
+
+// StandardDecimalCounter is the standard implementation of a DecimalCounter
+// and uses the sync/atomic package to manage the bits of a single float64
+// value.
+type StandardDecimalCounter struct {
+	count uint64
+}
+
+// Add adds the given amount to the counter.
+func (c *StandardDecimalCounter) Add(f float64) {
+	for {
+		old := atomic.LoadUint64(&c.count)
+		if atomic.CompareAndSwapUint64(&c.count, old, math.Float64bits(math.Float64frombits(old)+f)) {
+			return
+		}
+	}
+}
+
+// Clear sets the counter to zero and returns its previous count in a single
+// atomic operation.
+func (c *StandardDecimalCounter) Clear() float64 {
+	return math.Float64frombits(atomic.SwapUint64(&c.count, 0))
+}
+
+// Count returns the current count.
+func (c *StandardDecimalCounter) Count() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&c.count))
+}
+
+// Snapshot returns a read-only copy of the counter.
+func (c *StandardDecimalCounter) Snapshot() DecimalCounter {
+	return DecimalCounterSnapshot(c.Count())
+}
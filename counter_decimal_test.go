@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+)
+
+func BenchmarkDecimalCounter(b *testing.B) {
+	c := NewDecimalCounter()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Add(0.5)
+	}
+}
+
+func TestDecimalCounterAdd(t *testing.T) {
+	c := NewDecimalCounter()
+	c.Add(1.5)
+	c.Add(0.25)
+	if count := c.Count(); 1.75 != count {
+		t.Errorf("c.Count(): 1.75 != %v\n", count)
+	}
+	c.Add(-2.0)
+	if count := c.Count(); -0.25 != count {
+		t.Errorf("c.Count(): -0.25 != %v\n", count)
+	}
+}
+
+func TestDecimalCounterClear(t *testing.T) {
+	c := NewDecimalCounter()
+	c.Add(1.5)
+	if count := c.Clear(); 1.5 != count {
+		t.Errorf("c.Clear(): 1.5 != %v\n", count)
+	}
+	if count := c.Count(); 0.0 != count {
+		t.Errorf("c.Count(): 0.0 != %v\n", count)
+	}
+}
+
+func TestNilDecimalCounterClear(t *testing.T) {
+	if count := (NilDecimalCounter{}).Clear(); 0.0 != count {
+		t.Errorf("NilDecimalCounter{}.Clear(): 0.0 != %v\n", count)
+	}
+}
+
+func TestDecimalCounterConcurrency(t *testing.T) {
+	c := NewDecimalCounter()
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Add(0.5)
+			}
+		}()
+	}
+	wg.Wait()
+	if count := c.Count(); 5000.0 != count {
+		t.Errorf("c.Count(): 5000.0 != %v\n", count)
+	}
+}
+
+func TestDecimalCounterSnapshot(t *testing.T) {
+	c := NewDecimalCounter()
+	c.Add(1.5)
+	snapshot := c.Snapshot()
+	c.Add(1.0)
+	if count := snapshot.Count(); 1.5 != count {
+		t.Errorf("snapshot.Count(): 1.5 != %v\n", count)
+	}
+}
+
+func TestGetOrRegisterDecimalCounter(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredDecimalCounter("foo", r).Add(4.7)
+	if c := GetOrRegisterDecimalCounter("foo", r); 4.7 != c.Count() {
+		t.Fatal(c)
+	}
+	if v := r.GetAll()["foo"]["count"]; 4.7 != v {
+		t.Fatal(v)
+	}
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// SaturatingCounters are Counters which stop at math.MaxInt64 and
+// math.MinInt64 rather than wrapping around, and report whether they have.
+type SaturatingCounter interface {
+	Counter
+	Saturated() bool
+}
+
+// NewSaturatingCounter constructs a new StandardSaturatingCounter.
+func NewSaturatingCounter() SaturatingCounter {
+	if UseNilMetrics {
+		return NilSaturatingCounter{}
+	}
+	return &StandardSaturatingCounter{}
+}
+
+// NewRegisteredSaturatingCounter constructs and registers a new
+// StandardSaturatingCounter.
+func NewRegisteredSaturatingCounter(name string, r Registry) SaturatingCounter {
+	c := NewSaturatingCounter()
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// NilSaturatingCounter is a no-op SaturatingCounter.
+type NilSaturatingCounter struct{ NilCounter }
+
+// Saturated is a no-op.
+func (NilSaturatingCounter) Saturated() bool { return false }
+
+// StandardSaturatingCounter is the standard implementation of a
+// SaturatingCounter.  Each update is a compare-and-swap loop, so it is
+// somewhat costlier than StandardCounter's single atomic addition.
+type StandardSaturatingCounter struct {
+	count     int64
+	saturated uint32
+}
+
+// Clear sets the counter to zero, forgets that it saturated and returns its
+// previous count.
+func (c *StandardSaturatingCounter) Clear() int64 {
+	count := atomic.SwapInt64(&c.count, 0)
+	atomic.StoreUint32(&c.saturated, 0)
+	return count
+}
+
+// Count returns the current count.
+func (c *StandardSaturatingCounter) Count() int64 {
+	return atomic.LoadInt64(&c.count)
+}
+
+// Dec decrements the counter by the given amount, stopping at
+// math.MinInt64.
+func (c *StandardSaturatingCounter) Dec(i int64) {
+	if math.MinInt64 == i {
+		c.add(math.MaxInt64)
+		c.add(1)
+		return
+	}
+	c.add(-i)
+}
+
+// Inc increments the counter by the given amount, stopping at
+// math.MaxInt64.
+func (c *StandardSaturatingCounter) Inc(i int64) {
+	c.add(i)
+}
+
+// Saturated returns whether any update has stopped at math.MaxInt64 or
+// math.MinInt64 since the counter was constructed or last cleared.  The
+// count may since have moved away from the limit.
+func (c *StandardSaturatingCounter) Saturated() bool {
+	return 1 == atomic.LoadUint32(&c.saturated)
+}
+
+// Snapshot returns a read-only copy of the counter.
+func (c *StandardSaturatingCounter) Snapshot() Counter {
+	return CounterSnapshot(c.Count())
+}
+
+func (c *StandardSaturatingCounter) add(i int64) {
+	for {
+		old := atomic.LoadInt64(&c.count)
+		count, saturated := old+i, false
+		if i > 0 && old > math.MaxInt64-i {
+			count, saturated = math.MaxInt64, true
+		} else if i < 0 && old < math.MinInt64-i {
+			count, saturated = math.MinInt64, true
+		}
+		if atomic.CompareAndSwapInt64(&c.count, old, count) {
+			if saturated {
+				atomic.StoreUint32(&c.saturated, 1)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestSaturatingCounterInc(t *testing.T) {
+	c := NewSaturatingCounter()
+	c.Inc(math.MaxInt64 - 1)
+	if c.Saturated() {
+		t.Fatal("saturated below math.MaxInt64")
+	}
+	c.Inc(1)
+	if count := c.Count(); math.MaxInt64 != count {
+		t.Errorf("c.Count(): math.MaxInt64 != %v\n", count)
+	}
+	if c.Saturated() {
+		t.Fatal("saturated at exactly math.MaxInt64")
+	}
+	c.Inc(47)
+	if count := c.Count(); math.MaxInt64 != count {
+		t.Errorf("c.Count(): math.MaxInt64 != %v\n", count)
+	}
+	if !c.Saturated() {
+		t.Fatal("not saturated")
+	}
+	if count := c.Clear(); math.MaxInt64 != count {
+		t.Errorf("c.Clear(): math.MaxInt64 != %v\n", count)
+	}
+	if c.Saturated() {
+		t.Fatal("saturated after Clear")
+	}
+}
+
+func TestSaturatingCounterDec(t *testing.T) {
+	c := NewSaturatingCounter()
+	c.Dec(math.MaxInt64)
+	c.Dec(2)
+	if count := c.Count(); math.MinInt64 != count {
+		t.Errorf("c.Count(): math.MinInt64 != %v\n", count)
+	}
+	if !c.Saturated() {
+		t.Fatal("not saturated")
+	}
+	c.Clear()
+	c.Dec(math.MinInt64)
+	if count := c.Count(); math.MaxInt64 != count {
+		t.Errorf("c.Count(): math.MaxInt64 != %v\n", count)
+	}
+}
+
+func TestSaturatingCounterConcurrent(t *testing.T) {
+	c := NewSaturatingCounter()
+	c.Inc(math.MaxInt64 - 1000)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Inc(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if count := c.Count(); math.MaxInt64 != count {
+		t.Errorf("c.Count(): math.MaxInt64 != %v\n", count)
+	}
+	if !c.Saturated() {
+		t.Fatal("not saturated")
+	}
+}
+
+func TestStandardCounterWraps(t *testing.T) {
+	c := NewCounter()
+	c.Inc(math.MaxInt64)
+	c.Inc(1)
+	if count := c.Count(); math.MinInt64 != count {
+		t.Errorf("c.Count(): math.MinInt64 != %v\n", count)
+	}
+}
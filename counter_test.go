@@ -1,6 +1,9 @@
 package metrics
 
-import "testing"
+import (
+	"sync"
+	"testing"
+)
 
 func BenchmarkCounter(b *testing.B) {
 	c := NewCounter()
@@ -75,3 +78,87 @@ func TestGetOrRegisterCounter(t *testing.T) {
 		t.Fatal(c)
 	}
 }
+
+func TestFunctionalCounter(t *testing.T) {
+	var calls int64
+	c := NewFunctionalCounter(func() int64 {
+		calls++
+		return calls * 10
+	})
+	if count := c.Count(); 10 != count {
+		t.Errorf("c.Count(): 10 != %v\n", count)
+	}
+	if count := c.Count(); 20 != count {
+		t.Errorf("c.Count(): 20 != %v\n", count)
+	}
+	if 2 != calls {
+		t.Errorf("calls: 2 != %v\n", calls)
+	}
+}
+
+func TestFunctionalCounterSnapshot(t *testing.T) {
+	var n int64 = 47
+	c := NewFunctionalCounter(func() int64 { return n })
+	snapshot := c.Snapshot()
+	n = 0
+	if count := snapshot.Count(); 47 != count {
+		t.Errorf("snapshot.Count(): 47 != %v\n", count)
+	}
+}
+
+func TestGetOrRegisterFunctionalCounter(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredFunctionalCounter("foo", r, func() int64 { return 47 })
+	if c := GetOrRegisterCounter("foo", r); 47 != c.Count() {
+		t.Fatal(c)
+	}
+	i := 0
+	r.Each(func(name string, iface interface{}) {
+		i++
+		if _, ok := iface.(Counter); !ok {
+			t.Fatal(iface)
+		}
+	})
+	if 1 != i {
+		t.Fatal(i)
+	}
+}
+
+func TestCounterClearReturnsCount(t *testing.T) {
+	c := NewCounter()
+	c.Inc(47)
+	if count := c.Clear(); 47 != count {
+		t.Errorf("c.Clear(): 47 != %v\n", count)
+	}
+	if count := c.Count(); 0 != count {
+		t.Errorf("c.Count(): 0 != %v\n", count)
+	}
+}
+
+func TestCounterClearConcurrent(t *testing.T) {
+	c := NewCounter()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10000; j++ {
+				c.Inc(1)
+			}
+		}()
+	}
+	done := make(chan struct{})
+	var total int64
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			total += c.Clear()
+		}
+	}()
+	wg.Wait()
+	<-done
+	total += c.Clear()
+	if 40000 != total {
+		t.Errorf("total: 40000 != %v\n", total)
+	}
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// windowCounterBuckets is the number of buckets a WindowCounter divides its
+// window into.  Increments expire from the count a bucket at a time, so the
+// window is accurate to within window/windowCounterBuckets.
+const windowCounterBuckets = 60
+
+// NewWindowCounter constructs a new WindowCounter which counts the events of
+// the trailing window.
+func NewWindowCounter(window time.Duration) Counter {
+	if UseNilMetrics {
+		return NilCounter{}
+	}
+	width := window / windowCounterBuckets
+	if width <= 0 {
+		width = 1
+	}
+	return &WindowCounter{
+		clock: WallClock,
+		width: int64(width),
+	}
+}
+
+// NewRegisteredWindowCounter constructs and registers a new WindowCounter.
+func NewRegisteredWindowCounter(name string, r Registry, window time.Duration) Counter {
+	c := NewWindowCounter(window)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// WindowCounter is a Counter whose count only includes increments made within
+// a trailing time window.  Increments are accumulated into a fixed ring of
+// time buckets which are recycled lazily, so neither Inc nor Count allocate.
+type WindowCounter struct {
+	buckets [windowCounterBuckets]windowCounterBucket
+	clock   Clock
+	mutex   sync.Mutex
+	width   int64
+}
+
+type windowCounterBucket struct {
+	epoch int64
+	count int64
+}
+
+// Clear sets the counter to zero and returns the sum of the increments made
+// within the window.
+func (c *WindowCounter) Clear() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	count := c.count()
+	for i := range c.buckets {
+		c.buckets[i] = windowCounterBucket{}
+	}
+	return count
+}
+
+// Count returns the sum of the increments made within the window.
+func (c *WindowCounter) Count() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.count()
+}
+
+// count must be called with the mutex held.
+func (c *WindowCounter) count() int64 {
+	epoch := c.epoch()
+	var count int64
+	for _, b := range c.buckets {
+		if epoch-b.epoch < windowCounterBuckets {
+			count += b.count
+		}
+	}
+	return count
+}
+
+// Dec decrements the counter by the given amount.
+func (c *WindowCounter) Dec(i int64) {
+	c.Inc(-i)
+}
+
+// Inc increments the counter by the given amount.
+func (c *WindowCounter) Inc(i int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	epoch := c.epoch()
+	b := &c.buckets[epoch%windowCounterBuckets]
+	if b.epoch != epoch {
+		b.epoch = epoch
+		b.count = 0
+	}
+	b.count += i
+}
+
+// Snapshot returns a read-only copy of the counter.
+func (c *WindowCounter) Snapshot() Counter {
+	return CounterSnapshot(c.Count())
+}
+
+// epoch returns the index of the bucket width containing the current time.
+func (c *WindowCounter) epoch() int64 {
+	return c.clock.Now().UnixNano() / c.width
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func BenchmarkWindowCounter(b *testing.B) {
+	c := NewWindowCounter(time.Minute)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Inc(1)
+	}
+}
+
+func TestWindowCounter(t *testing.T) {
+	clock := &manualClock{time.Unix(1000, 0)}
+	c := NewWindowCounter(time.Minute).(*WindowCounter)
+	c.clock = clock
+	c.Inc(3)
+	clock.Add(30 * time.Second)
+	c.Inc(4)
+	c.Dec(1)
+	if count := c.Count(); 6 != count {
+		t.Errorf("c.Count(): 6 != %v\n", count)
+	}
+	clock.Add(31 * time.Second)
+	if count := c.Count(); 3 != count {
+		t.Errorf("c.Count(): 3 != %v\n", count)
+	}
+	clock.Add(30 * time.Second)
+	if count := c.Count(); 0 != count {
+		t.Errorf("c.Count(): 0 != %v\n", count)
+	}
+}
+
+func TestWindowCounterReusesBuckets(t *testing.T) {
+	clock := &manualClock{time.Unix(1000, 0)}
+	c := NewWindowCounter(time.Minute).(*WindowCounter)
+	c.clock = clock
+	c.Inc(5)
+	clock.Add(time.Minute)
+	c.Inc(1)
+	if count := c.Count(); 1 != count {
+		t.Errorf("c.Count(): 1 != %v\n", count)
+	}
+}
+
+func TestWindowCounterClear(t *testing.T) {
+	c := NewWindowCounter(time.Minute)
+	c.Inc(1)
+	c.Clear()
+	if count := c.Count(); 0 != count {
+		t.Errorf("c.Count(): 0 != %v\n", count)
+	}
+}
+
+func TestWindowCounterRegistry(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredWindowCounter("foo", r, time.Minute).Inc(47)
+	if c := GetOrRegisterCounter("foo", r); 47 != c.Count() {
+		t.Fatal(c)
+	}
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// csvColumns are the columns written by WriteCSV.  Columns which do not
+// apply to a metric's type are left empty.
+var csvColumns = []string{
+	"type", "name", "count", "value", "error",
+	"min", "max", "mean", "stddev", "median", "75%", "95%", "99%", "99.9%",
+	"1m.rate", "5m.rate", "15m.rate", "mean.rate", "max.rate", "min.rate",
+}
+
+// WriteCSV sorts and writes the metrics in the given registry to the given
+// io.Writer as CSV, with a header row followed by one row per metric.  Every
+// row has the same columns, the first of which is the metric's type, so
+// metrics of every type share one file; columns which do not apply to a
+// metric's type are left empty.
+func WriteCSV(r Registry, w io.Writer) {
+	namedMetrics := sortedMetrics(r)
+
+	cw := csv.NewWriter(w)
+	cw.Write(csvColumns)
+	for _, namedMetric := range namedMetrics {
+		row := make(map[string]string)
+		switch metric := namedMetric.m.(type) {
+		case Counter:
+			row["type"] = "counter"
+			row["count"] = strconv.FormatInt(metric.Count(), 10)
+		case Gauge:
+			row["type"] = "gauge"
+			row["value"] = strconv.FormatInt(metric.Value(), 10)
+		case GaugeFloat64:
+			row["type"] = "gauge"
+			row["value"] = csvFloat(metric.Value())
+		case Healthcheck:
+			metric.Check()
+			row["type"] = "healthcheck"
+			if err := metric.Error(); nil != err {
+				row["error"] = err.Error()
+			}
+		case Histogram:
+			h := metric.Snapshot()
+			row["type"] = "histogram"
+			csvHistogram(row, h.Count(), h.Min(), h.Max(), h.Mean(), h.StdDev(), h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999}))
+		case Meter:
+			m := metric.Snapshot()
+			row["type"] = "meter"
+			row["count"] = strconv.FormatInt(m.Count(), 10)
+			csvRates(row, m.Rate1(), m.Rate5(), m.Rate15(), m.RateMean())
+			row["max.rate"] = csvFloat(m.RateMax())
+			row["min.rate"] = csvFloat(m.RateMin())
+		case Timer:
+			t := metric.Snapshot()
+			row["type"] = "timer"
+			csvHistogram(row, t.Count(), t.Min(), t.Max(), t.Mean(), t.StdDev(), t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999}))
+			csvRates(row, t.Rate1(), t.Rate5(), t.Rate15(), t.RateMean())
+		default:
+			continue
+		}
+		row["name"] = namedMetric.name
+		record := make([]string, len(csvColumns))
+		for i, column := range csvColumns {
+			record[i] = row[column]
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+}
+
+func csvHistogram(row map[string]string, count, min, max int64, mean, stdDev float64, ps []float64) {
+	row["count"] = strconv.FormatInt(count, 10)
+	row["min"] = strconv.FormatInt(min, 10)
+	row["max"] = strconv.FormatInt(max, 10)
+	row["mean"] = csvFloat(mean)
+	row["stddev"] = csvFloat(stdDev)
+	for i, column := range []string{"median", "75%", "95%", "99%", "99.9%"} {
+		row[column] = csvFloat(ps[i])
+	}
+}
+
+func csvRates(row map[string]string, rate1, rate5, rate15, rateMean float64) {
+	row["1m.rate"] = csvFloat(rate1)
+	row["5m.rate"] = csvFloat(rate5)
+	row["15m.rate"] = csvFloat(rate15)
+	row["mean.rate"] = csvFloat(rateMean)
+}
+
+func csvFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
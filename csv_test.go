@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	NewRegisteredGaugeFloat64("bar", r).Update(1.5)
+	r.Register("baz", NewHealthcheck(func(h Healthcheck) { h.Unhealthy(errors.New("down, hard")) }))
+	NewRegisteredTimer("bang", r).Update(23)
+	b := &bytes.Buffer{}
+	WriteCSV(r, b)
+
+	records, err := csv.NewReader(b).ReadAll()
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 5 != len(records) {
+		t.Fatal(records)
+	}
+	for i, c := range []struct{ typ, name string }{
+		{"type", "name"},
+		{"timer", "bang"},
+		{"gauge", "bar"},
+		{"healthcheck", "baz"},
+		{"counter", "foo"},
+	} {
+		if len(csvColumns) != len(records[i]) || c.typ != records[i][0] || c.name != records[i][1] {
+			t.Errorf("records[%d]: %v, %v != %v\n", i, c.typ, c.name, records[i])
+		}
+	}
+	column := func(record []string, name string) string {
+		for i, c := range csvColumns {
+			if c == name {
+				return record[i]
+			}
+		}
+		t.Fatal(name)
+		return ""
+	}
+	if v := column(records[1], "max"); "23" != v {
+		t.Errorf("timer max: 23 != %v\n", v)
+	}
+	if v := column(records[1], "value"); "" != v {
+		t.Errorf("timer value: \"\" != %v\n", v)
+	}
+	if v := column(records[2], "value"); "1.5" != v {
+		t.Errorf("gauge value: 1.5 != %v\n", v)
+	}
+	if v := column(records[3], "error"); "down, hard" != v {
+		t.Errorf("healthcheck error: down, hard != %v\n", v)
+	}
+	if v := column(records[4], "count"); "47" != v {
+		t.Errorf("counter count: 47 != %v\n", v)
+	}
+}
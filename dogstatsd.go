@@ -0,0 +1,229 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultStatsDMTU is the default maximum size of a StatsD packet, chosen to
+// fit within a typical Ethernet MTU after IP and UDP headers.
+const DefaultStatsDMTU = 1432
+
+// DogStatsDConfig provides a container with configuration parameters for
+// the DogStatsD exporter
+type DogStatsDConfig struct {
+	Addr          *net.UDPAddr      // Network address to send to
+	Registry      Registry          // Registry to be exported
+	FlushInterval time.Duration     // Flush interval
+	DurationUnit  time.Duration     // Time conversion unit for durations, nanoseconds if zero
+	Prefix        string            // Prefix to be prepended to metric names
+	Percentiles   []float64         // Percentiles to export from timers and histograms
+	Tags          map[string]string // Tags added to every metric
+	MTU           int               // Maximum packet size, DefaultStatsDMTU if zero
+
+	// Labels, if not nil, is consulted for additional tags for each metric.
+	Labels func(name string, i interface{}) map[string]string
+
+	// Mapper, if not nil, transforms each metric name before it is prefixed
+	// and suffixed.  Labels is passed the name before it is transformed.
+	Mapper func(name string) string
+}
+
+// DogStatsD is a blocking exporter function which reports metrics in r
+// to a DogStatsD agent located at addr, flushing them every d duration.
+func DogStatsD(r Registry, d time.Duration, addr *net.UDPAddr) {
+	DogStatsDWithConfig(DogStatsDConfig{
+		Addr:          addr,
+		Registry:      r,
+		FlushInterval: d,
+		DurationUnit:  time.Nanosecond,
+		Percentiles:   []float64{0.5, 0.75, 0.95, 0.99, 0.999},
+	})
+}
+
+// DogStatsDWithConfig is a blocking exporter function just like DogStatsD,
+// but it takes a DogStatsDConfig instead.
+func DogStatsDWithConfig(c DogStatsDConfig) {
+	for _ = range time.Tick(c.FlushInterval) {
+		if err := dogStatsD(&c); nil != err {
+			log.Println(err)
+		}
+	}
+}
+
+// DogStatsDOnce performs a single submission to DogStatsD, returning a
+// non-nil error on failed connections.
+func DogStatsDOnce(c DogStatsDConfig) error {
+	return dogStatsD(&c)
+}
+
+// dogStatsD sends every metric as a gauge, since Counters, Meters and Timers
+// hold cumulative or already-aggregated values which the agent must not sum
+// or re-aggregate.  For the same reason sample rates set by SetSampleRate
+// are ignored.  The children of a LabeledCounter are sent individually with
+// their labels as tags.
+func dogStatsD(c *DogStatsDConfig) error {
+	du := float64(durationUnit(c.DurationUnit))
+	conn, err := net.DialUDP("udp", nil, c.Addr)
+	if nil != err {
+		return err
+	}
+	defer conn.Close()
+	w := newStatsDPacketWriter(conn, c.MTU)
+	c.Registry.Each(func(name string, i interface{}) {
+		tags := make(map[string]string)
+		for k, v := range c.Tags {
+			tags[k] = v
+		}
+		if l, ok := i.(Labeled); ok {
+			for k, v := range l.Labels() {
+				tags[k] = v
+			}
+		}
+		if nil != c.Labels {
+			for k, v := range c.Labels(name, i) {
+				tags[k] = v
+			}
+		}
+		name = mapName(c.Mapper, name)
+		if "" != c.Prefix {
+			name = c.Prefix + "." + name
+		}
+		gauge := func(suffix string, v float64) {
+			w.WriteLine(fmt.Sprintf("%s%s:%s|g%s", name, suffix, statsDFloat(v), dogStatsDTags(tags)))
+		}
+		switch metric := i.(type) {
+		case LabeledCounter:
+			metric.Each(func(labels map[string]string, child Counter) {
+				childTags := make(map[string]string, len(tags)+len(labels))
+				for k, v := range tags {
+					childTags[k] = v
+				}
+				for k, v := range labels {
+					childTags[k] = v
+				}
+				w.WriteLine(fmt.Sprintf("%s.count:%d|g%s", name, child.Count(), dogStatsDTags(childTags)))
+			})
+		case Counter:
+			gauge(".count", float64(metric.Count()))
+		case Gauge:
+			gauge(".value", float64(metric.Value()))
+		case GaugeFloat64:
+			gauge(".value", metric.Value())
+		case Histogram:
+			h := metric.Snapshot()
+			ps := h.Percentiles(c.Percentiles)
+			gauge(".count", float64(h.Count()))
+			gauge(".min", float64(h.Min()))
+			gauge(".max", float64(h.Max()))
+			gauge(".mean", h.Mean())
+			gauge(".std-dev", h.StdDev())
+			for psIdx, psKey := range c.Percentiles {
+				gauge("."+percentileKey(psKey), ps[psIdx])
+			}
+		case Meter:
+			m := metric.Snapshot()
+			gauge(".count", float64(m.Count()))
+			gauge(".one-minute", m.Rate1())
+			gauge(".five-minute", m.Rate5())
+			gauge(".fifteen-minute", m.Rate15())
+			gauge(".mean", m.RateMean())
+			gauge(".max-rate", m.RateMax())
+			gauge(".min-rate", m.RateMin())
+		case Timer:
+			t := metric.Snapshot()
+			ps := t.Percentiles(c.Percentiles)
+			gauge(".count", float64(t.Count()))
+			gauge(".min", float64(t.Min())/du)
+			gauge(".max", float64(t.Max())/du)
+			gauge(".mean", t.Mean()/du)
+			gauge(".std-dev", t.StdDev()/du)
+			for psIdx, psKey := range c.Percentiles {
+				gauge("."+percentileKey(psKey), ps[psIdx]/du)
+			}
+			gauge(".one-minute", t.Rate1())
+			gauge(".five-minute", t.Rate5())
+			gauge(".fifteen-minute", t.Rate15())
+			gauge(".mean-rate", t.RateMean())
+		}
+	})
+	return w.Flush()
+}
+
+// dogStatsDTags formats tags as a DogStatsD tag suffix, sorted by key.
+func dogStatsDTags(tags map[string]string) string {
+	if 0 == len(tags) {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		if "" == tags[k] {
+			pairs[i] = k
+		} else {
+			pairs[i] = k + ":" + tags[k]
+		}
+	}
+	return "|#" + strings.Join(pairs, ",")
+}
+
+// percentileKey names a percentile for use in a metric name, i.e. 0.95 is
+// named 95-percentile and 0.999 is named 999-percentile.
+func percentileKey(p float64) string {
+	return strings.Replace(strconv.FormatFloat(p*100.0, 'f', -1, 64), ".", "", 1) + "-percentile"
+}
+
+func statsDFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// statsDPacketWriter batches newline-separated lines into writes no larger
+// than mtu bytes so that each write becomes a single datagram.  A line which
+// is itself larger than mtu is written on its own.
+type statsDPacketWriter struct {
+	w   io.Writer
+	mtu int
+	buf []byte
+	err error
+}
+
+func newStatsDPacketWriter(w io.Writer, mtu int) *statsDPacketWriter {
+	if mtu <= 0 {
+		mtu = DefaultStatsDMTU
+	}
+	return &statsDPacketWriter{w: w, mtu: mtu, buf: make([]byte, 0, mtu)}
+}
+
+// WriteLine appends a line to the current packet, first sending the packet
+// if the line would not fit.
+func (p *statsDPacketWriter) WriteLine(line string) {
+	if 0 != len(p.buf) && len(p.buf)+1+len(line) > p.mtu {
+		p.Flush()
+	}
+	if 0 != len(p.buf) {
+		p.buf = append(p.buf, '\n')
+	}
+	p.buf = append(p.buf, line...)
+}
+
+// Flush sends the current packet, if any, and returns the first error
+// encountered by any write.
+func (p *statsDPacketWriter) Flush() error {
+	if 0 != len(p.buf) {
+		if _, err := p.w.Write(p.buf); nil != err && nil == p.err {
+			p.err = err
+		}
+		p.buf = p.buf[:0]
+	}
+	return p.err
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type packetRecorder struct {
+	packets []string
+}
+
+func (r *packetRecorder) Write(b []byte) (int, error) {
+	r.packets = append(r.packets, string(b))
+	return len(b), nil
+}
+
+func TestStatsDPacketWriter(t *testing.T) {
+	rec := &packetRecorder{}
+	w := newStatsDPacketWriter(rec, 10)
+	w.WriteLine("aaaa")
+	w.WriteLine("bbbb")
+	w.WriteLine("cc")
+	w.WriteLine("dddddddddddd")
+	w.WriteLine("e")
+	if err := w.Flush(); nil != err {
+		t.Fatal(err)
+	}
+	want := []string{"aaaa\nbbbb", "cc", "dddddddddddd", "e"}
+	if len(want) != len(rec.packets) {
+		t.Fatal(rec.packets)
+	}
+	for i := range want {
+		if want[i] != rec.packets[i] {
+			t.Errorf("packet %d: %q != %q\n", i, want[i], rec.packets[i])
+		}
+	}
+}
+
+func TestDogStatsDOnce(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	NewRegisteredLabeledCounter("requests", r, nil).With(map[string]string{"code": "200"}).Inc(2)
+	err = DogStatsDOnce(DogStatsDConfig{
+		Addr:         conn.LocalAddr().(*net.UDPAddr),
+		Registry:     r,
+		DurationUnit: time.Nanosecond,
+		Prefix:       "app",
+		Tags:         map[string]string{"env": "test"},
+		Labels: func(name string, i interface{}) map[string]string {
+			return map[string]string{"metric": name}
+		},
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, DefaultStatsDMTU)
+	n, _, err := conn.ReadFromUDP(buf)
+	if nil != err {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(buf[:n]), "\n")
+	got := make(map[string]bool)
+	for _, line := range lines {
+		got[line] = true
+	}
+	for _, line := range []string{
+		"app.foo.count:47|g|#env:test,metric:foo",
+		"app.requests.count:2|g|#code:200,env:test,metric:requests",
+	} {
+		if !got[line] {
+			t.Errorf("missing %q in %q\n", line, lines)
+		}
+	}
+}
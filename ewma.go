@@ -4,6 +4,7 @@ import (
 	"math"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // EWMAs continuously calculate an exponentially-weighted moving average
@@ -23,6 +24,14 @@ func NewEWMA(alpha float64) EWMA {
 	return &StandardEWMA{alpha: alpha}
 }
 
+// EWMAAlpha returns the alpha for an EWMA ticked every tick which averages
+// over the given window, that is 1 - e^(-tick/window).  An event's weight
+// in the average falls by a factor of e every window, so NewEWMA1 uses
+// EWMAAlpha(time.Minute, 5*time.Second).
+func EWMAAlpha(window, tick time.Duration) float64 {
+	return 1 - math.Exp(-float64(tick)/float64(window))
+}
+
 // NewEWMA1 constructs a new EWMA for a one-minute moving average.
 func NewEWMA1() EWMA {
 	return NewEWMA(1 - math.Exp(-5.0/60.0/1))
@@ -81,6 +90,7 @@ type StandardEWMA struct {
 	alpha     float64
 	rate      uint64
 	init      uint32
+	interval  time.Duration // between ticks, five seconds if zero
 	mutex     sync.Mutex
 }
 
@@ -96,7 +106,8 @@ func (a *StandardEWMA) Snapshot() EWMA {
 }
 
 // Tick ticks the clock to update the moving average.  It assumes it is called
-// every five seconds.
+// every five seconds, unless the EWMA was constructed by NewCustomMeter with
+// another tick interval.
 func (a *StandardEWMA) Tick() {
 	// Optimization to avoid mutex locking in the hot-path.
 	if atomic.LoadUint32(&a.init) == 1 {
@@ -122,7 +133,11 @@ func (a *StandardEWMA) Tick() {
 func (a *StandardEWMA) fetchInstantRate() float64 {
 	count := atomic.LoadInt64(&a.uncounted)
 	atomic.AddInt64(&a.uncounted, -count)
-	instantRate := float64(count) / float64(5e9)
+	interval := a.interval
+	if 0 == interval {
+		interval = 5e9
+	}
+	instantRate := float64(count) / float64(interval)
 	return instantRate
 }
 
@@ -132,6 +147,16 @@ func (a *StandardEWMA) updateRate(instantRate float64) {
 	atomic.StoreUint64(&a.rate, math.Float64bits(currentRate))
 }
 
+// reset discards uncounted events and returns the EWMA to its uninitialized
+// state, so the next Tick seeds the rate afresh.
+func (a *StandardEWMA) reset() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	atomic.StoreInt64(&a.uncounted, 0)
+	atomic.StoreUint64(&a.rate, 0)
+	atomic.StoreUint32(&a.init, 0)
+}
+
 // Update adds n uncounted events.
 func (a *StandardEWMA) Update(n int64) {
 	atomic.AddInt64(&a.uncounted, n)
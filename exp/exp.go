@@ -113,6 +113,8 @@ func (exp *exp) publishMeter(name string, metric metrics.Meter) {
 	exp.getFloat(name + ".five-minute").Set(float64(m.Rate5()))
 	exp.getFloat(name + ".fifteen-minute").Set(float64((m.Rate15())))
 	exp.getFloat(name + ".mean").Set(float64(m.RateMean()))
+	exp.getFloat(name + ".max-rate").Set(float64(m.RateMax()))
+	exp.getFloat(name + ".min-rate").Set(float64(m.RateMin()))
 }
 
 func (exp *exp) publishTimer(name string, metric metrics.Timer) {
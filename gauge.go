@@ -5,10 +5,21 @@ import "sync/atomic"
 // Gauges hold an int64 value that can be set arbitrarily.
 type Gauge interface {
 	Snapshot() Gauge
+	Swap(int64) int64
 	Update(int64)
 	Value() int64
 }
 
+// GetGauge returns the Gauge registered under the given name, or nil if no
+// metric is registered under the name or the registered metric is not a Gauge.
+func GetGauge(name string, r Registry) Gauge {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	m, _ := r.Get(name).(Gauge)
+	return m
+}
+
 // GetOrRegisterGauge returns an existing Gauge or constructs and registers a
 // new StandardGauge.
 func GetOrRegisterGauge(name string, r Registry) Gauge {
@@ -60,6 +71,11 @@ type GaugeSnapshot int64
 // Snapshot returns the snapshot.
 func (g GaugeSnapshot) Snapshot() Gauge { return g }
 
+// Swap panics.
+func (GaugeSnapshot) Swap(int64) int64 {
+	panic("Swap called on a GaugeSnapshot")
+}
+
 // Update panics.
 func (GaugeSnapshot) Update(int64) {
 	panic("Update called on a GaugeSnapshot")
@@ -74,6 +90,9 @@ type NilGauge struct{}
 // Snapshot is a no-op.
 func (NilGauge) Snapshot() Gauge { return NilGauge{} }
 
+// Swap is a no-op.
+func (NilGauge) Swap(v int64) int64 { return 0 }
+
 // Update is a no-op.
 func (NilGauge) Update(v int64) {}
 
@@ -91,6 +110,11 @@ func (g *StandardGauge) Snapshot() Gauge {
 	return GaugeSnapshot(g.Value())
 }
 
+// Swap updates the gauge's value and returns its previous value.
+func (g *StandardGauge) Swap(v int64) int64 {
+	return atomic.SwapInt64(&g.value, v)
+}
+
 // Update updates the gauge's value.
 func (g *StandardGauge) Update(v int64) {
 	atomic.StoreInt64(&g.value, v)
@@ -114,6 +138,11 @@ func (g FunctionalGauge) Value() int64 {
 // Snapshot returns the snapshot.
 func (g FunctionalGauge) Snapshot() Gauge { return GaugeSnapshot(g.Value()) }
 
+// Swap panics.
+func (FunctionalGauge) Swap(int64) int64 {
+	panic("Swap called on a FunctionalGauge")
+}
+
 // Update panics.
 func (FunctionalGauge) Update(int64) {
 	panic("Update called on a FunctionalGauge")
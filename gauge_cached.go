@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// NewCachedGauge constructs a new CachedGauge which reads the given gauge at
+// most once every ttl.
+func NewCachedGauge(inner Gauge, ttl time.Duration) Gauge {
+	if UseNilMetrics {
+		return NilGauge{}
+	}
+	return &CachedGauge{inner: inner, ttl: ttl}
+}
+
+// NewRegisteredCachedGauge constructs and registers a new CachedGauge.
+func NewRegisteredCachedGauge(name string, r Registry, inner Gauge, ttl time.Duration) Gauge {
+	c := NewCachedGauge(inner, ttl)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// CachedGauge is a Gauge which caches the value of another Gauge, such as a
+// FunctionalGauge which is expensive to read, for a fixed time so that
+// exporters scraping the same registry share one read.  When the cached
+// value expires, one caller reads the inner gauge while concurrent callers
+// wait for its result rather than reading it too.
+type CachedGauge struct {
+	inner   Gauge
+	ttl     time.Duration
+	mutex   sync.Mutex
+	value   int64
+	expires time.Time
+}
+
+// Snapshot returns a read-only copy of the gauge.
+func (g *CachedGauge) Snapshot() Gauge { return GaugeSnapshot(g.Value()) }
+
+// Swap swaps the inner gauge's value and caches the new value.
+func (g *CachedGauge) Swap(v int64) int64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	old := g.inner.Swap(v)
+	g.value, g.expires = v, time.Now().Add(g.ttl)
+	return old
+}
+
+// Update updates the inner gauge's value and caches the new value.
+func (g *CachedGauge) Update(v int64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.inner.Update(v)
+	g.value, g.expires = v, time.Now().Add(g.ttl)
+}
+
+// Value returns the cached value, reading the inner gauge if it has expired.
+func (g *CachedGauge) Value() int64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if !time.Now().Before(g.expires) {
+		g.value = g.inner.Value()
+		g.expires = time.Now().Add(g.ttl)
+	}
+	return g.value
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCachedGauge(t *testing.T) {
+	var calls int64
+	g := NewCachedGauge(NewFunctionalGauge(func() int64 {
+		time.Sleep(time.Millisecond)
+		return atomic.AddInt64(&calls, 1)
+	}), time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v := g.Value(); 1 != v {
+				t.Errorf("g.Value(): 1 != %v\n", v)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt64(&calls); 1 != n {
+		t.Errorf("calls: 1 != %v\n", n)
+	}
+}
+
+func TestCachedGaugeExpires(t *testing.T) {
+	var calls int64
+	g := NewCachedGauge(NewFunctionalGauge(func() int64 {
+		return atomic.AddInt64(&calls, 1)
+	}), 10*time.Millisecond)
+	g.Value()
+	g.Value()
+	time.Sleep(20 * time.Millisecond)
+	if v := g.Value(); 2 != v {
+		t.Errorf("g.Value(): 2 != %v\n", v)
+	}
+	if v := g.Snapshot().Value(); 2 != v {
+		t.Errorf("g.Snapshot().Value(): 2 != %v\n", v)
+	}
+}
+
+func TestCachedGaugeUpdate(t *testing.T) {
+	inner := NewGauge()
+	g := NewCachedGauge(inner, time.Hour)
+	inner.Update(1)
+	if v := g.Value(); 1 != v {
+		t.Errorf("g.Value(): 1 != %v\n", v)
+	}
+	inner.Update(2)
+	if v := g.Value(); 1 != v {
+		t.Errorf("g.Value(): 1 != %v\n", v)
+	}
+	g.Update(47)
+	if v := inner.Value(); 47 != v {
+		t.Errorf("inner.Value(): 47 != %v\n", v)
+	}
+	if v := g.Swap(48); 47 != v || 48 != g.Value() {
+		t.Errorf("g.Swap(48): 47 != %v\n", v)
+	}
+}
+
+func TestGetOrRegisterCachedGauge(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCachedGauge("foo", r, NewFunctionalGauge(func() int64 { return 47 }), time.Hour)
+	if g := GetOrRegisterGauge("foo", r); 47 != g.Value() {
+		t.Fatal(g)
+	}
+}
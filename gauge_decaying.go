@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// NewDecayingGauge constructs a new DecayingGauge whose value halves every
+// halfLife after it was last updated.
+func NewDecayingGauge(halfLife time.Duration) GaugeFloat64 {
+	if UseNilMetrics {
+		return NilGaugeFloat64{}
+	}
+	return &DecayingGauge{
+		clock:    WallClock,
+		halfLife: halfLife,
+	}
+}
+
+// NewRegisteredDecayingGauge constructs and registers a new DecayingGauge.
+func NewRegisteredDecayingGauge(name string, r Registry, halfLife time.Duration) GaugeFloat64 {
+	c := NewDecayingGauge(halfLife)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// DecayingGauge is a GaugeFloat64 whose value decays exponentially toward
+// zero in the absence of updates, so that a gauge nobody updates any more
+// reads as declining rather than flat.  The decay is computed lazily from
+// the time elapsed since the last Update whenever the value is read, so no
+// background goroutine is needed.
+//
+// The value and the time of the last update are guarded by a single mutex
+// and always change together, so concurrent readers never observe a value
+// decayed against the wrong timestamp.
+type DecayingGauge struct {
+	clock    Clock
+	halfLife time.Duration
+	mutex    sync.Mutex
+	updated  time.Time
+	value    float64
+}
+
+// Add adds the given delta to the gauge's decayed value and restarts its
+// decay.
+func (g *DecayingGauge) Add(delta float64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	now := g.clock.Now()
+	g.updated, g.value = now, g.decayed(now)+delta
+}
+
+// Snapshot returns a read-only copy of the gauge's current decayed value.
+func (g *DecayingGauge) Snapshot() GaugeFloat64 {
+	return GaugeFloat64Snapshot(g.Value())
+}
+
+// Swap updates the gauge's value and returns its previous decayed value.
+func (g *DecayingGauge) Swap(v float64) float64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	now := g.clock.Now()
+	old := g.decayed(now)
+	g.updated, g.value = now, v
+	return old
+}
+
+// Update updates the gauge's value and restarts its decay.
+func (g *DecayingGauge) Update(v float64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.updated, g.value = g.clock.Now(), v
+}
+
+// Value returns the last updated value decayed by the time since.
+func (g *DecayingGauge) Value() float64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.decayed(g.clock.Now())
+}
+
+// decayed must be called with the mutex held.
+func (g *DecayingGauge) decayed(now time.Time) float64 {
+	elapsed := now.Sub(g.updated)
+	if 0 == g.value || elapsed <= 0 {
+		return g.value
+	}
+	if g.halfLife <= 0 {
+		return 0
+	}
+	return g.value * math.Exp2(-float64(elapsed)/float64(g.halfLife))
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func BenchmarkDecayingGauge(b *testing.B) {
+	g := NewDecayingGauge(time.Minute)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.Update(float64(i))
+		g.Value()
+	}
+}
+
+func TestDecayingGauge(t *testing.T) {
+	clock := &manualClock{time.Unix(1000, 0)}
+	g := NewDecayingGauge(time.Minute).(*DecayingGauge)
+	g.clock = clock
+	g.Update(80)
+	if v := g.Value(); 80 != v {
+		t.Errorf("g.Value(): 80 != %v\n", v)
+	}
+	clock.Add(time.Minute)
+	if v := g.Value(); 40 != v {
+		t.Errorf("g.Value(): 40 != %v\n", v)
+	}
+	clock.Add(2 * time.Minute)
+	if v := g.Value(); 10 != v {
+		t.Errorf("g.Value(): 10 != %v\n", v)
+	}
+	clock.Add(30 * time.Second)
+	if v := g.Value(); math.Abs(10/math.Sqrt2-v) > 1e-9 {
+		t.Errorf("g.Value(): %v != %v\n", 10/math.Sqrt2, v)
+	}
+}
+
+func TestDecayingGaugeUpdateRestartsDecay(t *testing.T) {
+	clock := &manualClock{time.Unix(1000, 0)}
+	g := NewDecayingGauge(time.Minute).(*DecayingGauge)
+	g.clock = clock
+	g.Update(80)
+	clock.Add(time.Minute)
+	if old := g.Swap(20); 40 != old {
+		t.Errorf("g.Swap(): 40 != %v\n", old)
+	}
+	clock.Add(time.Minute)
+	if v := g.Snapshot().Value(); 10 != v {
+		t.Errorf("g.Snapshot().Value(): 10 != %v\n", v)
+	}
+}
+
+func TestDecayingGaugeRegistry(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredDecayingGauge("foo", r, time.Minute).Update(47)
+	if g, ok := r.Get("foo").(GaugeFloat64); !ok || 47 < g.Value() || g.Value() < 46 {
+		t.Fatal(r.Get("foo"))
+	}
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NewDerivative constructs a new Derivative which reads the given Counter
+// immediately and then every interval on its own goroutine.
+// Be sure to call Stop() once the gauge is of no use to allow for garbage collection.
+func NewDerivative(c Counter, interval time.Duration) *Derivative {
+	d := &Derivative{counter: c, stop: make(chan struct{})}
+	if !UseNilMetrics {
+		go d.run(interval)
+	}
+	return d
+}
+
+// NewRegisteredDerivative constructs and registers a new Derivative.
+// Be sure to unregister the gauge from the registry once it is of no use to
+// allow for garbage collection.
+func NewRegisteredDerivative(name string, r Registry, c Counter, interval time.Duration) *Derivative {
+	d := NewDerivative(c, interval)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, d)
+	return d
+}
+
+// Derivative is a GaugeFloat64 whose value is the rate of change per second
+// of a Counter, i.e. bytes per second from a count of bytes, over the most
+// recent interval.  The rate is measured over the time actually elapsed
+// between reads of the counter, and is zero until the second read.  If the
+// counter decreases, as when it is cleared, the rate is that of its new count
+// over the interval.
+type Derivative struct {
+	value    uint64 // math.Float64bits of the rate
+	counter  Counter
+	count    int64
+	last     time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Add panics.
+func (*Derivative) Add(float64) {
+	panic("Add called on a Derivative")
+}
+
+// Snapshot returns a read-only copy of the gauge.
+func (d *Derivative) Snapshot() GaugeFloat64 { return GaugeFloat64Snapshot(d.Value()) }
+
+// Stop stops reading the counter.  The last rate is kept.
+func (d *Derivative) Stop() {
+	d.stopOnce.Do(func() { close(d.stop) })
+}
+
+// Swap panics.
+func (*Derivative) Swap(float64) float64 {
+	panic("Swap called on a Derivative")
+}
+
+// Update panics.
+func (*Derivative) Update(float64) {
+	panic("Update called on a Derivative")
+}
+
+// Value returns the counter's rate of change per second over the most
+// recent interval.
+func (d *Derivative) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&d.value))
+}
+
+func (d *Derivative) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		d.read(time.Now())
+		select {
+		case <-ticker.C:
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// read reads the counter at the given time and updates the rate.  It is only
+// called from run's goroutine.
+func (d *Derivative) read(now time.Time) {
+	count := d.counter.Count()
+	if !d.last.IsZero() {
+		if elapsed := now.Sub(d.last).Seconds(); elapsed > 0 {
+			delta := count - d.count
+			if delta < 0 {
+				delta = count
+			}
+			atomic.StoreUint64(&d.value, math.Float64bits(float64(delta)/elapsed))
+		}
+	}
+	d.count, d.last = count, now
+}
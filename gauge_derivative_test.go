@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDerivativeRead(t *testing.T) {
+	c := NewCounter()
+	d := &Derivative{counter: c}
+	now := time.Now()
+	c.Inc(1000)
+	d.read(now)
+	if v := d.Value(); 0 != v {
+		t.Errorf("d.Value(): 0 != %v\n", v)
+	}
+	c.Inc(100)
+	d.read(now.Add(2 * time.Second))
+	if v := d.Value(); 50 != v {
+		t.Errorf("d.Value(): 50 != %v\n", v)
+	}
+	c.Inc(500)
+	d.read(now.Add(3 * time.Second))
+	if v := d.Value(); 500 != v {
+		t.Errorf("d.Value(): 500 != %v\n", v)
+	}
+	c.Clear()
+	c.Inc(10)
+	d.read(now.Add(5 * time.Second))
+	if v := d.Value(); 5 != v {
+		t.Errorf("d.Value(): 5 != %v\n", v)
+	}
+}
+
+func TestDerivative(t *testing.T) {
+	r := NewRegistry()
+	c := NewCounter()
+	d := NewRegisteredDerivative("foo", r, c, time.Millisecond)
+	defer r.Unregister("foo")
+	deadline := time.Now().Add(time.Second)
+	for 0 == d.Value() {
+		if time.Now().After(deadline) {
+			t.Fatal(d.Value())
+		}
+		c.Inc(1)
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := r.Get("foo").(GaugeFloat64); !ok {
+		t.Fatal(r.Get("foo"))
+	}
+}
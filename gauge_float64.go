@@ -7,11 +7,23 @@ import (
 
 // GaugeFloat64s hold a float64 value that can be set arbitrarily.
 type GaugeFloat64 interface {
+	Add(float64)
 	Snapshot() GaugeFloat64
+	Swap(float64) float64
 	Update(float64)
 	Value() float64
 }
 
+// GetGaugeFloat64 returns the GaugeFloat64 registered under the given name, or nil if no
+// metric is registered under the name or the registered metric is not a GaugeFloat64.
+func GetGaugeFloat64(name string, r Registry) GaugeFloat64 {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	m, _ := r.Get(name).(GaugeFloat64)
+	return m
+}
+
 // GetOrRegisterGaugeFloat64 returns an existing GaugeFloat64 or constructs and registers a
 // new StandardGaugeFloat64.
 func GetOrRegisterGaugeFloat64(name string, r Registry) GaugeFloat64 {
@@ -59,12 +71,40 @@ func NewRegisteredFunctionalGaugeFloat64(name string, r Registry, f func() float
 	return c
 }
 
+// NewHistogramGauge constructs a new FunctionalGaugeFloat64 whose value is
+// the given percentile of the given histogram at the time it is read, for
+// exporters which only understand gauges.
+func NewHistogramGauge(h Histogram, p float64) GaugeFloat64 {
+	return NewFunctionalGaugeFloat64(func() float64 { return h.Percentile(p) })
+}
+
+// NewRegisteredHistogramGauge constructs and registers a new
+// FunctionalGaugeFloat64 tracking a percentile of the given histogram.
+func NewRegisteredHistogramGauge(name string, r Registry, h Histogram, p float64) GaugeFloat64 {
+	c := NewHistogramGauge(h, p)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
 // GaugeFloat64Snapshot is a read-only copy of another GaugeFloat64.
 type GaugeFloat64Snapshot float64
 
+// Add panics.
+func (GaugeFloat64Snapshot) Add(float64) {
+	panic("Add called on a GaugeFloat64Snapshot")
+}
+
 // Snapshot returns the snapshot.
 func (g GaugeFloat64Snapshot) Snapshot() GaugeFloat64 { return g }
 
+// Swap panics.
+func (GaugeFloat64Snapshot) Swap(float64) float64 {
+	panic("Swap called on a GaugeFloat64Snapshot")
+}
+
 // Update panics.
 func (GaugeFloat64Snapshot) Update(float64) {
 	panic("Update called on a GaugeFloat64Snapshot")
@@ -76,9 +116,15 @@ func (g GaugeFloat64Snapshot) Value() float64 { return float64(g) }
 // NilGauge is a no-op Gauge.
 type NilGaugeFloat64 struct{}
 
+// Add is a no-op.
+func (NilGaugeFloat64) Add(delta float64) {}
+
 // Snapshot is a no-op.
 func (NilGaugeFloat64) Snapshot() GaugeFloat64 { return NilGaugeFloat64{} }
 
+// Swap is a no-op.
+func (NilGaugeFloat64) Swap(v float64) float64 { return 0.0 }
+
 // Update is a no-op.
 func (NilGaugeFloat64) Update(v float64) {}
 
@@ -91,11 +137,28 @@ type StandardGaugeFloat64 struct {
 	value uint64
 }
 
+// Add adds the given delta to the gauge's value.  Concurrent calls to Add
+// never lose an update.
+func (g *StandardGaugeFloat64) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&g.value)
+		new := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&g.value, old, new) {
+			return
+		}
+	}
+}
+
 // Snapshot returns a read-only copy of the gauge.
 func (g *StandardGaugeFloat64) Snapshot() GaugeFloat64 {
 	return GaugeFloat64Snapshot(g.Value())
 }
 
+// Swap updates the gauge's value and returns its previous value.
+func (g *StandardGaugeFloat64) Swap(v float64) float64 {
+	return math.Float64frombits(atomic.SwapUint64(&g.value, math.Float64bits(v)))
+}
+
 // Update updates the gauge's value.
 func (g *StandardGaugeFloat64) Update(v float64) {
 	atomic.StoreUint64(&g.value, math.Float64bits(v))
@@ -119,6 +182,16 @@ func (g FunctionalGaugeFloat64) Value() float64 {
 // Snapshot returns the snapshot.
 func (g FunctionalGaugeFloat64) Snapshot() GaugeFloat64 { return GaugeFloat64Snapshot(g.Value()) }
 
+// Add panics.
+func (FunctionalGaugeFloat64) Add(float64) {
+	panic("Add called on a FunctionalGaugeFloat64")
+}
+
+// Swap panics.
+func (FunctionalGaugeFloat64) Swap(float64) float64 {
+	panic("Swap called on a FunctionalGaugeFloat64")
+}
+
 // Update panics.
 func (FunctionalGaugeFloat64) Update(float64) {
 	panic("Update called on a FunctionalGaugeFloat64")
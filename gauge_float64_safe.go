@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// NewSafeGaugeFloat64 constructs a new SafeGaugeFloat64 wrapping a new
+// StandardGaugeFloat64.
+func NewSafeGaugeFloat64() *SafeGaugeFloat64 {
+	return &SafeGaugeFloat64{GaugeFloat64: NewGaugeFloat64()}
+}
+
+// NewRegisteredSafeGaugeFloat64 constructs and registers a new
+// SafeGaugeFloat64.
+func NewRegisteredSafeGaugeFloat64(name string, r Registry) *SafeGaugeFloat64 {
+	c := NewSafeGaugeFloat64()
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// SafeGaugeFloat64 is a GaugeFloat64 which guards the GaugeFloat64 it wraps
+// against NaN and infinite values, which cannot be represented by JSON or
+// most exporters.  Such updates are rejected, keeping the last finite value,
+// and counted.  Every method but Add, Swap and Update delegates to the
+// wrapped GaugeFloat64.
+type SafeGaugeFloat64 struct {
+	GaugeFloat64
+	rejected int64
+}
+
+// Add adds the given delta to the gauge's value if delta is finite.
+func (g *SafeGaugeFloat64) Add(delta float64) {
+	if g.accept(delta) {
+		g.GaugeFloat64.Add(delta)
+	}
+}
+
+// Rejected returns the number of NaN and infinite values rejected.
+func (g *SafeGaugeFloat64) Rejected() int64 {
+	return atomic.LoadInt64(&g.rejected)
+}
+
+// Swap updates the gauge's value and returns its previous value if v is
+// finite, and otherwise rejects v and returns the current value.
+func (g *SafeGaugeFloat64) Swap(v float64) float64 {
+	if !g.accept(v) {
+		return g.Value()
+	}
+	return g.GaugeFloat64.Swap(v)
+}
+
+// Update updates the gauge's value if v is finite.
+func (g *SafeGaugeFloat64) Update(v float64) {
+	if g.accept(v) {
+		g.GaugeFloat64.Update(v)
+	}
+}
+
+func (g *SafeGaugeFloat64) accept(v float64) bool {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		atomic.AddInt64(&g.rejected, 1)
+		return false
+	}
+	return true
+}
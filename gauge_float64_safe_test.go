@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSafeGaugeFloat64(t *testing.T) {
+	g := NewSafeGaugeFloat64()
+	g.Update(47.0)
+	g.Update(math.NaN())
+	g.Update(math.Inf(1))
+	if v := g.Value(); 47.0 != v {
+		t.Errorf("g.Value(): 47.0 != %v\n", v)
+	}
+	if old := g.Swap(math.Inf(-1)); 47.0 != old {
+		t.Errorf("g.Swap(): 47.0 != %v\n", old)
+	}
+	if rejected := g.Rejected(); 3 != rejected {
+		t.Errorf("g.Rejected(): 3 != %v\n", rejected)
+	}
+	if old := g.Swap(1.5); 47.0 != old {
+		t.Errorf("g.Swap(): 47.0 != %v\n", old)
+	}
+	if v := g.Snapshot().Value(); 1.5 != v {
+		t.Errorf("g.Snapshot().Value(): 1.5 != %v\n", v)
+	}
+}
+
+func TestSafeGaugeFloat64Registry(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredSafeGaugeFloat64("foo", r).Update(2.5)
+	if g, ok := r.Get("foo").(GaugeFloat64); !ok || 2.5 != g.Value() {
+		t.Fatal(r.Get("foo"))
+	}
+}
@@ -1,6 +1,9 @@
 package metrics
 
-import "testing"
+import (
+	"sync"
+	"testing"
+)
 
 func BenchmarkGuageFloat64(b *testing.B) {
 	g := NewGaugeFloat64()
@@ -28,6 +31,35 @@ func TestGaugeFloat64(t *testing.T) {
 	}
 }
 
+func TestGaugeFloat64Swap(t *testing.T) {
+	g := NewGaugeFloat64()
+	g.Update(47.0)
+	if v := g.Swap(23.5); 47.0 != v {
+		t.Errorf("g.Swap(): 47.0 != %v\n", v)
+	}
+	if v := g.Value(); 23.5 != v {
+		t.Errorf("g.Value(): 23.5 != %v\n", v)
+	}
+}
+
+func TestGaugeFloat64Add(t *testing.T) {
+	g := NewGaugeFloat64()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				g.Add(0.5)
+			}
+		}()
+	}
+	wg.Wait()
+	if v := g.Value(); 4000.0 != v {
+		t.Errorf("g.Value(): 4000.0 != %v\n", v)
+	}
+}
+
 func TestGaugeFloat64Snapshot(t *testing.T) {
 	g := NewGaugeFloat64()
 	g.Update(float64(47.0))
@@ -67,3 +99,36 @@ func TestGetOrRegisterFunctionalGaugeFloat64(t *testing.T) {
 		t.Fatal(g)
 	}
 }
+
+func TestHistogramGauge(t *testing.T) {
+	h := NewHistogram(NewUniformSample(100))
+	g := NewHistogramGauge(h, 0.5)
+	if v := g.Value(); 0 != v {
+		t.Errorf("g.Value(): 0 != %v\n", v)
+	}
+	for i := int64(1); i <= 5; i++ {
+		h.Update(i)
+	}
+	if v := g.Value(); 3 != v {
+		t.Errorf("g.Value(): 3 != %v\n", v)
+	}
+	for i := int64(0); i < 10; i++ {
+		h.Update(100)
+	}
+	if v := g.Value(); 100 != v {
+		t.Errorf("g.Value(): 100 != %v\n", v)
+	}
+	if v := g.Snapshot().Value(); 100 != v {
+		t.Errorf("g.Snapshot().Value(): 100 != %v\n", v)
+	}
+}
+
+func TestGetOrRegisterHistogramGauge(t *testing.T) {
+	r := NewRegistry()
+	h := NewHistogram(NewUniformSample(100))
+	h.Update(47)
+	NewRegisteredHistogramGauge("foo.p99", r, h, 0.99)
+	if g := GetOrRegisterGaugeFloat64("foo.p99", r); 47 != g.Value() {
+		t.Fatal(g)
+	}
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NewPolledGauge constructs a new PolledGauge which calls the given function
+// immediately and then every interval on its own goroutine.
+// Be sure to call Stop() once the gauge is of no use to allow for garbage collection.
+func NewPolledGauge(f func() int64, interval time.Duration) *PolledGauge {
+	g := &PolledGauge{f: f, stop: make(chan struct{})}
+	if !UseNilMetrics {
+		go g.run(interval)
+	}
+	return g
+}
+
+// NewRegisteredPolledGauge constructs and registers a new PolledGauge.
+// Be sure to unregister the gauge from the registry once it is of no use to
+// allow for garbage collection.
+func NewRegisteredPolledGauge(name string, r Registry, f func() int64, interval time.Duration) *PolledGauge {
+	c := NewPolledGauge(f, interval)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// PolledGauge is a Gauge whose value is computed by a function on a schedule
+// rather than on every read, as by FunctionalGauge, for values which are
+// expensive to compute.  Value returns the most recent result without
+// calling the function, and is zero until the first call returns.
+type PolledGauge struct {
+	value    int64
+	f        func() int64
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Snapshot returns a read-only copy of the gauge.
+func (g *PolledGauge) Snapshot() Gauge { return GaugeSnapshot(g.Value()) }
+
+// Stop stops calling the gauge's function.  The last value is kept.
+func (g *PolledGauge) Stop() {
+	g.stopOnce.Do(func() { close(g.stop) })
+}
+
+// Swap panics.
+func (*PolledGauge) Swap(int64) int64 {
+	panic("Swap called on a PolledGauge")
+}
+
+// Update panics.
+func (*PolledGauge) Update(int64) {
+	panic("Update called on a PolledGauge")
+}
+
+// Value returns the result of the most recent call to the gauge's function.
+func (g *PolledGauge) Value() int64 {
+	return atomic.LoadInt64(&g.value)
+}
+
+func (g *PolledGauge) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		atomic.StoreInt64(&g.value, g.f())
+		select {
+		case <-ticker.C:
+		case <-g.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPolledGauge(t *testing.T) {
+	var calls int64
+	g := NewPolledGauge(func() int64 { return atomic.AddInt64(&calls, 1) }, time.Millisecond)
+	defer g.Stop()
+	deadline := time.Now().Add(time.Second)
+	for g.Value() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal(g.Value())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if v := g.Snapshot().Value(); v < 3 {
+		t.Errorf("g.Snapshot().Value(): 3 > %v\n", v)
+	}
+}
+
+func TestPolledGaugeValueDoesNotCall(t *testing.T) {
+	var calls int64
+	g := NewPolledGauge(func() int64 { return atomic.AddInt64(&calls, 1) }, time.Hour)
+	defer g.Stop()
+	deadline := time.Now().Add(time.Second)
+	for 0 == g.Value() {
+		if time.Now().After(deadline) {
+			t.Fatal(g.Value())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		g.Value()
+	}
+	if n := atomic.LoadInt64(&calls); 1 != n {
+		t.Errorf("calls: 1 != %v\n", n)
+	}
+}
+
+func TestPolledGaugeStop(t *testing.T) {
+	var calls int64
+	r := NewRegistry()
+	g := NewRegisteredPolledGauge("foo", r, func() int64 { return atomic.AddInt64(&calls, 1) }, time.Millisecond)
+	r.Unregister("foo")
+	g.Stop()
+	time.Sleep(5 * time.Millisecond)
+	n := atomic.LoadInt64(&calls)
+	time.Sleep(5 * time.Millisecond)
+	if atomic.LoadInt64(&calls) != n {
+		t.Fatal("gauge polled after Stop")
+	}
+}
@@ -40,6 +40,17 @@ func TestGauge(t *testing.T) {
 	}
 }
 
+func TestGaugeSwap(t *testing.T) {
+	g := NewGauge()
+	g.Update(int64(47))
+	if v := g.Swap(int64(23)); 47 != v {
+		t.Errorf("g.Swap(): 47 != %v\n", v)
+	}
+	if v := g.Value(); 23 != v {
+		t.Errorf("g.Value(): 23 != %v\n", v)
+	}
+}
+
 func TestGaugeSnapshot(t *testing.T) {
 	g := NewGauge()
 	g.Update(int64(47))
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// TimestampedGauges are Gauges which keep the value with the most recent
+// source timestamp rather than the value updated last, so that updates from
+// clock-skewed sources arriving out of order do not clobber newer values.
+type TimestampedGauge interface {
+	Gauge
+	UpdateAt(int64, time.Time)
+	UpdatedAt() time.Time
+}
+
+// NewTimestampedGauge constructs a new StandardTimestampedGauge.
+func NewTimestampedGauge() TimestampedGauge {
+	if UseNilMetrics {
+		return NilTimestampedGauge{}
+	}
+	return &StandardTimestampedGauge{}
+}
+
+// NewRegisteredTimestampedGauge constructs and registers a new
+// StandardTimestampedGauge.
+func NewRegisteredTimestampedGauge(name string, r Registry) TimestampedGauge {
+	c := NewTimestampedGauge()
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// NilTimestampedGauge is a no-op TimestampedGauge.
+type NilTimestampedGauge struct{ NilGauge }
+
+// UpdateAt is a no-op.
+func (NilTimestampedGauge) UpdateAt(int64, time.Time) {}
+
+// UpdatedAt is a no-op.
+func (NilTimestampedGauge) UpdatedAt() time.Time { return time.Time{} }
+
+// StandardTimestampedGauge is the standard implementation of a
+// TimestampedGauge.
+type StandardTimestampedGauge struct {
+	mutex     sync.Mutex
+	value     int64
+	updatedAt time.Time
+}
+
+// Snapshot returns a read-only copy of the gauge.
+func (g *StandardTimestampedGauge) Snapshot() Gauge {
+	return GaugeSnapshot(g.Value())
+}
+
+// Swap sets the gauge's value as of now and returns its previous value.
+// Unlike UpdateAt, it always applies, since now is newer than any timestamp
+// not in the future.
+func (g *StandardTimestampedGauge) Swap(v int64) int64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	old := g.value
+	g.value, g.updatedAt = v, time.Now()
+	return old
+}
+
+// Update updates the gauge's value as of now.  See UpdateAt.
+func (g *StandardTimestampedGauge) Update(v int64) {
+	g.UpdateAt(v, time.Now())
+}
+
+// UpdateAt updates the gauge's value as of the given time, unless it already
+// holds a value as of the same or a later time.
+func (g *StandardTimestampedGauge) UpdateAt(v int64, t time.Time) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if !t.After(g.updatedAt) {
+		return
+	}
+	g.value, g.updatedAt = v, t
+}
+
+// UpdatedAt returns the time as of which the gauge holds its value, or the
+// zero time if it has never been updated.
+func (g *StandardTimestampedGauge) UpdatedAt() time.Time {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.updatedAt
+}
+
+// Value returns the gauge's current value.
+func (g *StandardTimestampedGauge) Value() int64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.value
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimestampedGaugeOutOfOrder(t *testing.T) {
+	g := NewTimestampedGauge()
+	t0 := time.Unix(1000, 0)
+	g.UpdateAt(2, t0.Add(2*time.Second))
+	g.UpdateAt(1, t0.Add(time.Second))
+	if v := g.Value(); 2 != v {
+		t.Errorf("g.Value(): 2 != %v\n", v)
+	}
+	g.UpdateAt(3, t0.Add(2*time.Second))
+	if v := g.Value(); 2 != v {
+		t.Errorf("g.Value(): 2 != %v\n", v)
+	}
+	g.UpdateAt(4, t0.Add(3*time.Second))
+	if v := g.Value(); 4 != v {
+		t.Errorf("g.Value(): 4 != %v\n", v)
+	}
+	if at := g.UpdatedAt(); !at.Equal(t0.Add(3 * time.Second)) {
+		t.Errorf("g.UpdatedAt(): %v != %v\n", t0.Add(3*time.Second), at)
+	}
+}
+
+func TestTimestampedGaugeUpdate(t *testing.T) {
+	g := NewTimestampedGauge()
+	if at := g.UpdatedAt(); !at.IsZero() {
+		t.Fatal(at)
+	}
+	before := time.Now()
+	g.Update(47)
+	if v := g.Value(); 47 != v {
+		t.Errorf("g.Value(): 47 != %v\n", v)
+	}
+	if at := g.UpdatedAt(); at.Before(before) {
+		t.Fatal(at)
+	}
+	g.UpdateAt(1, before)
+	if v := g.Snapshot().Value(); 47 != v {
+		t.Errorf("g.Snapshot().Value(): 47 != %v\n", v)
+	}
+	g.UpdateAt(48, time.Now().Add(time.Hour))
+	if v := g.Swap(49); 48 != v || 49 != g.Value() {
+		t.Errorf("g.Swap(49): 48 != %v\n", v)
+	}
+}
+
+func TestGetOrRegisterTimestampedGauge(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredTimestampedGauge("foo", r).Update(47)
+	if g := GetOrRegisterGauge("foo", r); 47 != g.Value() {
+		t.Fatal(g)
+	}
+	if _, ok := r.Get("foo").(TimestampedGauge); !ok {
+		t.Fatal(r.Get("foo"))
+	}
+}
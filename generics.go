@@ -0,0 +1,42 @@
+//go:build go1.18
+// +build go1.18
+
+package metrics
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// GetOrRegisterT returns the metric registered under the given name as an M
+// or constructs one using ctor and registers it.  It panics, naming both
+// types, if the existing metric is not an M.
+//
+//	c := metrics.GetOrRegisterT(r, "foo", metrics.NewCounter)
+func GetOrRegisterT[M any](r Registry, name string, ctor func() M) M {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	i := r.GetOrRegister(name, func() interface{} { return ctor() })
+	m, ok := i.(M)
+	if !ok {
+		panic(fmt.Sprintf("metrics: %s is registered as a %T, not a %s", name, i, typeName[M]()))
+	}
+	return m
+}
+
+// GetT returns the metric registered under the given name as an M and true,
+// or the zero M and false if no metric is registered under the name or the
+// registered metric is not an M.
+func GetT[M any](r Registry, name string) (M, bool) {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	m, ok := r.Get(name).(M)
+	return m, ok
+}
+
+func typeName[M any]() string {
+	var m *M
+	return reflect.TypeOf(m).Elem().String()
+}
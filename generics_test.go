@@ -0,0 +1,48 @@
+//go:build go1.18
+// +build go1.18
+
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetOrRegisterT(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	if c := GetOrRegisterT(r, "foo", NewCounter); 47 != c.Count() {
+		t.Fatal(c)
+	}
+	g := GetOrRegisterT(r, "bar", NewGauge)
+	g.Update(47)
+	if v := r.Get("bar").(Gauge).Value(); 47 != v {
+		t.Fatal(v)
+	}
+}
+
+func TestGetOrRegisterTMismatch(t *testing.T) {
+	r := NewRegistry()
+	r.Register("foo", NewGauge())
+	defer func() {
+		msg, _ := recover().(string)
+		if !strings.Contains(msg, "*metrics.StandardGauge") || !strings.Contains(msg, "metrics.Counter") {
+			t.Fatal(msg)
+		}
+	}()
+	GetOrRegisterT(r, "foo", NewCounter)
+}
+
+func TestGetT(t *testing.T) {
+	r := NewRegistry()
+	r.Register("foo", NewCounter())
+	if _, ok := GetT[Counter](r, "foo"); !ok {
+		t.Fatal("GetT[Counter](foo): !ok")
+	}
+	if _, ok := GetT[Gauge](r, "foo"); ok {
+		t.Fatal("GetT[Gauge](foo): ok")
+	}
+	if _, ok := GetT[Counter](r, "bar"); ok {
+		t.Fatal("GetT[Counter](bar): ok")
+	}
+}
@@ -2,23 +2,118 @@ package metrics
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 // GraphiteConfig provides a container with configuration parameters for
 // the Graphite exporter
 type GraphiteConfig struct {
-	Addr          *net.TCPAddr  // Network address to connect to
-	Registry      Registry      // Registry to be exported
-	FlushInterval time.Duration // Flush interval
-	DurationUnit  time.Duration // Time conversion unit for durations
-	Prefix        string        // Prefix to be prepended to metric names
-	Percentiles   []float64     // Percentiles to export from timers and histograms
+	Addr          *net.TCPAddr     // Network address to connect to
+	Registry      Registry         // Registry to be exported
+	FlushInterval time.Duration    // Flush interval
+	DurationUnit  time.Duration    // Time conversion unit for durations, nanoseconds if zero
+	Prefix        string           // Prefix to be prepended to metric names
+	Percentiles   []float64        // Percentiles to export from timers and histograms, GraphitePercentiles if nil
+	BackoffMin    time.Duration    // Initial delay before retrying a failed flush, one second if zero
+	BackoffMax    time.Duration    // Maximum delay between retries, FlushInterval if zero
+	Protocol      GraphiteProtocol // Wire protocol, GraphitePlaintext if zero
+
+	// Mapper, if not nil, transforms each metric name before it is prefixed
+	// and suffixed, so that Graphite paths need not follow the registry's
+	// names.
+	Mapper func(name string) string
+
+	// OnlyChanged, if true, skips metrics which have not changed since the
+	// last successful flush, as seen by the registry's ChangedSince.  Meters
+	// and Timers are only seen to change when they record events, so their
+	// decaying rates are not re-sent while idle.  Every metric is sent by
+	// the first flush and by GraphiteOnce.
+	OnlyChanged bool
+
+	// Logger, if not nil, logs failed flushes, including panics recovered
+	// from them, instead of the standard logger.
+	Logger Logger
+
+	// FlushNow, if not nil, is received from between intervals to flush
+	// immediately, such as from a signal handler before a deploy.  The flush
+	// happens on the exporter's goroutine, so it never overlaps a flush at an
+	// interval.  Closing it makes the exporter flush once more and return.
+	FlushNow <-chan struct{}
+
+	// CounterMode selects whether Counters are sent as their count or as
+	// its increase since the last successful flush.  CounterAbsolute if zero.
+	CounterMode CounterMode
+
+	lastFlush time.Time
+	counts    map[string]int64 // counts sent by the last successful flush
+}
+
+// CounterMode selects how an exporter sends the value of a Counter.
+type CounterMode int
+
+const (
+	// CounterAbsolute sends the Counter's count.  It is the default.
+	CounterAbsolute CounterMode = iota
+
+	// CounterDelta sends the increase in the Counter's count since the last
+	// successful flush, or all of it if the counter has since been cleared,
+	// without modifying the Counter.  Every call to GraphiteOnce starts
+	// afresh, so it sends the entire count.
+	CounterDelta
+)
+
+// GraphiteProtocol selects how the Graphite exporter encodes metrics on the
+// wire.
+type GraphiteProtocol int
+
+const (
+	// GraphitePlaintext writes one "path value timestamp" line per metric,
+	// as carbon's line receiver expects.  It is the default.
+	GraphitePlaintext GraphiteProtocol = iota
+
+	// GraphiteGzip writes the plaintext lines compressed as a single gzip
+	// stream, for receivers such as carbon-c-relay listening for gzip.
+	GraphiteGzip
+
+	// GraphitePickle writes batches of metrics in the pickle protocol
+	// expected by carbon's pickle receiver, each a four-byte big-endian
+	// length followed by a pickled list of (path, (timestamp, value))
+	// tuples.  See GraphitePickleBatchSize.
+	GraphitePickle
+)
+
+// GraphitePercentiles are the percentiles exported for each Histogram and
+// Timer when GraphiteConfig.Percentiles is nil.
+var GraphitePercentiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
+// GraphiteNameValidator is a NameValidator which rejects names Graphite
+// cannot store: empty names, names containing whitespace or control
+// characters, and dotted names with an empty component.
+func GraphiteNameValidator(name string) error {
+	if "" == name {
+		return errors.New("empty name")
+	}
+	for _, c := range name {
+		if unicode.IsSpace(c) || unicode.IsControl(c) {
+			return fmt.Errorf("contains %q", c)
+		}
+	}
+	for _, component := range strings.Split(name, ".") {
+		if "" == component {
+			return errors.New("empty component")
+		}
+	}
+	return nil
 }
 
 // Graphite is a blocking exporter function which reports metrics in r
@@ -31,7 +126,6 @@ func Graphite(r Registry, d time.Duration, prefix string, addr *net.TCPAddr) {
 		FlushInterval: d,
 		DurationUnit:  time.Nanosecond,
 		Prefix:        prefix,
-		Percentiles:   []float64{0.5, 0.75, 0.95, 0.99, 0.999},
 	})
 }
 
@@ -39,9 +133,19 @@ func Graphite(r Registry, d time.Duration, prefix string, addr *net.TCPAddr) {
 // but it takes a GraphiteConfig instead.
 func GraphiteWithConfig(c GraphiteConfig) {
 	log.Printf("WARNING: This go-metrics client has been DEPRECATED! It has been moved to https://github.com/cyberdelia/go-metrics-graphite and will be removed from rcrowley/go-metrics on August 12th 2015")
-	for _ = range time.Tick(c.FlushInterval) {
-		if err := graphite(&c); nil != err {
-			log.Println(err)
+	ticker := time.Tick(c.FlushInterval)
+	for {
+		closed := false
+		select {
+		case <-ticker:
+		case _, ok := <-c.FlushNow:
+			closed = !ok
+		}
+		if err := recoverFlush(func() error { return graphite(&c) }); nil != err {
+			logFlushError(c.Logger, err)
+		}
+		if closed {
+			return
 		}
 	}
 }
@@ -56,32 +160,70 @@ func GraphiteOnce(c GraphiteConfig) error {
 
 func graphite(c *GraphiteConfig) error {
 	now := time.Now().Unix()
-	du := float64(c.DurationUnit)
+	du := float64(durationUnit(c.DurationUnit))
+	percentiles := c.Percentiles
+	if nil == percentiles {
+		percentiles = GraphitePercentiles
+	}
 	conn, err := net.DialTCP("tcp", nil, c.Addr)
 	if nil != err {
 		return err
 	}
 	defer conn.Close()
-	w := bufio.NewWriter(conn)
+	var out io.Writer = conn
+	var lines bytes.Buffer
+	var zw *gzip.Writer
+	switch c.Protocol {
+	case GraphiteGzip:
+		zw = gzip.NewWriter(conn)
+		out = zw
+	case GraphitePickle:
+		out = &lines
+	}
+	w := bufio.NewWriter(out)
+	var changed func(string) bool
+	if c.OnlyChanged {
+		changed = changedFilter(c.Registry, c.lastFlush)
+	}
+	flushed := time.Now() // after ChangedSince has seen this flush's changes
+	counts := make(map[string]int64)
+	if c.OnlyChanged {
+		for key, count := range c.counts {
+			counts[key] = count // kept for counters which are skipped
+		}
+	}
 	c.Registry.Each(func(name string, i interface{}) {
+		if nil != changed && !changed(name) {
+			return
+		}
+		key := name
+		name = mapName(c.Mapper, name)
 		switch metric := i.(type) {
 		case Counter:
-			fmt.Fprintf(w, "%s.%s.count %d %d\n", c.Prefix, name, metric.Count(), now)
+			count := metric.Count()
+			if CounterDelta == c.CounterMode {
+				counts[key] = count
+				if last := c.counts[key]; last <= count {
+					count -= last
+				}
+			}
+			fmt.Fprintf(w, "%s.%s.count %d %d\n", c.Prefix, name, count, now)
+		case DecimalCounter:
+			fmt.Fprintf(w, "%s.%s.count %f %d\n", c.Prefix, name, metric.Count(), now)
 		case Gauge:
 			fmt.Fprintf(w, "%s.%s.value %d %d\n", c.Prefix, name, metric.Value(), now)
 		case GaugeFloat64:
 			fmt.Fprintf(w, "%s.%s.value %f %d\n", c.Prefix, name, metric.Value(), now)
 		case Histogram:
 			h := metric.Snapshot()
-			ps := h.Percentiles(c.Percentiles)
+			ps := h.Percentiles(percentiles)
 			fmt.Fprintf(w, "%s.%s.count %d %d\n", c.Prefix, name, h.Count(), now)
 			fmt.Fprintf(w, "%s.%s.min %d %d\n", c.Prefix, name, h.Min(), now)
 			fmt.Fprintf(w, "%s.%s.max %d %d\n", c.Prefix, name, h.Max(), now)
 			fmt.Fprintf(w, "%s.%s.mean %.2f %d\n", c.Prefix, name, h.Mean(), now)
 			fmt.Fprintf(w, "%s.%s.std-dev %.2f %d\n", c.Prefix, name, h.StdDev(), now)
-			for psIdx, psKey := range c.Percentiles {
-				key := strings.Replace(strconv.FormatFloat(psKey*100.0, 'f', -1, 64), ".", "", 1)
-				fmt.Fprintf(w, "%s.%s.%s-percentile %.2f %d\n", c.Prefix, name, key, ps[psIdx], now)
+			for psIdx, psKey := range percentiles {
+				fmt.Fprintf(w, "%s.%s.%s %.2f %d\n", c.Prefix, name, graphitePercentileKey(psKey), ps[psIdx], now)
 			}
 		case Meter:
 			m := metric.Snapshot()
@@ -90,24 +232,44 @@ func graphite(c *GraphiteConfig) error {
 			fmt.Fprintf(w, "%s.%s.five-minute %.2f %d\n", c.Prefix, name, m.Rate5(), now)
 			fmt.Fprintf(w, "%s.%s.fifteen-minute %.2f %d\n", c.Prefix, name, m.Rate15(), now)
 			fmt.Fprintf(w, "%s.%s.mean %.2f %d\n", c.Prefix, name, m.RateMean(), now)
+			fmt.Fprintf(w, "%s.%s.max-rate %.2f %d\n", c.Prefix, name, m.RateMax(), now)
+			fmt.Fprintf(w, "%s.%s.min-rate %.2f %d\n", c.Prefix, name, m.RateMin(), now)
 		case Timer:
 			t := metric.Snapshot()
-			ps := t.Percentiles(c.Percentiles)
+			ps := t.Percentiles(percentiles)
 			fmt.Fprintf(w, "%s.%s.count %d %d\n", c.Prefix, name, t.Count(), now)
-			fmt.Fprintf(w, "%s.%s.min %d %d\n", c.Prefix, name, t.Min()/int64(du), now)
-			fmt.Fprintf(w, "%s.%s.max %d %d\n", c.Prefix, name, t.Max()/int64(du), now)
+			fmt.Fprintf(w, "%s.%s.min %.2f %d\n", c.Prefix, name, float64(t.Min())/du, now)
+			fmt.Fprintf(w, "%s.%s.max %.2f %d\n", c.Prefix, name, float64(t.Max())/du, now)
 			fmt.Fprintf(w, "%s.%s.mean %.2f %d\n", c.Prefix, name, t.Mean()/du, now)
 			fmt.Fprintf(w, "%s.%s.std-dev %.2f %d\n", c.Prefix, name, t.StdDev()/du, now)
-			for psIdx, psKey := range c.Percentiles {
-				key := strings.Replace(strconv.FormatFloat(psKey*100.0, 'f', -1, 64), ".", "", 1)
-				fmt.Fprintf(w, "%s.%s.%s-percentile %.2f %d\n", c.Prefix, name, key, ps[psIdx], now)
+			for psIdx, psKey := range percentiles {
+				fmt.Fprintf(w, "%s.%s.%s %.2f %d\n", c.Prefix, name, graphitePercentileKey(psKey), ps[psIdx]/du, now)
 			}
 			fmt.Fprintf(w, "%s.%s.one-minute %.2f %d\n", c.Prefix, name, t.Rate1(), now)
 			fmt.Fprintf(w, "%s.%s.five-minute %.2f %d\n", c.Prefix, name, t.Rate5(), now)
 			fmt.Fprintf(w, "%s.%s.fifteen-minute %.2f %d\n", c.Prefix, name, t.Rate15(), now)
 			fmt.Fprintf(w, "%s.%s.mean-rate %.2f %d\n", c.Prefix, name, t.RateMean(), now)
 		}
-		w.Flush()
 	})
+	if err := w.Flush(); nil != err {
+		return err
+	}
+	switch c.Protocol {
+	case GraphiteGzip:
+		err = zw.Close()
+	case GraphitePickle:
+		err = writeGraphitePickle(conn, lines.Bytes())
+	}
+	if nil != err {
+		return err
+	}
+	c.lastFlush = flushed
+	c.counts = counts
 	return nil
 }
+
+// graphitePercentileKey names a percentile for use in a metric name, i.e.
+// 0.95 is named p95 and 0.999 is named p99_9.
+func graphitePercentileKey(p float64) string {
+	return "p" + strings.Replace(strconv.FormatFloat(p*100.0, 'f', -1, 64), ".", "_", 1)
+}
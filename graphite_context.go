@@ -0,0 +1,64 @@
+//go:build go1.7
+// +build go1.7
+
+package metrics
+
+import (
+	"context"
+	"time"
+)
+
+// GraphiteWithContext is a blocking exporter function just like
+// GraphiteWithConfig, but it returns when ctx is cancelled, performing one
+// final flush before it does.  A failed flush is retried with exponential
+// backoff between c.BackoffMin and c.BackoffMax until it succeeds, so an
+// interval is delayed rather than dropped while the server is unreachable.
+// Closing c.FlushNow has the same effect as cancelling ctx.
+func GraphiteWithContext(ctx context.Context, c GraphiteConfig) {
+	ticker := time.NewTicker(c.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+			graphiteWithBackoff(ctx, &c)
+			continue
+		case _, ok := <-c.FlushNow:
+			if ok {
+				graphiteWithBackoff(ctx, &c)
+				continue
+			}
+		}
+		if err := recoverFlush(func() error { return graphite(&c) }); nil != err {
+			logFlushError(c.Logger, err)
+		}
+		return
+	}
+}
+
+// graphiteWithBackoff flushes to Graphite, retrying until the flush succeeds
+// or ctx is cancelled.
+func graphiteWithBackoff(ctx context.Context, c *GraphiteConfig) {
+	backoff, max := c.BackoffMin, c.BackoffMax
+	if 0 >= backoff {
+		backoff = time.Second
+	}
+	if 0 >= max {
+		max = c.FlushInterval
+	}
+	for {
+		err := recoverFlush(func() error { return graphite(c) })
+		if nil == err {
+			return
+		}
+		logFlushError(c.Logger, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > max {
+			backoff = max
+		}
+	}
+}
@@ -0,0 +1,169 @@
+//go:build go1.7
+// +build go1.7
+
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraphiteWithContextFinalFlush(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	GraphiteWithContext(ctx, GraphiteConfig{
+		Addr:          l.Addr().(*net.TCPAddr),
+		Registry:      r,
+		FlushInterval: time.Hour,
+		DurationUnit:  time.Nanosecond,
+		Prefix:        "prefix",
+	})
+	conn, err := l.Accept()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	line, _ := bufio.NewReader(conn).ReadString('\n')
+	if !strings.HasPrefix(line, "prefix.foo.count 47 ") {
+		t.Fatal(line)
+	}
+}
+
+func TestGraphiteWithBackoff(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	addr := l.Addr().(*net.TCPAddr)
+	l.Close()
+
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	done := make(chan struct{})
+	go func() {
+		graphiteWithBackoff(context.Background(), &GraphiteConfig{
+			Addr:         addr,
+			Registry:     r,
+			DurationUnit: time.Nanosecond,
+			Prefix:       "prefix",
+			BackoffMin:   time.Millisecond,
+			BackoffMax:   10 * time.Millisecond,
+		})
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	l, err = net.ListenTCP("tcp", addr)
+	if nil != err {
+		t.Skip(err)
+	}
+	defer l.Close()
+	conn, err := l.Accept()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	line, _ := bufio.NewReader(conn).ReadString('\n')
+	if !strings.HasPrefix(line, "prefix.foo.count 47 ") {
+		t.Fatal(line)
+	}
+	<-done
+}
+
+func TestGraphiteWithBackoffRecoversPanic(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	ch := make(chan string)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if nil != err {
+				return
+			}
+			b, _ := ioutil.ReadAll(conn)
+			conn.Close()
+			ch <- string(b)
+		}
+	}()
+
+	r := NewRegistry()
+	NewRegisteredFunctionalGauge("foo", r, panicOnce(47))
+	logger := &recordingLogger{}
+	graphiteWithBackoff(context.Background(), &GraphiteConfig{
+		Addr:         l.Addr().(*net.TCPAddr),
+		Registry:     r,
+		DurationUnit: time.Nanosecond,
+		Prefix:       "prefix",
+		BackoffMin:   time.Millisecond,
+		Logger:       logger,
+	})
+	if out := <-ch; "" != out {
+		t.Fatal(out)
+	}
+	if out := <-ch; !strings.HasPrefix(out, "prefix.foo.value 47 ") {
+		t.Fatal(out)
+	}
+	if lines := logger.Lines(); 1 != len(lines) || "panic during flush: nil metric" != lines[0] {
+		t.Fatal(lines)
+	}
+}
+
+func TestGraphiteWithContextFlushNow(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	ch := make(chan string)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if nil != err {
+				return
+			}
+			b, _ := ioutil.ReadAll(conn)
+			conn.Close()
+			ch <- string(b)
+		}
+	}()
+
+	r := NewRegistry()
+	c := NewRegisteredCounter("foo", r)
+	c.Inc(47)
+	flush := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		GraphiteWithContext(context.Background(), GraphiteConfig{
+			Addr:          l.Addr().(*net.TCPAddr),
+			Registry:      r,
+			FlushInterval: time.Hour,
+			Prefix:        "prefix",
+			FlushNow:      flush,
+		})
+		close(done)
+	}()
+	flush <- struct{}{}
+	if out := <-ch; !strings.HasPrefix(out, "prefix.foo.count 47 ") {
+		t.Fatal(out)
+	}
+	c.Inc(1)
+	close(flush)
+	if out := <-ch; !strings.HasPrefix(out, "prefix.foo.count 48 ") {
+		t.Fatal(out)
+	}
+	<-done
+}
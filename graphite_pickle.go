@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// GraphitePickleBatchSize is the number of metrics sent in each pickle
+// protocol message, keeping messages well within carbon's size limit.
+var GraphitePickleBatchSize = 500
+
+// Pickle opcodes, from protocol 2, used by writeGraphitePickle.
+const (
+	pickleProto      = 0x80
+	pickleEmptyList  = ']'
+	pickleMark       = '('
+	pickleAppends    = 'e'
+	pickleBinUnicode = 'X'
+	pickleBinInt     = 'J'
+	pickleLong1      = 0x8a
+	pickleBinFloat   = 'G'
+	pickleTuple2     = 0x86
+	pickleStop       = '.'
+)
+
+// writeGraphitePickle converts plaintext Graphite lines into pickle protocol
+// messages of at most GraphitePickleBatchSize metrics each and writes them.
+func writeGraphitePickle(w io.Writer, lines []byte) error {
+	var batch bytes.Buffer
+	n := 0
+	flush := func() error {
+		if 0 == n {
+			return nil
+		}
+		batch.WriteByte(pickleAppends)
+		batch.WriteByte(pickleStop)
+		header := make([]byte, 4)
+		binary.BigEndian.PutUint32(header, uint32(batch.Len()))
+		if _, err := w.Write(append(header, batch.Bytes()...)); nil != err {
+			return err
+		}
+		batch.Reset()
+		n = 0
+		return nil
+	}
+	s := bufio.NewScanner(bytes.NewReader(lines))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if 3 != len(fields) {
+			return fmt.Errorf("malformed graphite line %q", s.Text())
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if nil != err {
+			return err
+		}
+		timestamp, err := strconv.ParseInt(fields[2], 10, 64)
+		if nil != err {
+			return err
+		}
+		if 0 == n {
+			batch.Write([]byte{pickleProto, 2, pickleEmptyList, pickleMark})
+		}
+		pickleString(&batch, fields[0])
+		pickleInt(&batch, timestamp)
+		batch.WriteByte(pickleBinFloat)
+		binary.Write(&batch, binary.BigEndian, math.Float64bits(value))
+		batch.Write([]byte{pickleTuple2, pickleTuple2})
+		if n++; n >= GraphitePickleBatchSize {
+			if err := flush(); nil != err {
+				return err
+			}
+		}
+	}
+	if err := s.Err(); nil != err {
+		return err
+	}
+	return flush()
+}
+
+func pickleString(b *bytes.Buffer, s string) {
+	b.WriteByte(pickleBinUnicode)
+	binary.Write(b, binary.LittleEndian, uint32(len(s)))
+	b.WriteString(s)
+}
+
+// pickleInt writes i as a four-byte BININT if it fits and otherwise as an
+// eight-byte LONG1.
+func pickleInt(b *bytes.Buffer, i int64) {
+	if math.MinInt32 <= i && i <= math.MaxInt32 {
+		b.WriteByte(pickleBinInt)
+		binary.Write(b, binary.LittleEndian, int32(i))
+		return
+	}
+	b.Write([]byte{pickleLong1, 8})
+	binary.Write(b, binary.LittleEndian, i)
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"strings"
+	"testing"
+)
+
+type pickledMetric struct {
+	path      string
+	timestamp int64
+	value     float64
+}
+
+// unpickleGraphite decodes the subset of the pickle protocol written by
+// writeGraphitePickle.
+func unpickleGraphite(t *testing.T, b []byte) (metrics []pickledMetric, messages int) {
+	for 0 != len(b) {
+		size := int(binary.BigEndian.Uint32(b))
+		r := bytes.NewReader(b[4 : 4+size])
+		b = b[4+size:]
+		messages++
+		var stack []interface{}
+		pop := func() interface{} {
+			v := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			return v
+		}
+		for {
+			op, err := r.ReadByte()
+			if nil != err {
+				t.Fatal(err)
+			}
+			switch op {
+			case pickleProto:
+				r.ReadByte()
+			case pickleEmptyList, pickleMark:
+				stack = append(stack, op)
+			case pickleBinUnicode:
+				var n uint32
+				binary.Read(r, binary.LittleEndian, &n)
+				s := make([]byte, n)
+				r.Read(s)
+				stack = append(stack, string(s))
+			case pickleBinInt:
+				var i int32
+				binary.Read(r, binary.LittleEndian, &i)
+				stack = append(stack, int64(i))
+			case pickleLong1:
+				r.ReadByte()
+				var i int64
+				binary.Read(r, binary.LittleEndian, &i)
+				stack = append(stack, i)
+			case pickleBinFloat:
+				var bits uint64
+				binary.Read(r, binary.BigEndian, &bits)
+				stack = append(stack, math.Float64frombits(bits))
+			case pickleTuple2:
+				second, first := pop(), pop()
+				stack = append(stack, [2]interface{}{first, second})
+			case pickleAppends:
+				for {
+					v := pop()
+					if byte(pickleMark) == v {
+						break
+					}
+					tuple := v.([2]interface{})
+					point := tuple[1].([2]interface{})
+					metrics = append(metrics, pickledMetric{tuple[0].(string), point[0].(int64), point[1].(float64)})
+				}
+			case pickleStop:
+				if 1 != len(stack) || byte(pickleEmptyList) != stack[0] {
+					t.Fatal(stack)
+				}
+				goto next
+			default:
+				t.Fatalf("unexpected opcode %x", op)
+			}
+		}
+	next:
+	}
+	return metrics, messages
+}
+
+func TestWriteGraphitePickle(t *testing.T) {
+	defer func(n int) { GraphitePickleBatchSize = n }(GraphitePickleBatchSize)
+	GraphitePickleBatchSize = 2
+	var b bytes.Buffer
+	err := writeGraphitePickle(&b, []byte("foo.count 47 1400000000\nbar.value 1.50 1400000000\nbaz.mean 3 4294967296\n"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	metrics, messages := unpickleGraphite(t, b.Bytes())
+	if 2 != messages {
+		t.Errorf("messages: 2 != %v\n", messages)
+	}
+	want := map[pickledMetric]bool{
+		{"foo.count", 1400000000, 47}:  true,
+		{"bar.value", 1400000000, 1.5}: true,
+		{"baz.mean", 4294967296, 3}:    true,
+	}
+	if len(want) != len(metrics) {
+		t.Fatal(metrics)
+	}
+	for _, m := range metrics {
+		if !want[m] {
+			t.Errorf("unexpected %v\n", m)
+		}
+	}
+}
+
+func TestGraphiteOncePickle(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	out := graphiteOnceOutput(t, GraphiteConfig{Registry: r, Prefix: "prefix", Protocol: GraphitePickle})
+	metrics, _ := unpickleGraphite(t, []byte(out))
+	if 1 != len(metrics) || "prefix.foo.count" != metrics[0].path || 47 != metrics[0].value {
+		t.Fatal(metrics)
+	}
+}
+
+func TestGraphiteOnceGzip(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	out := graphiteOnceOutput(t, GraphiteConfig{Registry: r, Prefix: "prefix", Protocol: GraphiteGzip})
+	zr, err := gzip.NewReader(strings.NewReader(out))
+	if nil != err {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(zr)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "prefix.foo.count 47 ") {
+		t.Fatal(string(b))
+	}
+}
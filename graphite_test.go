@@ -1,7 +1,10 @@
 package metrics
 
 import (
+	"io/ioutil"
 	"net"
+	"strings"
+	"testing"
 	"time"
 )
 
@@ -20,3 +23,160 @@ func ExampleGraphiteWithConfig() {
 		Percentiles:   []float64{0.5, 0.75, 0.99, 0.999},
 	})
 }
+
+func TestGraphitePercentileKey(t *testing.T) {
+	for p, key := range map[float64]string{0.5: "p50", 0.9: "p90", 0.999: "p99_9", 0.9999: "p99_99"} {
+		if k := graphitePercentileKey(p); key != k {
+			t.Errorf("graphitePercentileKey(%v): %v != %v\n", p, key, k)
+		}
+	}
+}
+
+func TestGraphiteOncePercentiles(t *testing.T) {
+	r := NewRegistry()
+	h := NewRegisteredHistogram("foo", r, NewUniformSample(100))
+	h.Update(47)
+	for _, c := range []struct {
+		percentiles []float64
+		lines       []string
+	}{
+		{nil, []string{"p50", "p75", "p95", "p99", "p99_9"}},
+		{[]float64{0.5, 0.9, 0.999}, []string{"p50", "p90", "p99_9"}},
+	} {
+		out := graphiteOnceOutput(t, GraphiteConfig{
+			Registry:     r,
+			DurationUnit: time.Nanosecond,
+			Prefix:       "prefix",
+			Percentiles:  c.percentiles,
+		})
+		n := strings.Count(out, "prefix.foo.p")
+		if len(c.lines) != n {
+			t.Errorf("%d percentiles != %d in:\n%s", len(c.lines), n, out)
+		}
+		for _, key := range c.lines {
+			if !strings.Contains(out, "prefix.foo."+key+" 47.00 ") {
+				t.Errorf("missing %s in:\n%s", key, out)
+			}
+		}
+	}
+}
+
+func graphiteOnceOutput(t *testing.T, c GraphiteConfig) string {
+	return graphiteOutput(t, &c)
+}
+
+// graphiteOutput performs a single flush of c, which keeps its state for
+// the next flush, and returns what the server received.
+func graphiteOutput(t *testing.T, c *GraphiteConfig) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	ch := make(chan string)
+	go func() {
+		conn, err := l.Accept()
+		if nil != err {
+			ch <- ""
+			return
+		}
+		defer conn.Close()
+		b, _ := ioutil.ReadAll(conn)
+		ch <- string(b)
+	}()
+	c.Addr = l.Addr().(*net.TCPAddr)
+	if err := graphite(c); nil != err {
+		t.Fatal(err)
+	}
+	return <-ch
+}
+
+func TestGraphiteOnceDecimalCounter(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredDecimalCounter("foo", r).Add(1.5)
+	out := graphiteOnceOutput(t, GraphiteConfig{Registry: r, Prefix: "prefix"})
+	if !strings.HasPrefix(out, "prefix.foo.count 1.500000 ") {
+		t.Fatal(out)
+	}
+}
+
+func TestGraphiteOnceMapper(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("http.requests", r).Inc(47)
+	out := graphiteOnceOutput(t, GraphiteConfig{
+		Registry: r,
+		Prefix:   "stats",
+		Mapper: func(name string) string {
+			return "prod.web." + strings.Replace(name, ".", "_", -1)
+		},
+	})
+	if !strings.HasPrefix(out, "stats.prod.web.http_requests.count 47 ") {
+		t.Fatal(out)
+	}
+}
+
+func TestGraphiteCounterDelta(t *testing.T) {
+	r := NewRegistry()
+	counter := NewRegisteredCounter("foo", r)
+	counter.Inc(47)
+	c := &GraphiteConfig{Registry: r, Prefix: "prefix", CounterMode: CounterDelta}
+	if out := graphiteOutput(t, c); !strings.HasPrefix(out, "prefix.foo.count 47 ") {
+		t.Fatal(out)
+	}
+	counter.Inc(3)
+	counter.Inc(2)
+	if out := graphiteOutput(t, c); !strings.HasPrefix(out, "prefix.foo.count 5 ") {
+		t.Fatal(out)
+	}
+	if out := graphiteOutput(t, c); !strings.HasPrefix(out, "prefix.foo.count 0 ") {
+		t.Fatal(out)
+	}
+	if count := counter.Count(); 52 != count {
+		t.Errorf("counter.Count(): 52 != %v\n", count)
+	}
+}
+
+func TestGraphiteCounterAbsolute(t *testing.T) {
+	r := NewRegistry()
+	counter := NewRegisteredCounter("foo", r)
+	counter.Inc(47)
+	c := &GraphiteConfig{Registry: r, Prefix: "prefix"}
+	graphiteOutput(t, c)
+	counter.Inc(5)
+	if out := graphiteOutput(t, c); !strings.HasPrefix(out, "prefix.foo.count 52 ") {
+		t.Fatal(out)
+	}
+}
+
+func TestGraphiteOnceDurationUnit(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredTimer("foo", r).Update(1500 * time.Millisecond)
+	NewRegisteredTimer("bar", r).Update(1900 * time.Microsecond)
+	out := graphiteOnceOutput(t, GraphiteConfig{
+		Registry:     r,
+		DurationUnit: time.Millisecond,
+		Prefix:       "prefix",
+		Percentiles:  []float64{0.5},
+	})
+	for _, line := range []string{
+		"prefix.foo.min 1500.00 ",
+		"prefix.foo.max 1500.00 ",
+		"prefix.bar.min 1.90 ",
+		"prefix.bar.max 1.90 ",
+		"prefix.foo.mean 1500.00 ",
+		"prefix.foo.p50 1500.00 ",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("missing %s in:\n%s", line, out)
+		}
+	}
+}
+
+func TestGraphiteOnceDurationUnitZero(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredTimer("foo", r).Update(47)
+	out := graphiteOnceOutput(t, GraphiteConfig{Registry: r, Prefix: "prefix"})
+	if !strings.Contains(out, "prefix.foo.max 47.00 ") {
+		t.Fatal(out)
+	}
+}
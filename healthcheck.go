@@ -1,5 +1,11 @@
 package metrics
 
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
 // Healthchecks hold an error value describing an arbitrary up/down status.
 type Healthcheck interface {
 	Check()
@@ -17,6 +23,19 @@ func NewHealthcheck(f func(Healthcheck)) Healthcheck {
 	return &StandardHealthcheck{nil, f}
 }
 
+// NewRateHealthcheck constructs a new Healthcheck which is unhealthy while
+// the given meter's one-minute rate exceeds maxRate, such as a meter marked
+// on every error.
+func NewRateHealthcheck(m Meter, maxRate float64) Healthcheck {
+	return NewHealthcheck(func(h Healthcheck) {
+		if rate := m.Rate1(); rate > maxRate {
+			h.Unhealthy(fmt.Errorf("one-minute rate %g exceeds maximum %g", rate, maxRate))
+		} else {
+			h.Healthy()
+		}
+	})
+}
+
 // NilHealthcheck is a no-op.
 type NilHealthcheck struct{}
 
@@ -59,3 +78,71 @@ func (h *StandardHealthcheck) Healthy() {
 func (h *StandardHealthcheck) Unhealthy(err error) {
 	h.err = err
 }
+
+// NewScheduledHealthcheck constructs a new ScheduledHealthcheck which calls
+// the given function to update its status immediately and then every
+// interval on its own goroutine.
+// Be sure to call Stop() once the healthcheck is of no use to allow for garbage collection.
+func NewScheduledHealthcheck(f func(Healthcheck), interval time.Duration) *ScheduledHealthcheck {
+	h := &ScheduledHealthcheck{f: f, stop: make(chan struct{})}
+	if !UseNilMetrics {
+		go h.run(interval)
+	}
+	return h
+}
+
+// ScheduledHealthcheck is a Healthcheck which updates its status on its own
+// schedule rather than when checked, so that a slow check cannot stall
+// RunHealthchecks.  Its status may be read and updated concurrently.
+type ScheduledHealthcheck struct {
+	err      error
+	f        func(Healthcheck)
+	mutex    sync.RWMutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Check returns immediately; the status is updated on the healthcheck's
+// schedule.
+func (h *ScheduledHealthcheck) Check() {}
+
+// Error returns the status from the most recent run of the healthcheck
+// function, which will be nil if it is healthy.
+func (h *ScheduledHealthcheck) Error() error {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.err
+}
+
+// Healthy marks the healthcheck as healthy.
+func (h *ScheduledHealthcheck) Healthy() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.err = nil
+}
+
+// Stop stops running the healthcheck function.  The last status is kept.
+func (h *ScheduledHealthcheck) Stop() {
+	h.stopOnce.Do(func() { close(h.stop) })
+}
+
+// Unhealthy marks the healthcheck as unhealthy.  The error is stored and
+// may be retrieved by the Error method.
+func (h *ScheduledHealthcheck) Unhealthy(err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.err = err
+}
+
+func (h *ScheduledHealthcheck) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		h.f(h)
+		select {
+		case <-ticker.C:
+		case <-h.stop:
+			return
+		}
+	}
+}
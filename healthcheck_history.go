@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// HealthResult is the outcome of one run of a healthcheck's function.
+type HealthResult struct {
+	Time time.Time // When the run finished
+	Err  error     // Status after the run, nil if healthy
+}
+
+// NewHealthcheckWithHistory constructs a new HealthcheckWithHistory which
+// will use the given function to update its status and remembers the
+// outcomes of the last n checks.  An n less than one is replaced by one.
+func NewHealthcheckWithHistory(f func(Healthcheck), n int) *HealthcheckWithHistory {
+	if n < 1 {
+		n = 1
+	}
+	return &HealthcheckWithHistory{f: f, results: make([]HealthResult, 0, n)}
+}
+
+// HealthcheckWithHistory is a Healthcheck which records the outcome of each
+// Check in a ring buffer, so that a persistent failure can be told apart
+// from one which comes and goes.  Its status may be read and updated
+// concurrently.
+type HealthcheckWithHistory struct {
+	err     error
+	f       func(Healthcheck)
+	mutex   sync.RWMutex
+	results []HealthResult
+	next    int // index of the oldest result once results is full
+}
+
+// Check runs the healthcheck function to update the healthcheck's status
+// and records the outcome.
+func (h *HealthcheckWithHistory) Check() {
+	h.f(h)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	result := HealthResult{Time: time.Now(), Err: h.err}
+	if len(h.results) < cap(h.results) {
+		h.results = append(h.results, result)
+		return
+	}
+	h.results[h.next] = result
+	h.next = (h.next + 1) % len(h.results)
+}
+
+// Error returns the healthcheck's status, which will be nil if it is healthy.
+func (h *HealthcheckWithHistory) Error() error {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.err
+}
+
+// Healthy marks the healthcheck as healthy.
+func (h *HealthcheckWithHistory) Healthy() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.err = nil
+}
+
+// History returns the outcomes of the most recent checks, oldest first.
+func (h *HealthcheckWithHistory) History() []HealthResult {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	history := make([]HealthResult, 0, len(h.results))
+	history = append(history, h.results[h.next:]...)
+	return append(history, h.results[:h.next]...)
+}
+
+// IsHealthy reports whether the most recent check was healthy, or true if
+// the healthcheck has not been checked.  It is not named Healthy, which
+// marks a Healthcheck as healthy.
+func (h *HealthcheckWithHistory) IsHealthy() bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if 0 == len(h.results) {
+		return true
+	}
+	latest := (h.next + len(h.results) - 1) % len(h.results)
+	return nil == h.results[latest].Err
+}
+
+// Unhealthy marks the healthcheck as unhealthy.  The error is stored and
+// may be retrieved by the Error method.
+func (h *HealthcheckWithHistory) Unhealthy(err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.err = err
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHealthcheckWithHistory(t *testing.T) {
+	outcomes := []error{nil, errors.New("down"), nil, errors.New("still down"), errors.New("down again")}
+	i := 0
+	h := NewHealthcheckWithHistory(func(h Healthcheck) {
+		if err := outcomes[i]; nil != err {
+			h.Unhealthy(err)
+		} else {
+			h.Healthy()
+		}
+		i++
+	}, 3)
+	if !h.IsHealthy() || 0 != len(h.History()) {
+		t.Fatal(h.History())
+	}
+	h.Check()
+	h.Check()
+	if h.IsHealthy() {
+		t.Fatal("healthy after failed check")
+	}
+	if history := h.History(); 2 != len(history) || nil != history[0].Err || "down" != history[1].Err.Error() {
+		t.Fatal(history)
+	}
+	h.Check()
+	h.Check()
+	h.Check()
+	history := h.History()
+	if 3 != len(history) {
+		t.Fatal(history)
+	}
+	if nil != history[0].Err || "still down" != history[1].Err.Error() || "down again" != history[2].Err.Error() {
+		t.Fatal(history)
+	}
+	if history[0].Time.After(history[1].Time) || history[1].Time.After(history[2].Time) {
+		t.Fatal(history)
+	}
+	if h.IsHealthy() || "down again" != h.Error().Error() {
+		t.Fatal(h.Error())
+	}
+}
+
+func TestHealthcheckWithHistoryRegistry(t *testing.T) {
+	r := NewRegistry()
+	h := NewHealthcheckWithHistory(func(h Healthcheck) { h.Healthy() }, 0)
+	r.Register("foo", h)
+	r.RunHealthchecks()
+	r.RunHealthchecks()
+	if history := h.History(); 1 != len(history) || !h.IsHealthy() {
+		t.Fatal(history)
+	}
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduledHealthcheck(t *testing.T) {
+	var runs int32
+	h := NewScheduledHealthcheck(func(h Healthcheck) {
+		if 1 == atomic.AddInt32(&runs, 1) {
+			h.Unhealthy(errors.New("down"))
+		} else {
+			h.Healthy()
+		}
+	}, time.Millisecond)
+	defer h.Stop()
+	deadline := time.Now().Add(time.Second)
+	for nil != h.Error() || atomic.LoadInt32(&runs) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal(h.Error())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScheduledHealthcheckCheckDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	h := NewScheduledHealthcheck(func(h Healthcheck) {
+		<-release
+		h.Unhealthy(errors.New("slow"))
+	}, time.Hour)
+	defer h.Stop()
+	r := NewRegistry()
+	r.Register("foo", h)
+	done := make(chan struct{})
+	go func() {
+		r.RunHealthchecks()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunHealthchecks blocked on a slow ScheduledHealthcheck")
+	}
+	if err := h.Error(); nil != err {
+		t.Fatal(err)
+	}
+	close(release)
+}
+
+func TestScheduledHealthcheckStop(t *testing.T) {
+	var runs int32
+	h := NewScheduledHealthcheck(func(Healthcheck) { atomic.AddInt32(&runs, 1) }, time.Millisecond)
+	r := NewRegistry()
+	r.Register("foo", h)
+	r.Unregister("foo")
+	h.Stop()
+	time.Sleep(5 * time.Millisecond)
+	n := atomic.LoadInt32(&runs)
+	time.Sleep(5 * time.Millisecond)
+	if atomic.LoadInt32(&runs) != n {
+		t.Fatal("healthcheck ran after Stop")
+	}
+}
+
+func TestRateHealthcheck(t *testing.T) {
+	m := newStandardMeter()
+	h := NewRateHealthcheck(m, 1)
+	h.Check()
+	if err := h.Error(); nil != err {
+		t.Fatal(err)
+	}
+	m.Mark(10)
+	m.tick()
+	h.Check()
+	if err := h.Error(); nil == err || "one-minute rate 2 exceeds maximum 1" != err.Error() {
+		t.Fatal(err)
+	}
+	m.reset()
+	h.Check()
+	if err := h.Error(); nil != err {
+		t.Fatal(err)
+	}
+}
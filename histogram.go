@@ -5,9 +5,13 @@ type Histogram interface {
 	Clear()
 	Count() int64
 	Max() int64
+	MaxOK() (int64, bool)
 	Mean() float64
+	MeanOK() (float64, bool)
 	Min() int64
+	MinOK() (int64, bool)
 	Percentile(float64) float64
+	PercentileOK(float64) (float64, bool)
 	Percentiles([]float64) []float64
 	Sample() Sample
 	Snapshot() Histogram
@@ -17,6 +21,16 @@ type Histogram interface {
 	Variance() float64
 }
 
+// GetHistogram returns the Histogram registered under the given name, or nil if no
+// metric is registered under the name or the registered metric is not a Histogram.
+func GetHistogram(name string, r Registry) Histogram {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	m, _ := r.Get(name).(Histogram)
+	return m
+}
+
 // GetOrRegisterHistogram returns an existing Histogram or constructs and
 // registers a new StandardHistogram.
 func GetOrRegisterHistogram(name string, r Registry, s Sample) Histogram {
@@ -47,7 +61,7 @@ func NewRegisteredHistogram(name string, r Registry, s Sample) Histogram {
 
 // HistogramSnapshot is a read-only copy of another Histogram.
 type HistogramSnapshot struct {
-	sample *SampleSnapshot
+	sample Sample
 }
 
 // Clear panics.
@@ -63,20 +77,56 @@ func (h *HistogramSnapshot) Count() int64 { return h.sample.Count() }
 // taken.
 func (h *HistogramSnapshot) Max() int64 { return h.sample.Max() }
 
+// MaxOK returns the maximum value in the sample at the time the snapshot was
+// taken and false if the sample was empty.
+func (h *HistogramSnapshot) MaxOK() (int64, bool) {
+	if 0 == h.sample.Size() {
+		return 0, false
+	}
+	return h.sample.Max(), true
+}
+
 // Mean returns the mean of the values in the sample at the time the snapshot
 // was taken.
 func (h *HistogramSnapshot) Mean() float64 { return h.sample.Mean() }
 
+// MeanOK returns the mean of the values in the sample at the time the
+// snapshot was taken and false if the sample was empty.
+func (h *HistogramSnapshot) MeanOK() (float64, bool) {
+	if 0 == h.sample.Size() {
+		return 0.0, false
+	}
+	return h.sample.Mean(), true
+}
+
 // Min returns the minimum value in the sample at the time the snapshot was
 // taken.
 func (h *HistogramSnapshot) Min() int64 { return h.sample.Min() }
 
+// MinOK returns the minimum value in the sample at the time the snapshot was
+// taken and false if the sample was empty.
+func (h *HistogramSnapshot) MinOK() (int64, bool) {
+	if 0 == h.sample.Size() {
+		return 0, false
+	}
+	return h.sample.Min(), true
+}
+
 // Percentile returns an arbitrary percentile of values in the sample at the
 // time the snapshot was taken.
 func (h *HistogramSnapshot) Percentile(p float64) float64 {
 	return h.sample.Percentile(p)
 }
 
+// PercentileOK returns an arbitrary percentile of values in the sample at the
+// time the snapshot was taken and false if the sample was empty.
+func (h *HistogramSnapshot) PercentileOK(p float64) (float64, bool) {
+	if 0 == h.sample.Size() {
+		return 0.0, false
+	}
+	return h.sample.Percentile(p), true
+}
+
 // Percentiles returns a slice of arbitrary percentiles of values in the sample
 // at the time the snapshot was taken.
 func (h *HistogramSnapshot) Percentiles(ps []float64) []float64 {
@@ -116,15 +166,27 @@ func (NilHistogram) Count() int64 { return 0 }
 // Max is a no-op.
 func (NilHistogram) Max() int64 { return 0 }
 
+// MaxOK is a no-op.
+func (NilHistogram) MaxOK() (int64, bool) { return 0, false }
+
 // Mean is a no-op.
 func (NilHistogram) Mean() float64 { return 0.0 }
 
+// MeanOK is a no-op.
+func (NilHistogram) MeanOK() (float64, bool) { return 0.0, false }
+
 // Min is a no-op.
 func (NilHistogram) Min() int64 { return 0 }
 
+// MinOK is a no-op.
+func (NilHistogram) MinOK() (int64, bool) { return 0, false }
+
 // Percentile is a no-op.
 func (NilHistogram) Percentile(p float64) float64 { return 0.0 }
 
+// PercentileOK is a no-op.
+func (NilHistogram) PercentileOK(p float64) (float64, bool) { return 0.0, false }
+
 // Percentiles is a no-op.
 func (NilHistogram) Percentiles(ps []float64) []float64 {
 	return make([]float64, len(ps))
@@ -164,17 +226,35 @@ func (h *StandardHistogram) Count() int64 { return h.sample.Count() }
 // Max returns the maximum value in the sample.
 func (h *StandardHistogram) Max() int64 { return h.sample.Max() }
 
+// MaxOK returns the maximum value in the sample and false if the sample is
+// empty, distinguishing "no data" from a legitimate zero.
+func (h *StandardHistogram) MaxOK() (int64, bool) { return h.Snapshot().MaxOK() }
+
 // Mean returns the mean of the values in the sample.
 func (h *StandardHistogram) Mean() float64 { return h.sample.Mean() }
 
+// MeanOK returns the mean of the values in the sample and false if the sample
+// is empty.
+func (h *StandardHistogram) MeanOK() (float64, bool) { return h.Snapshot().MeanOK() }
+
 // Min returns the minimum value in the sample.
 func (h *StandardHistogram) Min() int64 { return h.sample.Min() }
 
+// MinOK returns the minimum value in the sample and false if the sample is
+// empty.
+func (h *StandardHistogram) MinOK() (int64, bool) { return h.Snapshot().MinOK() }
+
 // Percentile returns an arbitrary percentile of the values in the sample.
 func (h *StandardHistogram) Percentile(p float64) float64 {
 	return h.sample.Percentile(p)
 }
 
+// PercentileOK returns an arbitrary percentile of the values in the sample
+// and false if the sample is empty.
+func (h *StandardHistogram) PercentileOK(p float64) (float64, bool) {
+	return h.Snapshot().PercentileOK(p)
+}
+
 // Percentiles returns a slice of arbitrary percentiles of the values in the
 // sample.
 func (h *StandardHistogram) Percentiles(ps []float64) []float64 {
@@ -186,7 +266,7 @@ func (h *StandardHistogram) Sample() Sample { return h.sample }
 
 // Snapshot returns a read-only copy of the histogram.
 func (h *StandardHistogram) Snapshot() Histogram {
-	return &HistogramSnapshot{sample: h.sample.Snapshot().(*SampleSnapshot)}
+	return &HistogramSnapshot{sample: h.sample.Snapshot()}
 }
 
 // StdDev returns the standard deviation of the values in the sample.
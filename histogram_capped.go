@@ -0,0 +1,48 @@
+package metrics
+
+import "sync/atomic"
+
+// NewCappedHistogram wraps the given Histogram in a CappedHistogram which
+// drops values outside [min, max].
+func NewCappedHistogram(h Histogram, min, max int64) *CappedHistogram {
+	return &CappedHistogram{Histogram: h, min: min, max: max}
+}
+
+// NewClampedHistogram wraps the given Histogram in a CappedHistogram which
+// clamps values outside [min, max] to the nearer bound.
+func NewClampedHistogram(h Histogram, min, max int64) *CappedHistogram {
+	return &CappedHistogram{Histogram: h, min: min, max: max, clamp: true}
+}
+
+// CappedHistogram is a Histogram which guards the Histogram it wraps against
+// values outside a range, either dropping or clamping them, and counts them.
+// Every method but Update delegates to the wrapped Histogram.
+type CappedHistogram struct {
+	Histogram
+	min, max int64
+	clamp    bool
+	rejected int64
+}
+
+// Rejected returns the number of values outside the range, whether they were
+// dropped or clamped.
+func (h *CappedHistogram) Rejected() int64 {
+	return atomic.LoadInt64(&h.rejected)
+}
+
+// Update samples a new value if it is within the range, or otherwise drops
+// or clamps it.
+func (h *CappedHistogram) Update(v int64) {
+	if v < h.min || v > h.max {
+		atomic.AddInt64(&h.rejected, 1)
+		if !h.clamp {
+			return
+		}
+		if v < h.min {
+			v = h.min
+		} else {
+			v = h.max
+		}
+	}
+	h.Histogram.Update(v)
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import "testing"
+
+func TestCappedHistogram(t *testing.T) {
+	h := NewCappedHistogram(NewHistogram(NewUniformSample(100)), 0, 100)
+	for _, v := range []int64{-1, 0, 50, 100, 101} {
+		h.Update(v)
+	}
+	if count := h.Count(); 3 != count {
+		t.Errorf("h.Count(): 3 != %v\n", count)
+	}
+	if min, max := h.Min(), h.Max(); 0 != min || 100 != max {
+		t.Errorf("h.Min(), h.Max(): 0, 100 != %v, %v\n", min, max)
+	}
+	if rejected := h.Rejected(); 2 != rejected {
+		t.Errorf("h.Rejected(): 2 != %v\n", rejected)
+	}
+}
+
+func TestClampedHistogram(t *testing.T) {
+	h := NewClampedHistogram(NewHistogram(NewUniformSample(100)), 10, 100)
+	for _, v := range []int64{-1, 10, 100, 1e9} {
+		h.Update(v)
+	}
+	if count := h.Count(); 4 != count {
+		t.Errorf("h.Count(): 4 != %v\n", count)
+	}
+	if sum := h.Sum(); 220 != sum {
+		t.Errorf("h.Sum(): 220 != %v\n", sum)
+	}
+	if rejected := h.Rejected(); 2 != rejected {
+		t.Errorf("h.Rejected(): 2 != %v\n", rejected)
+	}
+}
+
+func TestCappedHistogramRegistry(t *testing.T) {
+	r := NewRegistry()
+	h := NewCappedHistogram(NewHistogram(NewUniformSample(100)), 0, 100)
+	if err := r.Register("foo", h); nil != err {
+		t.Fatal(err)
+	}
+	h.Update(47)
+	if snapshot := r.Get("foo").(Histogram).Snapshot(); 1 != snapshot.Count() {
+		t.Fatal(snapshot)
+	}
+}
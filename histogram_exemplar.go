@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Exemplars are individual observations tagged with the ID of the trace which
+// produced them, so that latency spikes can be correlated with traces.
+type Exemplar struct {
+	TraceID   string
+	Value     int64
+	Timestamp time.Time
+}
+
+// HistogramBuckets hold the cumulative count of observations less than or
+// equal to UpperBound and the most recent exemplar recorded in the bucket's
+// range, if any.
+type HistogramBucket struct {
+	UpperBound float64
+	Count      int64
+	Exemplar   *Exemplar
+}
+
+// ExemplarHistograms are Histograms which additionally count observations
+// into fixed buckets and keep the most recent exemplar for each bucket.
+type ExemplarHistogram interface {
+	Histogram
+	Buckets() []HistogramBucket
+	BucketSum() int64
+	Exemplars() []Exemplar
+	UpdateWithExemplar(int64, string)
+}
+
+// NewHistogramWithExemplars constructs a new StandardExemplarHistogram from a
+// Sample and a list of bucket upper bounds.  An implicit +Inf bucket catches
+// values above the largest bound.
+func NewHistogramWithExemplars(s Sample, bounds []int64) ExemplarHistogram {
+	if UseNilMetrics {
+		return NilExemplarHistogram{}
+	}
+	b := make([]int64, len(bounds))
+	copy(b, bounds)
+	sort.Sort(int64Slice(b))
+	return &StandardExemplarHistogram{
+		StandardHistogram: &StandardHistogram{sample: s},
+		bounds:            b,
+		counts:            make([]int64, len(b)+1),
+		exemplars:         make([]Exemplar, len(b)+1),
+	}
+}
+
+// NewRegisteredHistogramWithExemplars constructs and registers a new
+// StandardExemplarHistogram.
+func NewRegisteredHistogramWithExemplars(name string, r Registry, s Sample, bounds []int64) ExemplarHistogram {
+	c := NewHistogramWithExemplars(s, bounds)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// NilExemplarHistogram is a no-op ExemplarHistogram.
+type NilExemplarHistogram struct {
+	NilHistogram
+}
+
+// Buckets is a no-op.
+func (NilExemplarHistogram) Buckets() []HistogramBucket { return nil }
+
+// BucketSum is a no-op.
+func (NilExemplarHistogram) BucketSum() int64 { return 0 }
+
+// Exemplars is a no-op.
+func (NilExemplarHistogram) Exemplars() []Exemplar { return nil }
+
+// UpdateWithExemplar is a no-op.
+func (NilExemplarHistogram) UpdateWithExemplar(int64, string) {}
+
+// StandardExemplarHistogram is the standard implementation of an
+// ExemplarHistogram.  Statistics are computed by the embedded
+// StandardHistogram while bucket counts and exemplars are kept exactly.
+type StandardExemplarHistogram struct {
+	*StandardHistogram
+	bounds    []int64
+	counts    []int64
+	exemplars []Exemplar
+	mutex     sync.Mutex
+	sum       int64 // of every value counted into the buckets
+}
+
+// Buckets returns the cumulative bucket counts and their exemplars.
+func (h *StandardExemplarHistogram) Buckets() []HistogramBucket {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	buckets := make([]HistogramBucket, len(h.counts))
+	var count int64
+	for i := range h.counts {
+		count += h.counts[i]
+		buckets[i].Count = count
+		if i < len(h.bounds) {
+			buckets[i].UpperBound = float64(h.bounds[i])
+		} else {
+			buckets[i].UpperBound = math.Inf(1)
+		}
+		if "" != h.exemplars[i].TraceID {
+			e := h.exemplars[i]
+			buckets[i].Exemplar = &e
+		}
+	}
+	return buckets
+}
+
+// BucketSum returns the exact sum of every value counted by Buckets, unlike
+// Sum, which is the sum of the values in the sample.
+func (h *StandardExemplarHistogram) BucketSum() int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.sum
+}
+
+// Clear clears the histogram, its sample, its bucket counts and exemplars,
+// keeping its bucket bounds and reusing their storage.
+func (h *StandardExemplarHistogram) Clear() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.StandardHistogram.Clear()
+	h.sum = 0
+	for i := range h.counts {
+		h.counts[i] = 0
+		h.exemplars[i] = Exemplar{}
+	}
+}
+
+// Exemplars returns the most recent exemplar recorded in each bucket, in
+// bucket order, omitting buckets which have none.
+func (h *StandardExemplarHistogram) Exemplars() []Exemplar {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var exemplars []Exemplar
+	for _, e := range h.exemplars {
+		if "" != e.TraceID {
+			exemplars = append(exemplars, e)
+		}
+	}
+	return exemplars
+}
+
+// Update samples a new value without an exemplar.
+func (h *StandardExemplarHistogram) Update(v int64) {
+	h.UpdateWithExemplar(v, "")
+}
+
+// UpdateWithExemplar samples a new value and, if traceID is not empty,
+// records it as the exemplar for the value's bucket.
+func (h *StandardExemplarHistogram) UpdateWithExemplar(v int64, traceID string) {
+	h.StandardHistogram.Update(v)
+	i := sort.Search(len(h.bounds), func(i int) bool { return v <= h.bounds[i] })
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.counts[i]++
+	h.sum += v
+	if "" != traceID {
+		h.exemplars[i] = Exemplar{TraceID: traceID, Value: v, Timestamp: time.Now()}
+	}
+}
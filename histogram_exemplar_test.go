@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHistogramWithExemplars(t *testing.T) {
+	h := NewHistogramWithExemplars(NewUniformSample(100), []int64{100, 10})
+	h.UpdateWithExemplar(5, "a")
+	h.UpdateWithExemplar(7, "b")
+	h.UpdateWithExemplar(50, "c")
+	h.Update(500)
+	if count := h.Count(); 4 != count {
+		t.Errorf("h.Count(): 4 != %v\n", count)
+	}
+	buckets := h.Buckets()
+	if 3 != len(buckets) {
+		t.Fatal(buckets)
+	}
+	if b := buckets[0]; 10 != b.UpperBound || 2 != b.Count || "b" != b.Exemplar.TraceID || 7 != b.Exemplar.Value {
+		t.Errorf("buckets[0]: %+v %+v\n", b, b.Exemplar)
+	}
+	if b := buckets[1]; 100 != b.UpperBound || 3 != b.Count || "c" != b.Exemplar.TraceID {
+		t.Errorf("buckets[1]: %+v\n", b)
+	}
+	if b := buckets[2]; !math.IsInf(b.UpperBound, 1) || 4 != b.Count || nil != b.Exemplar {
+		t.Errorf("buckets[2]: %+v\n", b)
+	}
+	if exemplars := h.Exemplars(); 2 != len(exemplars) {
+		t.Errorf("h.Exemplars(): %v\n", exemplars)
+	}
+}
+
+func TestHistogramWithExemplarsClear(t *testing.T) {
+	h := NewHistogramWithExemplars(NewUniformSample(100), []int64{10})
+	h.UpdateWithExemplar(5, "a")
+	h.Clear()
+	if exemplars := h.Exemplars(); 0 != len(exemplars) {
+		t.Errorf("h.Exemplars(): %v\n", exemplars)
+	}
+	if count := h.Buckets()[1].Count; 0 != count {
+		t.Errorf("+Inf bucket: 0 != %v\n", count)
+	}
+}
+
+func TestHistogramWithExemplarsClearReusesBuckets(t *testing.T) {
+	h := NewHistogramWithExemplars(NewUniformSample(100), []int64{10, 20}).(*StandardExemplarHistogram)
+	h.Update(5)
+	counts := h.counts
+	h.Clear()
+	h.Update(15)
+	if &counts[0] != &h.counts[0] {
+		t.Fatal("buckets reallocated by Clear")
+	}
+	buckets := h.Buckets()
+	if 3 != len(buckets) || 20 != buckets[1].UpperBound || 0 != buckets[0].Count || 1 != buckets[1].Count {
+		t.Fatal(buckets)
+	}
+}
+
+func TestWritePrometheusExemplars(t *testing.T) {
+	r := NewRegistry()
+	h := NewRegisteredHistogramWithExemplars("latency", r, NewUniformSample(100), []int64{10})
+	h.UpdateWithExemplar(5, "abc")
+	h.Update(20)
+	NewRegisteredCounter("requests", r).Inc(1)
+	b := &bytes.Buffer{}
+	WriteOpenMetrics(r, b)
+	for _, s := range []string{
+		"# TYPE latency histogram\n",
+		"latency_bucket{le=\"10\"} 1 # {trace_id=\"abc\"} 5 ",
+		"latency_bucket{le=\"+Inf\"} 2\n",
+		"latency_sum 25\nlatency_count 2\n",
+		"# TYPE requests counter\nrequests_total 1\n",
+	} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("missing %q in:\n%s", s, b.String())
+		}
+	}
+	if !strings.HasSuffix(b.String(), "\n# EOF\n") {
+		t.Errorf("missing # EOF in:\n%s", b.String())
+	}
+
+	b.Reset()
+	WritePrometheus(r, b)
+	if s := "latency_bucket{le=\"10\"} 1\n"; !strings.Contains(b.String(), s) || strings.Contains(b.String(), "# EOF") {
+		t.Errorf("missing %q in:\n%s", s, b.String())
+	}
+}
+
+func TestWritePrometheusExemplarsSumMatchesCount(t *testing.T) {
+	r := NewRegistry()
+	h := NewRegisteredHistogramWithExemplars("latency", r, NewUniformSample(1), []int64{10})
+	h.Update(5)
+	h.Update(20)
+	b := &bytes.Buffer{}
+	WritePrometheus(r, b)
+	if s := "latency_sum 25\nlatency_count 2\n"; !strings.Contains(b.String(), s) {
+		t.Errorf("missing %q in:\n%s", s, b.String())
+	}
+}
+
+func TestWritePrometheusNilExemplarHistogram(t *testing.T) {
+	r := NewRegistry()
+	r.Register("latency", NilExemplarHistogram{})
+	b := &bytes.Buffer{}
+	WritePrometheus(r, b)
+	if s := "latency_bucket{le=\"+Inf\"} 0\nlatency_sum 0\nlatency_count 0\n"; !strings.Contains(b.String(), s) {
+		t.Errorf("missing %q in:\n%s", s, b.String())
+	}
+}
+
+func TestPrometheusHandlerExemplars(t *testing.T) {
+	r := NewRegistry()
+	h := NewRegisteredHistogramWithExemplars("latency", r, NewUniformSample(100), []int64{10})
+	h.UpdateWithExemplar(5, "abc")
+	w := httptest.NewRecorder()
+	PrometheusHandler(r).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatal(ct)
+	}
+	if strings.Contains(w.Body.String(), "trace_id") || strings.Contains(w.Body.String(), "# EOF") {
+		t.Fatal(w.Body.String())
+	}
+	for accept, openMetrics := range map[string]bool{
+		"text/plain;version=0.0.4":                                    false,
+		"application/openmetrics-text;version=1.0.0,text/plain;q=0.5": true,
+		"application/openmetrics-text":                                true,
+	} {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		PrometheusHandler(r).ServeHTTP(w, req)
+		ct, body := w.Header().Get("Content-Type"), w.Body.String()
+		if openMetrics {
+			if !strings.HasPrefix(ct, "application/openmetrics-text; version=1.0.0") {
+				t.Fatal(accept, ct)
+			}
+			if !strings.Contains(body, "trace_id=\"abc\"") || !strings.HasSuffix(body, "# EOF\n") {
+				t.Fatal(accept, body)
+			}
+		} else if !strings.HasPrefix(ct, "text/plain; version=0.0.4") || strings.Contains(body, "# EOF") {
+			t.Fatal(accept, ct, body)
+		}
+	}
+}
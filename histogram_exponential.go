@@ -0,0 +1,56 @@
+package metrics
+
+// ExponentialHistograms are Histograms whose values are counted in an
+// ExponentialSample, so that histograms from many sources may be merged.
+type ExponentialHistogram interface {
+	Histogram
+	Merge(Histogram) error
+}
+
+// NewExponentialHistogram constructs a new StandardExponentialHistogram
+// whose bucket bounds grow by the given factor.  See NewExponentialSample.
+func NewExponentialHistogram(factor float64) ExponentialHistogram {
+	if UseNilMetrics {
+		return NilExponentialHistogram{}
+	}
+	return &StandardExponentialHistogram{
+		StandardHistogram: &StandardHistogram{sample: NewExponentialSample(factor)},
+	}
+}
+
+// NewRegisteredExponentialHistogram constructs and registers a new
+// StandardExponentialHistogram.
+func NewRegisteredExponentialHistogram(name string, r Registry, factor float64) ExponentialHistogram {
+	c := NewExponentialHistogram(factor)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// NilExponentialHistogram is a no-op ExponentialHistogram.
+type NilExponentialHistogram struct {
+	NilHistogram
+}
+
+// Merge is a no-op.
+func (NilExponentialHistogram) Merge(Histogram) error { return nil }
+
+// StandardExponentialHistogram is the standard implementation of an
+// ExponentialHistogram.
+type StandardExponentialHistogram struct {
+	*StandardHistogram
+}
+
+// Merge adds the bucket counts and statistics of the given histogram, or of
+// a snapshot of one, to this histogram.  It returns ErrIncompatibleSamples
+// unless the other histogram's sample is an ExponentialSample with the same
+// factor.
+func (h *StandardExponentialHistogram) Merge(other Histogram) error {
+	s, ok := other.Sample().(*ExponentialSample)
+	if !ok {
+		return ErrIncompatibleSamples
+	}
+	return h.sample.(*ExponentialSample).Merge(s)
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import "testing"
+
+func TestExponentialHistogramMerge(t *testing.T) {
+	r := NewRegistry()
+	a := NewRegisteredExponentialHistogram("a", r, 1.05)
+	b := NewExponentialHistogram(1.05)
+	for i := int64(1); i <= 1000; i++ {
+		a.Update(i)
+		b.Update(i + 1000)
+	}
+	if err := a.Merge(b.Snapshot()); nil != err {
+		t.Fatal(err)
+	}
+	if count := a.Count(); 2000 != count {
+		t.Errorf("a.Count(): 2000 != %v\n", count)
+	}
+	if sum := a.Sum(); 2001000 != sum {
+		t.Errorf("a.Sum(): 2001000 != %v\n", sum)
+	}
+	ps := a.Percentiles([]float64{0.25, 0.5, 0.99})
+	for i, exact := range []float64{500, 1000, 1980} {
+		if ps[i] < exact || ps[i] > exact*1.05 {
+			t.Errorf("percentile %v: %v not within 5%% above %v\n", i, ps[i], exact)
+		}
+	}
+	if _, ok := r.Get("a").(Histogram); !ok {
+		t.Fatal(r.Get("a"))
+	}
+}
+
+func TestExponentialHistogramMergeIncompatible(t *testing.T) {
+	h := NewExponentialHistogram(2)
+	if err := h.Merge(NewHistogram(NewUniformSample(10))); ErrIncompatibleSamples != err {
+		t.Fatal(err)
+	}
+	if err := h.Merge(NewExponentialHistogram(3)); ErrIncompatibleSamples != err {
+		t.Fatal(err)
+	}
+}
@@ -1,6 +1,9 @@
 package metrics
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func BenchmarkHistogram(b *testing.B) {
 	h := NewHistogram(NewUniformSample(100))
@@ -27,6 +30,25 @@ func TestHistogram10000(t *testing.T) {
 	testHistogram10000(t, h)
 }
 
+func TestHistogramClear(t *testing.T) {
+	for _, h := range []Histogram{
+		NewHistogram(NewUniformSample(100)),
+		NewHistogram(NewHdrSample(1000, 3)),
+		NewHistogramWithExemplars(NewUniformSample(100), []int64{10}),
+		NewExponentialHistogram(2),
+	} {
+		h.Update(47)
+		h.Clear()
+		if count := h.Count(); 0 != count {
+			t.Errorf("%T.Count(): 0 != %v\n", h, count)
+		}
+		h.Update(48)
+		if max := h.Max(); 48 != max {
+			t.Errorf("%T.Max(): 48 != %v\n", h, max)
+		}
+	}
+}
+
 func TestHistogramEmpty(t *testing.T) {
 	h := NewHistogram(NewUniformSample(100))
 	if count := h.Count(); 0 != count {
@@ -56,6 +78,32 @@ func TestHistogramEmpty(t *testing.T) {
 	}
 }
 
+func TestHistogramEmptyOK(t *testing.T) {
+	h := NewHistogram(NewUniformSample(100))
+	if _, ok := h.PercentileOK(0.5); ok {
+		t.Error("h.PercentileOK(0.5): ok on empty sample")
+	}
+	if _, ok := h.MeanOK(); ok {
+		t.Error("h.MeanOK(): ok on empty sample")
+	}
+	if _, ok := h.MinOK(); ok {
+		t.Error("h.MinOK(): ok on empty sample")
+	}
+	if _, ok := h.MaxOK(); ok {
+		t.Error("h.MaxOK(): ok on empty sample")
+	}
+	h.Update(0)
+	if p, ok := h.PercentileOK(0.5); !ok || 0.0 != p {
+		t.Errorf("h.PercentileOK(0.5): 0.0, true != %v, %v\n", p, ok)
+	}
+	if max, ok := h.MaxOK(); !ok || 0 != max {
+		t.Errorf("h.MaxOK(): 0, true != %v, %v\n", max, ok)
+	}
+	if _, ok := (NilHistogram{}).PercentileOK(0.5); ok {
+		t.Error("NilHistogram.PercentileOK(0.5): ok")
+	}
+}
+
 func TestHistogramSnapshot(t *testing.T) {
 	h := NewHistogram(NewUniformSample(100000))
 	for i := 1; i <= 10000; i++ {
@@ -93,3 +141,28 @@ func testHistogram10000(t *testing.T, h Histogram) {
 		t.Errorf("99th percentile: 9900.99 != %v\n", ps[2])
 	}
 }
+
+func TestHistogramVariance(t *testing.T) {
+	h := NewHistogram(NewUniformSample(100))
+	for _, v := range []int64{2, 4, 4, 4, 5, 5, 7, 9} {
+		h.Update(v)
+	}
+	if v := h.Variance(); 4 != v {
+		t.Errorf("h.Variance(): 4 != %v\n", v)
+	}
+	if s := h.StdDev(); math.Sqrt(h.Variance()) != s {
+		t.Errorf("h.StdDev(): %v != %v\n", math.Sqrt(h.Variance()), s)
+	}
+	if v := NewRegisteredHistogram("foo", nil, NilSample{}).Variance(); 0 != v {
+		t.Errorf("Variance(): 0 != %v\n", v)
+	}
+	DefaultRegistry.Unregister("foo")
+	if v := (NilHistogram{}).Variance(); 0 != v {
+		t.Errorf("NilHistogram{}.Variance(): 0 != %v\n", v)
+	}
+	r := NewRegistry()
+	r.Register("foo", h)
+	if v := r.GetAll()["foo"]["variance"]; 4.0 != v {
+		t.Errorf("r.GetAll()[foo][variance]: 4 != %v\n", v)
+	}
+}
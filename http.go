@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler returns an http.Handler which serves the metrics in the given
+// registry as indented JSON in the format of GetAll, for inspecting a live
+// process.  A name query parameter restricts the response to the metric
+// registered under that name, responding 404 Not Found if there is none.
+// Only GET and HEAD are allowed.  It may be registered on
+// http.DefaultServeMux, for example
+//
+//	http.Handle("/debug/metrics", metrics.Handler(metrics.DefaultRegistry))
+func Handler(r Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if "GET" != req.Method && "HEAD" != req.Method {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		all := finiteJSON(r.GetAll())
+		var v interface{} = all
+		if name := req.URL.Query().Get("name"); "" != name {
+			metric, ok := all[name]
+			if !ok {
+				http.Error(w, "metric not found: "+name, http.StatusNotFound)
+				return
+			}
+			v = metric
+		}
+		b, err := json.MarshalIndent(v, "", "  ")
+		if nil != err {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(append(b, '\n'))
+	})
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	NewRegisteredGauge("bar", r).Update(23)
+	s := httptest.NewServer(Handler(r))
+	defer s.Close()
+
+	resp, err := http.Get(s.URL)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if "application/json" != resp.Header.Get("Content-Type") {
+		t.Fatal(resp.Header.Get("Content-Type"))
+	}
+	var all map[string]map[string]float64
+	if err := json.NewDecoder(resp.Body).Decode(&all); nil != err {
+		t.Fatal(err)
+	}
+	if 47 != all["foo"]["count"] || 23 != all["bar"]["value"] {
+		t.Fatal(all)
+	}
+}
+
+func TestHandlerName(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	NewRegisteredGauge("bar", r).Update(23)
+	s := httptest.NewServer(Handler(r))
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "?name=foo")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var metric map[string]float64
+	if err := json.NewDecoder(resp.Body).Decode(&metric); nil != err {
+		t.Fatal(err)
+	}
+	if 1 != len(metric) || 47 != metric["count"] {
+		t.Fatal(metric)
+	}
+
+	resp, err = http.Get(s.URL + "?name=baz")
+	if nil != err {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if http.StatusNotFound != resp.StatusCode {
+		t.Errorf("resp.StatusCode: 404 != %v\n", resp.StatusCode)
+	}
+}
+
+func TestHandlerMethod(t *testing.T) {
+	w := httptest.NewRecorder()
+	Handler(NewRegistry()).ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+	if http.StatusMethodNotAllowed != w.Code {
+		t.Errorf("w.Code: 405 != %v\n", w.Code)
+	}
+}
@@ -0,0 +1,341 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultInfluxDBBatchSize is the default maximum number of points written to
+// InfluxDB in one request.
+const DefaultInfluxDBBatchSize = 5000
+
+// InfluxDBConfig provides a container with configuration parameters for
+// the InfluxDB 2.x exporter
+type InfluxDBConfig struct {
+	URL           string            // Base URL of the server, i.e. http://localhost:8086
+	Org           string            // Organization to write to
+	Bucket        string            // Bucket to write to
+	Token         string            // API token used to authenticate
+	Registry      Registry          // Registry to be exported
+	FlushInterval time.Duration     // Flush interval
+	DurationUnit  time.Duration     // Time conversion unit for durations, nanoseconds if zero
+	Percentiles   []float64         // Percentiles to export from timers and histograms
+	Tags          map[string]string // Tags added to every point
+	BatchSize     int               // Maximum points per request, DefaultInfluxDBBatchSize if zero
+	MaxRetries    int               // Maximum retries of a request rejected with 429 Too Many Requests
+	Client        *http.Client      // HTTP client, http.DefaultClient if nil
+
+	// Mapper, if not nil, transforms each metric name into the measurement
+	// it is written as.
+	Mapper func(name string) string
+
+	// OnlyChanged, if true, skips metrics which have not changed since the
+	// last successful flush.  See GraphiteConfig.OnlyChanged.
+	OnlyChanged bool
+
+	// Units, if true, tags each point with the unit of its metric set by the
+	// registry's SetUnit, so that dashboards can format its values.  Timers
+	// without a unit are tagged with DurationUnit.
+	Units bool
+
+	// Logger, if not nil, logs failed flushes, including panics recovered
+	// from them, instead of the standard logger.
+	Logger Logger
+
+	// FlushNow, if not nil, is received from between intervals to flush
+	// immediately, such as from a signal handler before a deploy.  The flush
+	// happens on the exporter's goroutine, so it never overlaps a flush at an
+	// interval.  Closing it makes the exporter flush once more and return.
+	FlushNow <-chan struct{}
+
+	lastFlush time.Time
+}
+
+// InfluxDB is a blocking exporter function which reports metrics in r to
+// the bucket of an InfluxDB 2.x server at url, flushing them every d
+// duration.
+func InfluxDB(r Registry, d time.Duration, url, org, bucket, token string) {
+	InfluxDBWithConfig(InfluxDBConfig{
+		URL:           url,
+		Org:           org,
+		Bucket:        bucket,
+		Token:         token,
+		Registry:      r,
+		FlushInterval: d,
+		DurationUnit:  time.Nanosecond,
+		Percentiles:   []float64{0.5, 0.75, 0.95, 0.99, 0.999},
+		MaxRetries:    3,
+	})
+}
+
+// InfluxDBWithConfig is a blocking exporter function just like InfluxDB,
+// but it takes an InfluxDBConfig instead.
+func InfluxDBWithConfig(c InfluxDBConfig) {
+	ticker := time.Tick(c.FlushInterval)
+	for {
+		closed := false
+		select {
+		case <-ticker:
+		case _, ok := <-c.FlushNow:
+			closed = !ok
+		}
+		influxDBTick(&c)
+		if closed {
+			return
+		}
+	}
+}
+
+// influxDBTick performs one interval's flush, logging its error, or a panic
+// recovered from it, so that the exporter continues at the next interval.
+func influxDBTick(c *InfluxDBConfig) {
+	if err := recoverFlush(func() error { return influxDB(c) }); nil != err {
+		logFlushError(c.Logger, err)
+	}
+}
+
+// InfluxDBOnce performs a single submission to InfluxDB, returning a
+// non-nil error on failed requests.
+func InfluxDBOnce(c InfluxDBConfig) error {
+	return influxDB(&c)
+}
+
+// influxDB writes one point per metric, or per child of a LabeledCounter,
+// measured by the metric's name and tagged with c.Tags and the metric's
+// labels, and with its unit if c.Units is set.  NaN and infinite fields,
+// which line protocol cannot represent, are omitted, as are points left with
+// no fields.  Points are written
+// c.BatchSize at a time.
+func influxDB(c *InfluxDBConfig) error {
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	du := float64(durationUnit(c.DurationUnit))
+	var unitOf func(string) string
+	if c.Units {
+		if base, _ := findPrefix(c.Registry, ""); nil != base {
+			if u, ok := base.(interface {
+				Unit(string) string
+			}); ok {
+				unitOf = u.Unit
+			}
+		}
+	}
+	var lines []string
+	var unit string // of the metric being written
+	point := func(name string, labels map[string]string, fields ...string) {
+		finite := make([]string, 0, len(fields))
+		for _, f := range fields {
+			if "" != f {
+				finite = append(finite, f)
+			}
+		}
+		if 0 == len(finite) {
+			return
+		}
+		fields = finite
+		tags := make(map[string]string, len(c.Tags)+len(labels)+1)
+		for k, v := range c.Tags {
+			tags[k] = v
+		}
+		for k, v := range labels {
+			tags[k] = v
+		}
+		if "" != unit {
+			tags["unit"] = unit
+		}
+		lines = append(lines, influxMeasurementEscaper.Replace(name)+influxTags(tags)+" "+strings.Join(fields, ",")+" "+now)
+	}
+	var changed func(string) bool
+	if c.OnlyChanged {
+		changed = changedFilter(c.Registry, c.lastFlush)
+	}
+	flushed := time.Now() // after ChangedSince has seen this flush's changes
+	c.Registry.Each(func(name string, i interface{}) {
+		if nil != changed && !changed(name) {
+			return
+		}
+		unit = ""
+		if nil != unitOf {
+			unit = unitOf(name)
+		}
+		if _, ok := i.(Timer); ok && c.Units && "" == unit {
+			unit = durationUnitName(c.DurationUnit)
+		}
+		name = mapName(c.Mapper, name)
+		var labels map[string]string
+		if l, ok := i.(Labeled); ok {
+			labels = l.Labels()
+		}
+		switch metric := i.(type) {
+		case LabeledCounter:
+			metric.Each(func(labels map[string]string, child Counter) {
+				point(name, labels, influxInt("count", child.Count()))
+			})
+		case Counter:
+			point(name, labels, influxInt("count", metric.Count()))
+		case DecimalCounter:
+			point(name, labels, influxFloat("count", metric.Count()))
+		case Gauge:
+			point(name, labels, influxInt("value", metric.Value()))
+		case GaugeFloat64:
+			point(name, labels, influxFloat("value", metric.Value()))
+		case Histogram:
+			h := metric.Snapshot()
+			fields := []string{
+				influxInt("count", h.Count()),
+				influxInt("min", h.Min()),
+				influxInt("max", h.Max()),
+				influxFloat("mean", h.Mean()),
+				influxFloat("stddev", h.StdDev()),
+			}
+			for psIdx, ps := range h.Percentiles(c.Percentiles) {
+				fields = append(fields, influxFloat(graphitePercentileKey(c.Percentiles[psIdx]), ps))
+			}
+			point(name, labels, fields...)
+		case Meter:
+			m := metric.Snapshot()
+			point(
+				name, labels,
+				influxInt("count", m.Count()),
+				influxFloat("rate1", m.Rate1()),
+				influxFloat("rate5", m.Rate5()),
+				influxFloat("rate15", m.Rate15()),
+				influxFloat("rate_mean", m.RateMean()),
+				influxFloat("rate_max", m.RateMax()),
+				influxFloat("rate_min", m.RateMin()),
+			)
+		case Timer:
+			t := metric.Snapshot()
+			fields := []string{
+				influxInt("count", t.Count()),
+				influxFloat("min", float64(t.Min())/du),
+				influxFloat("max", float64(t.Max())/du),
+				influxFloat("mean", t.Mean()/du),
+				influxFloat("stddev", t.StdDev()/du),
+			}
+			for psIdx, ps := range t.Percentiles(c.Percentiles) {
+				fields = append(fields, influxFloat(graphitePercentileKey(c.Percentiles[psIdx]), ps/du))
+			}
+			fields = append(
+				fields,
+				influxFloat("rate1", t.Rate1()),
+				influxFloat("rate5", t.Rate5()),
+				influxFloat("rate15", t.Rate15()),
+				influxFloat("rate_mean", t.RateMean()),
+			)
+			point(name, labels, fields...)
+		}
+	})
+
+	batchSize := c.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultInfluxDBBatchSize
+	}
+	for len(lines) > 0 {
+		n := batchSize
+		if n > len(lines) {
+			n = len(lines)
+		}
+		if err := influxDBWrite(c, strings.Join(lines[:n], "\n")); nil != err {
+			return err
+		}
+		lines = lines[n:]
+	}
+	c.lastFlush = flushed
+	return nil
+}
+
+// influxDBWrite posts a batch of points, retrying up to c.MaxRetries times
+// after the delay given by the Retry-After header when the server responds
+// 429 Too Many Requests.
+func influxDBWrite(c *InfluxDBConfig, body string) error {
+	client := c.Client
+	if nil == client {
+		client = http.DefaultClient
+	}
+	u := fmt.Sprintf(
+		"%s/api/v2/write?%s",
+		strings.TrimRight(c.URL, "/"),
+		url.Values{"org": {c.Org}, "bucket": {c.Bucket}, "precision": {"ns"}}.Encode(),
+	)
+	for retries := 0; ; retries++ {
+		req, err := http.NewRequest("POST", u, bytes.NewBufferString(body))
+		if nil != err {
+			return err
+		}
+		req.Header.Set("Authorization", "Token "+c.Token)
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		resp, err := client.Do(req)
+		if nil != err {
+			return err
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if http.StatusTooManyRequests == resp.StatusCode && retries < c.MaxRetries {
+			time.Sleep(influxDBRetryAfter(resp.Header.Get("Retry-After")))
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("influxdb: %s: %s", resp.Status, strings.TrimSpace(string(b)))
+		}
+		return nil
+	}
+}
+
+// influxDBRetryAfter parses a Retry-After header given in either seconds or
+// as an HTTP date, defaulting to one second.
+func influxDBRetryAfter(header string) time.Duration {
+	if seconds, err := strconv.Atoi(header); nil == err && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(header); nil == err {
+		if d := t.Sub(time.Now()); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return time.Second
+}
+
+// influxFloat formats a float field, or returns the empty string if v is NaN
+// or infinite.
+func influxFloat(key string, v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return ""
+	}
+	return influxEscaper.Replace(key) + "=" + strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func influxInt(key string, v int64) string {
+	return influxEscaper.Replace(key) + "=" + strconv.FormatInt(v, 10) + "i"
+}
+
+// influxTags formats tags as a line protocol tag set, sorted by key as
+// InfluxDB recommends.
+func influxTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if "" != k && "" != v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	for _, k := range keys {
+		fmt.Fprintf(&buf, ",%s=%s", influxEscaper.Replace(k), influxEscaper.Replace(tags[k]))
+	}
+	return buf.String()
+}
+
+// influxEscaper escapes tag keys and values and field keys.
+var influxEscaper = strings.NewReplacer(",", "\\,", "=", "\\=", " ", "\\ ")
+
+// influxMeasurementEscaper escapes measurements.
+var influxMeasurementEscaper = strings.NewReplacer(",", "\\,", " ", "\\ ")
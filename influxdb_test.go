@@ -0,0 +1,297 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ExampleInfluxDB() {
+	go InfluxDB(DefaultRegistry, 10*time.Second, "http://localhost:8086", "org", "bucket", "token")
+}
+
+func TestInfluxDBOnce(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if "/api/v2/write" != req.URL.Path {
+			t.Error(req.URL.Path)
+		}
+		if q := req.URL.Query(); "org" != q.Get("org") || "bucket" != q.Get("bucket") || "ns" != q.Get("precision") {
+			t.Error(q)
+		}
+		if a := req.Header.Get("Authorization"); "Token token" != a {
+			t.Error(a)
+		}
+		b, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(b))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	NewRegisteredGaugeFloat64("bar baz", r).Update(1.5)
+	NewRegisteredLabeledCounter("requests", r, nil).With(map[string]string{"code": "200"}).Inc(3)
+	err := InfluxDBOnce(InfluxDBConfig{
+		URL:       server.URL + "/",
+		Org:       "org",
+		Bucket:    "bucket",
+		Token:     "token",
+		Registry:  r,
+		Tags:      map[string]string{"host": "a,b"},
+		BatchSize: 2,
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(bodies) {
+		t.Fatal(bodies)
+	}
+	lines := strings.Split(strings.Join(bodies, "\n"), "\n")
+	for _, pattern := range []string{
+		`^foo,host=a\\,b count=47i \d+$`,
+		`^bar\\ baz,host=a\\,b value=1.5 \d+$`,
+		`^requests,code=200,host=a\\,b count=3i \d+$`,
+	} {
+		found := false
+		for _, line := range lines {
+			if ok, _ := regexp.MatchString(pattern, line); ok {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s not in %q\n", pattern, lines)
+		}
+	}
+}
+
+func TestInfluxDBOnceNonFinite(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	r := NewRegistry()
+	NewRegisteredGaugeFloat64("nan", r).Update(math.NaN())
+	NewRegisteredGaugeFloat64("inf", r).Update(math.Inf(1))
+	NewRegisteredGaugeFloat64("finite", r).Update(1.5)
+	r.Register("meter", NewStaticMeter(1, math.NaN(), 0, 0, 0, math.Inf(1), 0))
+	if err := InfluxDBOnce(InfluxDBConfig{URL: server.URL, Registry: r}); nil != err {
+		t.Fatal(err)
+	}
+	if strings.Contains(body, "NaN") || strings.Contains(body, "Inf") || strings.Contains(body, "nan ") || strings.Contains(body, "inf ") {
+		t.Fatal(body)
+	}
+	if !strings.Contains(body, "finite value=1.5 ") || !strings.Contains(body, "meter count=1i,rate5=0,") {
+		t.Fatal(body)
+	}
+}
+
+func TestInfluxDBOnceRetryAfter(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	c := InfluxDBConfig{URL: server.URL, Registry: r, MaxRetries: 1}
+	if err := InfluxDBOnce(c); nil != err {
+		t.Fatal(err)
+	}
+	if 2 != requests {
+		t.Fatal(requests)
+	}
+
+	requests = 0
+	c.MaxRetries = 0
+	if err := InfluxDBOnce(c); nil == err {
+		t.Fatal(err)
+	}
+}
+
+func TestInfluxDBRetryAfter(t *testing.T) {
+	if d := influxDBRetryAfter("2"); 2*time.Second != d {
+		t.Error(d)
+	}
+	if d := influxDBRetryAfter(""); time.Second != d {
+		t.Error(d)
+	}
+	if d := influxDBRetryAfter(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)); 0 != d {
+		t.Error(d)
+	}
+}
+
+func TestInfluxDBOnlyChanged(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	r := NewRegistry()
+	c := NewRegisteredCounter("foo", r)
+	NewRegisteredGauge("bar", r).Update(47)
+	config := &InfluxDBConfig{URL: server.URL, Registry: r, OnlyChanged: true}
+	if err := influxDB(config); nil != err {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body, "foo ") || !strings.Contains(body, "bar ") {
+		t.Fatal(body)
+	}
+	c.Inc(1)
+	body = ""
+	if err := influxDB(config); nil != err {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body, "foo count=1i") || strings.Contains(body, "bar ") {
+		t.Fatal(body)
+	}
+	body = ""
+	if err := influxDB(config); nil != err {
+		t.Fatal(err)
+	}
+	if "" != body {
+		t.Fatal(body)
+	}
+}
+
+func TestInfluxDBOnlyChangedPrefixedMerged(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	a := NewRegistry()
+	c := NewRegisteredCounter("a.foo", a)
+	NewRegisteredGauge("a.bar", a).Update(47)
+	r := NewPrefixedChildRegistry(MergedRegistry(a), "a.")
+	config := &InfluxDBConfig{URL: server.URL, Registry: r, OnlyChanged: true}
+	if err := influxDB(config); nil != err {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body, "a.foo ") || !strings.Contains(body, "a.bar ") {
+		t.Fatal(body)
+	}
+	c.Inc(1)
+	body = ""
+	if err := influxDB(config); nil != err {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body, "a.foo count=1i") || strings.Contains(body, "a.bar ") {
+		t.Fatal(body)
+	}
+}
+
+func TestInfluxDBUnits(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	r := NewRegistry().(*StandardRegistry)
+	p := r.Scope("app")
+	NewRegisteredGauge("heap", p).Update(47)
+	p.SetUnit("heap", "bytes")
+	NewRegisteredCounter("requests", r)
+	NewRegisteredTimer("latency", r).Update(time.Millisecond)
+	NewRegisteredTimer("uptime", r).Update(time.Second)
+	r.SetUnit("uptime", "seconds")
+	config := InfluxDBConfig{URL: server.URL, Registry: p, DurationUnit: time.Millisecond, Units: true}
+	if err := InfluxDBOnce(config); nil != err {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body, "app.heap,unit=bytes value=47i ") {
+		t.Fatal(body)
+	}
+	config.Registry = r
+	if err := InfluxDBOnce(config); nil != err {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"requests count=0i ", "latency,unit=ms count=1i,min=1,", "uptime,unit=seconds count=1i,min=1000,"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("%s not in %q\n", s, body)
+		}
+	}
+	config.Units = false
+	if err := InfluxDBOnce(config); nil != err {
+		t.Fatal(err)
+	}
+	if strings.Contains(body, "unit=") {
+		t.Fatal(body)
+	}
+}
+
+func TestInfluxDBTickRecoversPanic(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(b))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	r := NewRegistry()
+	NewRegisteredFunctionalGauge("foo", r, panicOnce(47))
+	logger := &recordingLogger{}
+	config := &InfluxDBConfig{URL: server.URL, Registry: r, Logger: logger}
+	influxDBTick(config)
+	if lines := logger.Lines(); 1 != len(lines) || "panic during flush: nil metric" != lines[0] {
+		t.Fatal(lines)
+	}
+	influxDBTick(config)
+	if 1 != len(bodies) || !strings.HasPrefix(bodies[0], "foo value=47i ") {
+		t.Fatal(bodies)
+	}
+	if lines := logger.Lines(); 1 != len(lines) {
+		t.Fatal(lines)
+	}
+}
+
+func TestInfluxDBWithConfigFlushNow(t *testing.T) {
+	ch := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		ch <- string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	r := NewRegistry()
+	c := NewRegisteredCounter("foo", r)
+	c.Inc(47)
+	flush := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		InfluxDBWithConfig(InfluxDBConfig{URL: server.URL, Registry: r, FlushInterval: time.Hour, FlushNow: flush})
+		close(done)
+	}()
+	flush <- struct{}{}
+	if body := <-ch; !strings.HasPrefix(body, "foo count=47i ") {
+		t.Fatal(body)
+	}
+	c.Inc(1)
+	close(flush)
+	<-done
+	if body := <-ch; !strings.HasPrefix(body, "foo count=48i ") {
+		t.Fatal(body)
+	}
+}
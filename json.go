@@ -1,15 +1,20 @@
 package metrics
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"math"
+	"sync/atomic"
 	"time"
 )
 
 // MarshalJSON returns a byte slice containing a JSON representation of all
 // the metrics in the Registry.
 func (r *StandardRegistry) MarshalJSON() ([]byte, error) {
-	return json.Marshal(r.GetAll())
+	return json.Marshal(finiteJSON(r.GetAll()))
 }
 
 // WriteJSON writes metrics from the given registry  periodically to the
@@ -27,5 +32,469 @@ func WriteJSONOnce(r Registry, w io.Writer) {
 }
 
 func (p *PrefixedRegistry) MarshalJSON() ([]byte, error) {
-	return json.Marshal(p.GetAll())
+	return json.Marshal(finiteJSON(p.GetAll()))
+}
+
+func (r *mergedRegistry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(finiteJSON(r.GetAll()))
+}
+
+// MarshalOptions controls the JSON representation produced by
+// MarshalRegistry.
+type MarshalOptions struct {
+	// DurationUnit, if not zero, is the unit in which Timer durations are
+	// written, which must be one of time.Nanosecond, time.Microsecond,
+	// time.Millisecond or time.Second.  Timers' min, max, mean, stddev and
+	// percentiles are then written as integers rounded to the unit, their
+	// variance is written in the square of the unit, and a "_unit" field of
+	// "ns", "us", "ms" or "s" names it.  If zero, durations are written in
+	// nanoseconds as by MarshalJSON.
+	DurationUnit time.Duration
+
+	// Verbose, if true, additionally writes the state of each of the moving
+	// averages of Meters and Timers which use a StandardMeter, for
+	// diagnosing their rates: "1m.ewma.rate", "1m.ewma.uncounted" and
+	// "1m.ewma.initialized", and the same for "5m" and "15m".  An EWMA's
+	// rate is zero until the first tick initializes it, and its uncounted
+	// events are those marked since the last tick.
+	Verbose bool
+
+	// Values, if true, additionally writes the values in the sample of each
+	// Histogram and Timer as "values", for offline analysis.  Histograms
+	// and Timers restored by RegistryFromJSON ignore them.  Timer values are
+	// written in DurationUnit, rounded, if it is not zero.
+	Values bool
+}
+
+// MarshalVerbose returns a JSON representation of all the metrics in the
+// given registry including the state of their moving averages.  See
+// MarshalOptions.Verbose.
+func MarshalVerbose(r Registry) ([]byte, error) {
+	return MarshalRegistry(r, MarshalOptions{Verbose: true})
+}
+
+var durationUnitNames = map[time.Duration]string{
+	time.Nanosecond:  "ns",
+	time.Microsecond: "us",
+	time.Millisecond: "ms",
+	time.Second:      "s",
+}
+
+// MarshalRegistry returns a JSON representation of all the metrics in the
+// given registry, like MarshalJSON but controlled by the given options.
+func MarshalRegistry(r Registry, opts MarshalOptions) ([]byte, error) {
+	data := r.GetAll()
+	if 0 != opts.DurationUnit {
+		unit, ok := durationUnitNames[opts.DurationUnit]
+		if !ok {
+			return nil, fmt.Errorf("unsupported duration unit %v", opts.DurationUnit)
+		}
+		du := float64(opts.DurationUnit)
+		r.Each(func(name string, i interface{}) {
+			metric, ok := i.(Timer)
+			if !ok {
+				return
+			}
+			values, ok := data[name]
+			if !ok {
+				return
+			}
+			t := metric.Snapshot()
+			ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+			duration := func(v float64) int64 { return int64(math.Round(v / du)) }
+			values["_unit"] = unit
+			values["count"] = t.Count()
+			values["min"] = int64(time.Duration(t.Min()).Round(opts.DurationUnit) / opts.DurationUnit)
+			values["max"] = int64(time.Duration(t.Max()).Round(opts.DurationUnit) / opts.DurationUnit)
+			values["mean"] = duration(t.Mean())
+			values["stddev"] = duration(t.StdDev())
+			values["variance"] = t.Variance() / (du * du)
+			values["median"] = duration(ps[0])
+			values["75%"] = duration(ps[1])
+			values["95%"] = duration(ps[2])
+			values["99%"] = duration(ps[3])
+			values["99.9%"] = duration(ps[4])
+			values["1m.rate"] = t.Rate1()
+			values["5m.rate"] = t.Rate5()
+			values["15m.rate"] = t.Rate15()
+			values["mean.rate"] = t.RateMean()
+		})
+	}
+	if opts.Verbose {
+		r.Each(func(name string, i interface{}) {
+			m := standardMeterOf(i)
+			values, ok := data[name]
+			if nil == m || !ok {
+				return
+			}
+			for _, w := range []struct {
+				name string
+				ewma EWMA
+			}{{"1m", m.a1}, {"5m", m.a5}, {"15m", m.a15}} {
+				a, ok := w.ewma.(*StandardEWMA)
+				if !ok {
+					continue
+				}
+				values[w.name+".ewma.rate"] = a.Rate()
+				values[w.name+".ewma.uncounted"] = atomic.LoadInt64(&a.uncounted)
+				values[w.name+".ewma.initialized"] = 1 == atomic.LoadUint32(&a.init)
+			}
+		})
+	}
+	if opts.Values {
+		r.Each(func(name string, i interface{}) {
+			values, ok := data[name]
+			if !ok {
+				return
+			}
+			switch metric := i.(type) {
+			case Histogram:
+				values["values"] = metric.Sample().Values()
+			case Timer:
+				s, ok := metric.Snapshot().(interface {
+					Values() []int64
+				})
+				if !ok {
+					return
+				}
+				vs := s.Values()
+				if 0 != opts.DurationUnit {
+					for j, v := range vs {
+						vs[j] = int64(time.Duration(v).Round(opts.DurationUnit) / opts.DurationUnit)
+					}
+				}
+				values["values"] = vs
+			}
+		})
+	}
+	return json.Marshal(finiteJSON(data))
+}
+
+// standardMeterOf returns the StandardMeter which is or underlies the given
+// Meter or Timer, or nil if there is none.
+func standardMeterOf(i interface{}) *StandardMeter {
+	var m Meter
+	switch metric := i.(type) {
+	case Meter:
+		m = metric
+	case *StandardTimer:
+		m = metric.meter
+	case *LockFreeTimer:
+		m = metric.meter
+	case *SampledTimer:
+		m = metric.meter
+	}
+	sm, _ := m.(*StandardMeter)
+	return sm
+}
+
+// finiteJSON replaces the NaN and infinite values in data, which JSON cannot
+// represent, with null.
+func finiteJSON(data map[string]map[string]interface{}) map[string]map[string]interface{} {
+	for _, values := range data {
+		for k, v := range values {
+			if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+				values[k] = nil
+			}
+		}
+	}
+	return data
+}
+
+// RegistryFromJSON constructs a new registry from the JSON representation
+// produced by MarshalJSON.  See StandardRegistry.UnmarshalJSON.
+func RegistryFromJSON(data []byte) (Registry, error) {
+	r := NewRegistry().(*StandardRegistry)
+	if err := r.UnmarshalJSON(data); nil != err {
+		return nil, err
+	}
+	return r, nil
+}
+
+// UnmarshalJSON registers the metrics in the JSON representation produced
+// by MarshalJSON.  Counters, DecimalCounters, Gauges and GaugeFloat64s are
+// restored as plain value-holding metrics; a counter or gauge whose value is
+// integral is restored as a Counter or Gauge and any other as a
+// DecimalCounter or GaugeFloat64.  Healthchecks are restored with
+// their error and are not re-checked.  Values marshaled as null because
+// they were NaN or infinite are restored as NaN.
+//
+// Histograms, Meters and Timers are restored as best-effort static
+// snapshots, since their samples and decay state cannot be recovered: they
+// report the marshaled statistics and answer Percentile with the nearest
+// marshaled percentile.  Durations written in another unit by MarshalRegistry
+// are converted back to nanoseconds.
+func (r *StandardRegistry) UnmarshalJSON(data []byte) error {
+	var all map[string]map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&all); nil != err {
+		return err
+	}
+	for name, values := range all {
+		metric, err := unmarshalMetric(values)
+		if nil != err {
+			return fmt.Errorf("metric %s: %v", name, err)
+		}
+		if err := r.Register(name, metric); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func unmarshalMetric(values map[string]interface{}) (interface{}, error) {
+	if e, ok := values["error"]; ok {
+		h := &StandardHealthcheck{f: func(Healthcheck) {}}
+		if s, ok := e.(string); ok {
+			h.err = errors.New(s)
+		}
+		return h, nil
+	}
+	if v, ok := values["value"]; ok && nil == v {
+		g := NewGaugeFloat64()
+		g.Update(math.NaN())
+		return g, nil
+	}
+	if v, ok := values["value"].(json.Number); ok {
+		if i, err := v.Int64(); nil == err {
+			g := NewGauge()
+			g.Update(i)
+			return g, nil
+		}
+		f, err := v.Float64()
+		if nil != err {
+			return nil, err
+		}
+		g := NewGaugeFloat64()
+		g.Update(f)
+		return g, nil
+	}
+
+	var err error
+	number := func(key string) float64 {
+		if v, present := values[key]; present && nil == v {
+			return math.NaN()
+		}
+		v, ok := values[key].(json.Number)
+		if !ok {
+			if nil == err {
+				err = fmt.Errorf("missing %s", key)
+			}
+			return 0
+		}
+		f, e := v.Float64()
+		if nil != e && nil == err {
+			err = e
+		}
+		return f
+	}
+	integer := func(key string) int64 {
+		if v, ok := values[key].(json.Number); ok {
+			if i, e := v.Int64(); nil == e {
+				return i
+			}
+		}
+		return int64(number(key))
+	}
+	_, hasMin := values["min"]
+	_, hasRate := values["1m.rate"]
+	var metric interface{}
+	switch {
+	case hasMin:
+		unit := time.Nanosecond
+		if name, ok := values["_unit"]; ok {
+			unit = 0
+			for u, n := range durationUnitNames {
+				if n == name {
+					unit = u
+				}
+			}
+			if 0 == unit {
+				return nil, fmt.Errorf("unsupported duration unit %v", name)
+			}
+		}
+		du := float64(unit)
+		h := NewStaticHistogram(
+			integer("count"),
+			integer("min")*int64(unit),
+			integer("max")*int64(unit),
+			number("mean")*du,
+			number("stddev")*du,
+			[]float64{0.5, 0.75, 0.95, 0.99, 0.999},
+			[]float64{
+				number("median") * du,
+				number("75%") * du,
+				number("95%") * du,
+				number("99%") * du,
+				number("99.9%") * du,
+			},
+		)
+		metric = h
+		if hasRate {
+			metric = NewStaticTimer(h, unmarshalMeterSnapshot(h.Count(), number))
+		}
+	case hasRate:
+		m := unmarshalMeterSnapshot(integer("count"), number)
+		m.rateMax = math.Float64bits(number("max.rate"))
+		m.rateMin = math.Float64bits(number("min.rate"))
+		metric = m
+	default:
+		if count, ok := values["count"].(json.Number); ok {
+			if _, e := count.Int64(); nil != e {
+				c := NewDecimalCounter()
+				c.Add(number("count"))
+				metric = c
+				break
+			}
+		}
+		c := NewCounter()
+		c.Inc(integer("count"))
+		metric = c
+	}
+	if nil != err {
+		return nil, err
+	}
+	return metric, nil
+}
+
+func unmarshalMeterSnapshot(count int64, number func(string) float64) *MeterSnapshot {
+	return NewStaticMeter(count, number("1m.rate"), number("5m.rate"), number("15m.rate"), number("mean.rate"), 0, 0)
+}
+
+// NewStaticHistogram returns a read-only Histogram which reports the given
+// statistics and answers Percentile with the score of the nearest of the
+// given percentiles, such as to restore a marshaled Histogram.
+func NewStaticHistogram(count, min, max int64, mean, stdDev float64, ps, scores []float64) Histogram {
+	return &staticHistogram{
+		count:  count,
+		max:    max,
+		mean:   mean,
+		min:    min,
+		stdDev: stdDev,
+		ps:     append([]float64(nil), ps...),
+		scores: append([]float64(nil), scores...),
+	}
+}
+
+// NewStaticMeter returns a read-only MeterSnapshot of the given count and
+// rates, such as to restore a marshaled Meter.
+func NewStaticMeter(count int64, rate1, rate5, rate15, rateMean, rateMax, rateMin float64) *MeterSnapshot {
+	return &MeterSnapshot{
+		count:    count,
+		rate1:    math.Float64bits(rate1),
+		rate5:    math.Float64bits(rate5),
+		rate15:   math.Float64bits(rate15),
+		rateMean: math.Float64bits(rateMean),
+		rateMax:  math.Float64bits(rateMax),
+		rateMin:  math.Float64bits(rateMin),
+	}
+}
+
+// NewStaticTimer returns a read-only Timer which reports the statistics of a
+// snapshot of the given Histogram and the rates of a snapshot of the given
+// Meter, such as to restore a marshaled Timer.
+func NewStaticTimer(h Histogram, m Meter) Timer {
+	return &staticTimer{Histogram: h.Snapshot(), meter: m.Snapshot()}
+}
+
+// staticHistogram is a read-only Histogram restored from marshaled
+// statistics.
+type staticHistogram struct {
+	count, max, min int64
+	mean, stdDev    float64
+	ps, scores      []float64
+}
+
+func (*staticHistogram) Clear() { panic("Clear called on a static histogram") }
+
+func (h *staticHistogram) Count() int64 { return h.count }
+
+func (h *staticHistogram) Max() int64 { return h.max }
+
+func (h *staticHistogram) MaxOK() (int64, bool) { return h.max, 0 != h.count }
+
+func (h *staticHistogram) Mean() float64 { return h.mean }
+
+func (h *staticHistogram) MeanOK() (float64, bool) { return h.mean, 0 != h.count }
+
+func (h *staticHistogram) Min() int64 { return h.min }
+
+func (h *staticHistogram) MinOK() (int64, bool) { return h.min, 0 != h.count }
+
+// Percentile returns the marshaled percentile nearest to p, or zero if there
+// are none.
+func (h *staticHistogram) Percentile(p float64) float64 {
+	if 0 == len(h.ps) {
+		return 0
+	}
+	nearest := 0
+	for i := range h.ps {
+		if math.Abs(h.ps[i]-p) < math.Abs(h.ps[nearest]-p) {
+			nearest = i
+		}
+	}
+	return h.scores[nearest]
+}
+
+func (h *staticHistogram) PercentileOK(p float64) (float64, bool) {
+	return h.Percentile(p), 0 != h.count
+}
+
+func (h *staticHistogram) Percentiles(ps []float64) []float64 {
+	scores := make([]float64, len(ps))
+	for i, p := range ps {
+		scores[i] = h.Percentile(p)
+	}
+	return scores
+}
+
+func (*staticHistogram) Sample() Sample { return NilSample{} }
+
+func (h *staticHistogram) Snapshot() Histogram { return h }
+
+func (h *staticHistogram) StdDev() float64 { return h.stdDev }
+
+func (h *staticHistogram) Sum() int64 { return int64(h.mean * float64(h.count)) }
+
+func (*staticHistogram) Update(int64) { panic("Update called on a static histogram") }
+
+func (h *staticHistogram) Variance() float64 { return h.stdDev * h.stdDev }
+
+// staticTimer is a read-only Timer restored from marshaled statistics.
+type staticTimer struct {
+	Histogram
+	meter Meter
+}
+
+func (t *staticTimer) Rate1() float64 { return t.meter.Rate1() }
+
+func (t *staticTimer) Rate5() float64 { return t.meter.Rate5() }
+
+func (t *staticTimer) Rate15() float64 { return t.meter.Rate15() }
+
+func (t *staticTimer) RateMean() float64 { return t.meter.RateMean() }
+
+// MeanIn returns the marshaled mean in the given unit.  See
+// StandardTimer.MeanIn.
+func (t *staticTimer) MeanIn(unit time.Duration) float64 { return inDurationUnit(t.Mean(), unit) }
+
+func (*staticTimer) Reset() { panic("Reset called on a static timer") }
+
+func (t *staticTimer) Snapshot() Timer { return t }
+
+func (*staticTimer) Start() *TimerStopwatch { panic("Start called on a static timer") }
+
+func (*staticTimer) Stop() {}
+
+func (*staticTimer) Time(func()) { panic("Time called on a static timer") }
+
+func (*staticTimer) TimeDefer() func() { panic("TimeDefer called on a static timer") }
+
+func (*staticTimer) Update(time.Duration) { panic("Update called on a static timer") }
+
+func (*staticTimer) UpdateSince(time.Time) { panic("UpdateSince called on a static timer") }
+
+func (*staticTimer) UpdateSinceMonotonic(time.Time) {
+	panic("UpdateSinceMonotonic called on a static timer")
 }
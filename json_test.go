@@ -3,7 +3,9 @@ package metrics
 import (
 	"bytes"
 	"encoding/json"
+	"math"
 	"testing"
+	"time"
 )
 
 func TestRegistryMarshallJSON(t *testing.T) {
@@ -26,3 +28,232 @@ func TestRegistryWriteJSONOnce(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestRegistryFromJSON(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("counter", r).Inc(47)
+	NewRegisteredGauge("gauge", r).Update(23)
+	NewRegisteredGaugeFloat64("gaugefloat64", r).Update(1.5)
+	NewRegisteredDecimalCounter("decimalcounter", r).Add(2.5)
+	h := NewRegisteredHistogram("histogram", r, NewUniformSample(100))
+	timer := NewRegisteredTimer("timer", r)
+	for i := int64(1); i <= 100; i++ {
+		h.Update(i)
+		timer.Update(time.Duration(i))
+	}
+	NewRegisteredMeter("meter", r).Mark(3)
+	data, err := json.Marshal(r)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	restored, err := RegistryFromJSON(data)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if c, ok := restored.Get("counter").(Counter); !ok || 47 != c.Count() {
+		t.Fatal(restored.Get("counter"))
+	}
+	if g, ok := restored.Get("gauge").(Gauge); !ok || 23 != g.Value() {
+		t.Fatal(restored.Get("gauge"))
+	}
+	if g, ok := restored.Get("gaugefloat64").(GaugeFloat64); !ok || 1.5 != g.Value() {
+		t.Fatal(restored.Get("gaugefloat64"))
+	}
+	if c, ok := restored.Get("decimalcounter").(DecimalCounter); !ok || 2.5 != c.Count() {
+		t.Fatal(restored.Get("decimalcounter"))
+	}
+	if m, ok := restored.Get("meter").(Meter); !ok || 3 != m.Count() {
+		t.Fatal(restored.Get("meter"))
+	}
+	if rh, ok := restored.Get("histogram").(Histogram); !ok || 100 != rh.Count() || 100 != rh.Max() {
+		t.Fatal(restored.Get("histogram"))
+	} else if p := rh.Percentile(0.99); h.Percentile(0.99) != p {
+		t.Errorf("rh.Percentile(0.99): %v != %v\n", h.Percentile(0.99), p)
+	}
+	if rt, ok := restored.Get("timer").(Timer); !ok || 100 != rt.Count() || 1 != rt.Min() {
+		t.Fatal(restored.Get("timer"))
+	}
+
+	again, err := json.Marshal(restored)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Errorf("%s != %s\n", data, again)
+	}
+}
+
+func TestRegistryFromJSONInvalid(t *testing.T) {
+	if _, err := RegistryFromJSON([]byte(`{"foo":{"min":1}}`)); nil == err {
+		t.Fatal(err)
+	}
+	if _, err := RegistryFromJSON([]byte(`[]`)); nil == err {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistryFromJSONLargeCounts(t *testing.T) {
+	const n = 1<<53 + 1
+	r := NewRegistry()
+	NewRegisteredCounter("counter", r).Inc(n)
+	m := newStandardMeter()
+	r.Register("meter", m)
+	m.Mark(n)
+	b, err := json.Marshal(r)
+	if nil != err {
+		t.Fatal(err)
+	}
+	r2, err := RegistryFromJSON(b)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if count := r2.Get("counter").(Counter).Count(); n != count {
+		t.Errorf("counter Count(): %v != %v\n", int64(n), count)
+	}
+	if count := r2.Get("meter").(Meter).Count(); n != count {
+		t.Errorf("meter Count(): %v != %v\n", int64(n), count)
+	}
+}
+
+func TestRegistryMarshalJSONNonFinite(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredGaugeFloat64("nan", r).Update(math.NaN())
+	NewRegisteredGaugeFloat64("inf", r).Update(math.Inf(1))
+	b, err := json.Marshal(r)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if s := string(b); `{"inf":{"value":null},"nan":{"value":null}}` != s {
+		t.Fatal(s)
+	}
+	restored, err := RegistryFromJSON(b)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if g, ok := restored.Get("nan").(GaugeFloat64); !ok || !math.IsNaN(g.Value()) {
+		t.Fatal(restored.Get("nan"))
+	}
+}
+
+func TestMarshalRegistryDurationUnit(t *testing.T) {
+	const d = 1<<53 + 1
+	r := NewRegistry()
+	NewRegisteredTimer("foo", r).Update(d)
+	b, err := MarshalRegistry(r, MarshalOptions{DurationUnit: time.Nanosecond})
+	if nil != err {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`"_unit":"ns"`, `"max":9007199254740993`, `"min":9007199254740993`} {
+		if !bytes.Contains(b, []byte(s)) {
+			t.Fatalf("missing %s in %s", s, b)
+		}
+	}
+	r2, err := RegistryFromJSON(b)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if max := r2.Get("foo").(Timer).Max(); d != max {
+		t.Errorf("Max(): %v != %v\n", int64(d), max)
+	}
+
+	r = NewRegistry()
+	NewRegisteredTimer("foo", r).Update(1500 * time.Millisecond)
+	if b, err = MarshalRegistry(r, MarshalOptions{DurationUnit: time.Millisecond}); nil != err {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte(`"max":1500`)) || !bytes.Contains(b, []byte(`"median":1500`)) {
+		t.Fatal(string(b))
+	}
+	if r2, err = RegistryFromJSON(b); nil != err {
+		t.Fatal(err)
+	}
+	if max := r2.Get("foo").(Timer).Max(); int64(1500*time.Millisecond) != max {
+		t.Errorf("Max(): 1500000000 != %v\n", max)
+	}
+
+	if _, err := MarshalRegistry(r, MarshalOptions{DurationUnit: time.Minute}); nil == err {
+		t.Fatal(err)
+	}
+}
+
+func TestMarshalRegistryValues(t *testing.T) {
+	r := NewRegistry()
+	h := NewRegisteredHistogram("histogram", r, NewUniformSample(100))
+	h.Update(1)
+	h.Update(2)
+	tm := NewRegisteredTimer("timer", r)
+	defer tm.Stop()
+	tm.Update(1500 * time.Millisecond)
+	NewRegisteredCounter("counter", r)
+
+	b, err := MarshalRegistry(r, MarshalOptions{DurationUnit: time.Millisecond, Values: true})
+	if nil != err {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`"values":[1,2]`, `"values":[1500]`} {
+		if !bytes.Contains(b, []byte(s)) {
+			t.Fatalf("missing %s in %s", s, b)
+		}
+	}
+	if _, err := RegistryFromJSON(b); nil != err {
+		t.Fatal(err)
+	}
+
+	if b, err = MarshalRegistry(r, MarshalOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte(`"values"`)) {
+		t.Fatal(string(b))
+	}
+}
+
+func TestMarshalVerbose(t *testing.T) {
+	r := NewRegistry()
+	m := newStandardMeter() // not ticked by the arbiter mid-test
+	r.Register("meter", m)
+	m.Mark(47)
+	tm := NewRegisteredTimer("timer", r)
+	defer tm.Stop()
+	tm.Update(time.Millisecond)
+	NewRegisteredCounter("counter", r)
+
+	b, err := MarshalVerbose(r)
+	if nil != err {
+		t.Fatal(err)
+	}
+	var data map[string]map[string]interface{}
+	if err := json.Unmarshal(b, &data); nil != err {
+		t.Fatal(err)
+	}
+	if v := data["meter"]["1m.ewma.uncounted"]; 47.0 != v {
+		t.Errorf("meter 1m.ewma.uncounted: 47 != %v\n", v)
+	}
+	if v := data["meter"]["15m.ewma.initialized"]; false != v {
+		t.Errorf("meter 15m.ewma.initialized: false != %v\n", v)
+	}
+	if v := data["timer"]["5m.ewma.rate"]; 0.0 != v {
+		t.Errorf("timer 5m.ewma.rate: 0 != %v\n", v)
+	}
+	if _, ok := data["counter"]["1m.ewma.rate"]; ok {
+		t.Fatal(data["counter"])
+	}
+	if _, err := RegistryFromJSON(b); nil != err {
+		t.Fatal(err)
+	}
+
+	m.Tick()
+	if b, err = MarshalVerbose(r); nil != err {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte(`"1m.ewma.initialized":true`)) || !bytes.Contains(b, []byte(`"1m.ewma.uncounted":0`)) {
+		t.Fatal(string(b))
+	}
+
+	if b, err = json.Marshal(r); nil != err {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("ewma")) {
+		t.Fatal(string(b))
+	}
+}
@@ -0,0 +1,326 @@
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Labeled is implemented by metrics which carry a set of key/value labels in
+// addition to the name they are registered under.  Exporters which understand
+// dimensions may type-assert metrics surfaced by Registry.Each to Labeled.
+type Labeled interface {
+	Labels() map[string]string
+}
+
+// LabeledCounters are Counters which carry a base name and a set of labels
+// and hand out child counters for further label sets via With.  The count of
+// the root LabeledCounter is the sum of every child's count.
+type LabeledCounter interface {
+	Counter
+	Labeled
+	Each(func(map[string]string, Counter))
+	Name() string
+	With(map[string]string) Counter
+}
+
+// GetOrRegisterLabeledCounter returns an existing LabeledCounter or constructs
+// and registers a new StandardLabeledCounter with no labels.
+func GetOrRegisterLabeledCounter(name string, r Registry) LabeledCounter {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return r.GetOrRegister(name, func() LabeledCounter {
+		return NewLabeledCounter(name, nil)
+	}).(LabeledCounter)
+}
+
+// NewLabeledCounter constructs a new StandardLabeledCounter with the given base
+// name and labels.
+func NewLabeledCounter(name string, labels map[string]string) LabeledCounter {
+	if UseNilMetrics {
+		return NilLabeledCounter{}
+	}
+	return &StandardLabeledCounter{
+		name:     name,
+		labels:   copyLabels(labels),
+		children: make(map[string]*labeledCounterChild),
+	}
+}
+
+// NewRegisteredLabeledCounter constructs and registers a new
+// StandardLabeledCounter.
+func NewRegisteredLabeledCounter(name string, r Registry, labels map[string]string) LabeledCounter {
+	c := NewLabeledCounter(name, labels)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// NilLabeledCounter is a no-op LabeledCounter.
+type NilLabeledCounter struct{}
+
+// Clear is a no-op.
+func (NilLabeledCounter) Clear() int64 { return 0 }
+
+// Count is a no-op.
+func (NilLabeledCounter) Count() int64 { return 0 }
+
+// Dec is a no-op.
+func (NilLabeledCounter) Dec(i int64) {}
+
+// Each is a no-op.
+func (NilLabeledCounter) Each(func(map[string]string, Counter)) {}
+
+// Inc is a no-op.
+func (NilLabeledCounter) Inc(i int64) {}
+
+// Labels is a no-op.
+func (NilLabeledCounter) Labels() map[string]string { return nil }
+
+// Name is a no-op.
+func (NilLabeledCounter) Name() string { return "" }
+
+// Snapshot is a no-op.
+func (NilLabeledCounter) Snapshot() Counter { return NilCounter{} }
+
+// With is a no-op.
+func (NilLabeledCounter) With(map[string]string) Counter { return NilCounter{} }
+
+// LabeledCounterSnapshot is a read-only copy of another LabeledCounter.
+type LabeledCounterSnapshot struct {
+	count    int64
+	name     string
+	labels   map[string]string
+	children []labeledCounterSnapshotChild
+}
+
+type labeledCounterSnapshotChild struct {
+	count  int64
+	labels map[string]string
+}
+
+// Clear panics.
+func (*LabeledCounterSnapshot) Clear() int64 {
+	panic("Clear called on a LabeledCounterSnapshot")
+}
+
+// Count returns the count at the time the snapshot was taken.
+func (c *LabeledCounterSnapshot) Count() int64 { return c.count }
+
+// Dec panics.
+func (*LabeledCounterSnapshot) Dec(int64) {
+	panic("Dec called on a LabeledCounterSnapshot")
+}
+
+// Each calls the given function for each child counter at the time the
+// snapshot was taken.
+func (c *LabeledCounterSnapshot) Each(f func(map[string]string, Counter)) {
+	for _, child := range c.children {
+		f(copyLabels(child.labels), CounterSnapshot(child.count))
+	}
+}
+
+// Inc panics.
+func (*LabeledCounterSnapshot) Inc(int64) {
+	panic("Inc called on a LabeledCounterSnapshot")
+}
+
+// Labels returns a copy of the counter's labels.
+func (c *LabeledCounterSnapshot) Labels() map[string]string {
+	return copyLabels(c.labels)
+}
+
+// Name returns the counter's base name.
+func (c *LabeledCounterSnapshot) Name() string { return c.name }
+
+// Snapshot returns the snapshot.
+func (c *LabeledCounterSnapshot) Snapshot() Counter { return c }
+
+// With panics.
+func (*LabeledCounterSnapshot) With(map[string]string) Counter {
+	panic("With called on a LabeledCounterSnapshot")
+}
+
+// StandardLabeledCounter is the standard implementation of a LabeledCounter.
+// Children are kept in a single flat map keyed by their complete label set,
+// so identical label sets always resolve to the same child regardless of the
+// order in which With was called.  The root's count is its own count plus
+// its children's, summed when read, so that it cannot drift from them.
+type StandardLabeledCounter struct {
+	count    int64 // incremented through the root itself
+	name     string
+	labels   map[string]string
+	mutex    sync.RWMutex
+	children map[string]*labeledCounterChild
+}
+
+// Clear sets the counter and all of its children to zero and returns the
+// counter's previous count.  The children are cleared one at a time, so an
+// increment made concurrently is either included in the count returned or
+// left in the counter, but never lost.
+func (c *StandardLabeledCounter) Clear() int64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	count := atomic.SwapInt64(&c.count, 0)
+	for _, child := range c.children {
+		count += atomic.SwapInt64(&child.count, 0)
+	}
+	return count
+}
+
+// Count returns the current count summed across all children.
+func (c *StandardLabeledCounter) Count() int64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	count := atomic.LoadInt64(&c.count)
+	for _, child := range c.children {
+		count += atomic.LoadInt64(&child.count)
+	}
+	return count
+}
+
+// Dec decrements the counter by the given amount.
+func (c *StandardLabeledCounter) Dec(i int64) {
+	atomic.AddInt64(&c.count, -i)
+}
+
+// Each calls the given function for each child counter created by With,
+// passing a copy of the child's complete label set.
+func (c *StandardLabeledCounter) Each(f func(map[string]string, Counter)) {
+	c.mutex.RLock()
+	children := make([]*labeledCounterChild, 0, len(c.children))
+	for _, child := range c.children {
+		children = append(children, child)
+	}
+	c.mutex.RUnlock()
+	for _, child := range children {
+		f(child.Labels(), child)
+	}
+}
+
+// Inc increments the counter by the given amount.
+func (c *StandardLabeledCounter) Inc(i int64) {
+	atomic.AddInt64(&c.count, i)
+}
+
+// Labels returns a copy of the counter's labels.
+func (c *StandardLabeledCounter) Labels() map[string]string {
+	return copyLabels(c.labels)
+}
+
+// Name returns the counter's base name.
+func (c *StandardLabeledCounter) Name() string { return c.name }
+
+// Snapshot returns a read-only copy of the counter and its children.
+func (c *StandardLabeledCounter) Snapshot() Counter {
+	snapshot := &LabeledCounterSnapshot{
+		count:  c.Count(),
+		name:   c.name,
+		labels: c.Labels(),
+	}
+	c.Each(func(labels map[string]string, child Counter) {
+		snapshot.children = append(snapshot.children, labeledCounterSnapshotChild{
+			count:  child.Count(),
+			labels: labels,
+		})
+	})
+	return snapshot
+}
+
+// With returns the child counter for the union of the counter's labels and
+// the given labels, creating it if necessary.  Increments to the child are
+// also reflected in the count of the root counter.
+func (c *StandardLabeledCounter) With(labels map[string]string) Counter {
+	merged := copyLabels(c.labels)
+	for k, v := range labels {
+		merged[k] = v
+	}
+	key := encodeLabels(merged)
+
+	c.mutex.RLock()
+	child, ok := c.children[key]
+	c.mutex.RUnlock()
+	if ok {
+		return child
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if child, ok := c.children[key]; ok {
+		return child
+	}
+	child = &labeledCounterChild{labels: merged}
+	c.children[key] = child
+	return child
+}
+
+// labeledCounterChild is a Counter handed out by StandardLabeledCounter.With,
+// whose count is included in its root's.
+type labeledCounterChild struct {
+	count  int64
+	labels map[string]string
+}
+
+// Clear sets the child to zero, which also removes its contribution from the
+// root, and returns its previous count.
+func (c *labeledCounterChild) Clear() int64 {
+	return atomic.SwapInt64(&c.count, 0)
+}
+
+// Count returns the child's current count.
+func (c *labeledCounterChild) Count() int64 {
+	return atomic.LoadInt64(&c.count)
+}
+
+// Dec decrements the child, and so the root, by the given amount.
+func (c *labeledCounterChild) Dec(i int64) {
+	atomic.AddInt64(&c.count, -i)
+}
+
+// Inc increments the child, and so the root, by the given amount.
+func (c *labeledCounterChild) Inc(i int64) {
+	atomic.AddInt64(&c.count, i)
+}
+
+// Labels returns a copy of the child's complete label set.
+func (c *labeledCounterChild) Labels() map[string]string {
+	return copyLabels(c.labels)
+}
+
+// Snapshot returns a read-only copy of the child.
+func (c *labeledCounterChild) Snapshot() Counter {
+	return CounterSnapshot(c.Count())
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	c := make(map[string]string, len(labels))
+	for k, v := range labels {
+		c[k] = v
+	}
+	return c
+}
+
+// encodeLabels returns a canonical string for a label set, with keys sorted
+// so that equal sets encode identically.  Each key and value is preceded by
+// its length so that no choice of characters in them can make two different
+// sets encode identically.
+func encodeLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var key []byte
+	for _, k := range keys {
+		for _, s := range []string{k, labels[k]} {
+			key = strconv.AppendInt(key, int64(len(s)), 10)
+			key = append(key, ':')
+			key = append(key, s...)
+		}
+	}
+	return string(key)
+}
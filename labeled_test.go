@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+)
+
+func BenchmarkLabeledCounterWith(b *testing.B) {
+	c := NewLabeledCounter("foo", nil)
+	labels := map[string]string{"code": "200"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.With(labels).Inc(1)
+	}
+}
+
+func TestLabeledCounterWith(t *testing.T) {
+	c := NewLabeledCounter("requests", map[string]string{"host": "a"})
+	c.With(map[string]string{"code": "200"}).Inc(3)
+	c.With(map[string]string{"code": "500"}).Inc(1)
+	if count := c.Count(); 4 != count {
+		t.Errorf("c.Count(): 4 != %v\n", count)
+	}
+	if count := c.With(map[string]string{"code": "200"}).Count(); 3 != count {
+		t.Errorf("c.With(200).Count(): 3 != %v\n", count)
+	}
+}
+
+func TestLabeledCounterWithSameLabels(t *testing.T) {
+	c := NewLabeledCounter("requests", nil)
+	a := c.With(map[string]string{"code": "200", "method": "GET"})
+	b := c.With(map[string]string{"method": "GET", "code": "200"})
+	if a != b {
+		t.Fatal("identical label sets returned different counters")
+	}
+}
+
+func TestLabeledCounterWithAmbiguousLabels(t *testing.T) {
+	c := NewLabeledCounter("requests", nil)
+	a := c.With(map[string]string{"a=": "b"})
+	b := c.With(map[string]string{"a": "=b"})
+	d := c.With(map[string]string{"a": "b\x00c=d"})
+	e := c.With(map[string]string{"a": "b", "c": "d"})
+	if a == b || d == e {
+		t.Fatal("different label sets returned the same counter")
+	}
+}
+
+func TestLabeledCounterEach(t *testing.T) {
+	c := NewLabeledCounter("requests", map[string]string{"host": "a"})
+	c.With(map[string]string{"code": "200"}).Inc(1)
+	i := 0
+	c.Each(func(labels map[string]string, child Counter) {
+		i++
+		if "a" != labels["host"] || "200" != labels["code"] {
+			t.Fatal(labels)
+		}
+		if 1 != child.Count() {
+			t.Fatal(child.Count())
+		}
+	})
+	if 1 != i {
+		t.Fatal(i)
+	}
+}
+
+func TestLabeledCounterClear(t *testing.T) {
+	c := NewLabeledCounter("requests", nil)
+	a := c.With(map[string]string{"code": "200"})
+	b := c.With(map[string]string{"code": "500"})
+	a.Inc(2)
+	b.Inc(3)
+	if count := a.Clear(); 2 != count {
+		t.Errorf("a.Clear(): 2 != %v\n", count)
+	}
+	if count := c.Count(); 3 != count {
+		t.Errorf("c.Count(): 3 != %v\n", count)
+	}
+	c.Clear()
+	if count := b.Count(); 0 != count {
+		t.Errorf("b.Count(): 0 != %v\n", count)
+	}
+}
+
+func TestLabeledCounterClearConcurrent(t *testing.T) {
+	c := NewLabeledCounter("requests", nil)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(code string) {
+			defer wg.Done()
+			child := c.With(map[string]string{"code": code})
+			for j := 0; j < 1000; j++ {
+				child.Inc(1)
+			}
+		}(string(rune('a' + i)))
+	}
+	var cleared int64
+	for i := 0; i < 100; i++ {
+		cleared += c.Clear()
+	}
+	wg.Wait()
+	if count := cleared + c.Count(); 4000 != count {
+		t.Errorf("cleared + c.Count(): 4000 != %v\n", count)
+	}
+	var sum int64
+	c.Each(func(_ map[string]string, child Counter) {
+		sum += child.Count()
+	})
+	if count := c.Count(); sum != count {
+		t.Errorf("c.Count(): %v != %v\n", sum, count)
+	}
+}
+
+func TestLabeledCounterRegistry(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredLabeledCounter("foo", r, map[string]string{"host": "a"}).With(nil).Inc(47)
+	r.Each(func(name string, i interface{}) {
+		l, ok := i.(Labeled)
+		if !ok {
+			t.Fatal(i)
+		}
+		if "a" != l.Labels()["host"] {
+			t.Fatal(l.Labels())
+		}
+	})
+	if c := GetOrRegisterLabeledCounter("foo", r); 47 != c.Count() {
+		t.Fatal(c)
+	}
+}
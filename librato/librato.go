@@ -125,10 +125,11 @@ func (self *Reporter) BuildRequest(now time.Time, r metrics.Registry) (snapshot
 				measurement[Sum] = float64(s.Sum())
 				measurement[SumSquares] = sumSquares(s)
 				gauges[0] = measurement
+				ps := s.Percentiles(self.Percentiles)
 				for i, p := range self.Percentiles {
 					gauges[i+1] = Measurement{
 						Name:   fmt.Sprintf("%s.%.2f", measurement[Name], p),
-						Value:  s.Percentile(p),
+						Value:  ps[i],
 						Period: measurement[Period],
 					}
 				}
@@ -187,10 +188,11 @@ func (self *Reporter) BuildRequest(now time.Time, r metrics.Registry) (snapshot
 					Period:     int64(self.Interval.Seconds()),
 					Attributes: self.TimerAttributes,
 				}
+				ps := m.Percentiles(self.Percentiles)
 				for i, p := range self.Percentiles {
 					gauges[i+1] = Measurement{
 						Name:       fmt.Sprintf("%s.timer.%2.0f", name, p*100),
-						Value:      m.Percentile(p),
+						Value:      ps[i],
 						Period:     int64(self.Interval.Seconds()),
 						Attributes: self.TimerAttributes,
 					}
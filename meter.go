@@ -8,18 +8,37 @@ import (
 )
 
 // Meters count events to produce exponentially-weighted moving average rates
-// at one-, five-, and fifteen-minutes and a mean rate.
+// at one-, five-, and fifteen-minutes and a mean rate.  RateMax and RateMin
+// are the highest and lowest one-minute rates seen on any tick, and are zero
+// until the meter's first tick.
+//
+// Stop releases the resources held by a meter, such as its membership in the
+// shared tick arbiter, so that it may be garbage collected.  Registry's
+// Unregister calls Stop on any metric implementing Stoppable.  Using a meter
+// after Stop is undefined.
 type Meter interface {
 	Count() int64
 	Mark(int64)
 	Rate1() float64
 	Rate5() float64
 	Rate15() float64
+	RateMax() float64
 	RateMean() float64
+	RateMin() float64
 	Snapshot() Meter
 	Stop()
 }
 
+// GetMeter returns the Meter registered under the given name, or nil if no
+// metric is registered under the name or the registered metric is not a Meter.
+func GetMeter(name string, r Registry) Meter {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	m, _ := r.Get(name).(Meter)
+	return m
+}
+
 // GetOrRegisterMeter returns an existing Meter or constructs and registers a
 // new StandardMeter.
 // Be sure to unregister the meter from the registry once it is of no use to
@@ -65,6 +84,7 @@ func NewRegisteredMeter(name string, r Registry) Meter {
 type MeterSnapshot struct {
 	count                          int64
 	rate1, rate5, rate15, rateMean uint64
+	rateMax, rateMin               uint64
 }
 
 // Count returns the count of events at the time the snapshot was taken.
@@ -87,10 +107,18 @@ func (m *MeterSnapshot) Rate5() float64 { return math.Float64frombits(m.rate5) }
 // at the time the snapshot was taken.
 func (m *MeterSnapshot) Rate15() float64 { return math.Float64frombits(m.rate15) }
 
+// RateMax returns the highest one-minute rate seen at the time the snapshot
+// was taken.
+func (m *MeterSnapshot) RateMax() float64 { return math.Float64frombits(m.rateMax) }
+
 // RateMean returns the meter's mean rate of events per second at the time the
 // snapshot was taken.
 func (m *MeterSnapshot) RateMean() float64 { return math.Float64frombits(m.rateMean) }
 
+// RateMin returns the lowest one-minute rate seen at the time the snapshot
+// was taken.
+func (m *MeterSnapshot) RateMin() float64 { return math.Float64frombits(m.rateMin) }
+
 // Snapshot returns the snapshot.
 func (m *MeterSnapshot) Snapshot() Meter { return m }
 
@@ -115,9 +143,15 @@ func (NilMeter) Rate5() float64 { return 0.0 }
 // Rate15is a no-op.
 func (NilMeter) Rate15() float64 { return 0.0 }
 
+// RateMax is a no-op.
+func (NilMeter) RateMax() float64 { return 0.0 }
+
 // RateMean is a no-op.
 func (NilMeter) RateMean() float64 { return 0.0 }
 
+// RateMin is a no-op.
+func (NilMeter) RateMin() float64 { return 0.0 }
+
 // Snapshot is a no-op.
 func (NilMeter) Snapshot() Meter { return NilMeter{} }
 
@@ -126,23 +160,27 @@ func (NilMeter) Stop() {}
 
 // StandardMeter is the standard implementation of a Meter.
 type StandardMeter struct {
+	startTime   int64 // /!\ UnixNano, accessed atomically so must be first to ensure 64-bit alignment
 	snapshot    *MeterSnapshot
 	a1, a5, a15 EWMA
-	startTime   time.Time
 	stopped     uint32
+	ticked      uint32
+	tickMutex   sync.Mutex
 }
 
 func newStandardMeter() *StandardMeter {
 	return &StandardMeter{
+		startTime: time.Now().UnixNano(),
 		snapshot:  &MeterSnapshot{},
 		a1:        NewEWMA1(),
 		a5:        NewEWMA5(),
 		a15:       NewEWMA15(),
-		startTime: time.Now(),
 	}
 }
 
-// Stop stops the meter, Mark() will be a no-op if you use it after being stopped.
+// Stop stops the meter and removes it from the tick arbiter.  Using a meter
+// after Stop is undefined; the StandardMeter currently ignores Mark and its
+// rates stop decaying.
 func (m *StandardMeter) Stop() {
 	if atomic.CompareAndSwapUint32(&m.stopped, 0, 1) {
 		arbiter.Lock()
@@ -185,11 +223,41 @@ func (m *StandardMeter) Rate15() float64 {
 	return math.Float64frombits(atomic.LoadUint64(&m.snapshot.rate15))
 }
 
+// RateMax returns the highest one-minute rate seen on any tick.
+func (m *StandardMeter) RateMax() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&m.snapshot.rateMax))
+}
+
 // RateMean returns the meter's mean rate of events per second.
 func (m *StandardMeter) RateMean() float64 {
 	return math.Float64frombits(atomic.LoadUint64(&m.snapshot.rateMean))
 }
 
+// RateMin returns the lowest one-minute rate seen on any tick.
+func (m *StandardMeter) RateMin() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&m.snapshot.rateMin))
+}
+
+// ResetCount zeroes the number of events recorded and restarts the clock
+// used for the mean rate, leaving the moving averages untouched.  Count then
+// reflects the events since the last reset, so a meter rotated daily reports
+// daily totals alongside continuous rates.
+func (m *StandardMeter) ResetCount() {
+	atomic.StoreInt64(&m.snapshot.count, 0)
+	atomic.StoreInt64(&m.startTime, time.Now().UnixNano())
+	m.updateSnapshot()
+}
+
+// Tick folds the events marked since the last tick into the moving averages
+// immediately, as the shared ticker does every five seconds, and updates the
+// rates.  It is intended for tests, which may mark events, call Tick and
+// assert the rates without sleeping, and for advanced use.  Each tick is
+// taken to cover five seconds, so ticking more often than the shared ticker
+// makes the rates change faster than in real time.
+func (m *StandardMeter) Tick() {
+	m.tick()
+}
+
 // Snapshot returns a read-only copy of the meter.
 func (m *StandardMeter) Snapshot() Meter {
 	copiedSnapshot := MeterSnapshot{
@@ -198,6 +266,8 @@ func (m *StandardMeter) Snapshot() Meter {
 		rate5:    atomic.LoadUint64(&m.snapshot.rate5),
 		rate15:   atomic.LoadUint64(&m.snapshot.rate15),
 		rateMean: atomic.LoadUint64(&m.snapshot.rateMean),
+		rateMax:  atomic.LoadUint64(&m.snapshot.rateMax),
+		rateMin:  atomic.LoadUint64(&m.snapshot.rateMin),
 	}
 	return &copiedSnapshot
 }
@@ -206,7 +276,8 @@ func (m *StandardMeter) updateSnapshot() {
 	rate1 := math.Float64bits(m.a1.Rate())
 	rate5 := math.Float64bits(m.a5.Rate())
 	rate15 := math.Float64bits(m.a15.Rate())
-	rateMean := math.Float64bits(float64(m.Count()) / time.Since(m.startTime).Seconds())
+	elapsed := time.Duration(time.Now().UnixNano() - atomic.LoadInt64(&m.startTime))
+	rateMean := math.Float64bits(float64(m.Count()) / elapsed.Seconds())
 
 	atomic.StoreUint64(&m.snapshot.rate1, rate1)
 	atomic.StoreUint64(&m.snapshot.rate5, rate5)
@@ -214,11 +285,48 @@ func (m *StandardMeter) updateSnapshot() {
 	atomic.StoreUint64(&m.snapshot.rateMean, rateMean)
 }
 
+// reset zeroes the meter's count and moving averages and restarts the clock
+// used for its mean rate.
+func (m *StandardMeter) reset() {
+	atomic.StoreInt64(&m.snapshot.count, 0)
+	atomic.StoreInt64(&m.startTime, time.Now().UnixNano())
+	atomic.StoreUint32(&m.ticked, 0)
+	atomic.StoreUint64(&m.snapshot.rateMax, 0)
+	atomic.StoreUint64(&m.snapshot.rateMin, 0)
+	for _, a := range []EWMA{m.a1, m.a5, m.a15} {
+		if a, ok := a.(*StandardEWMA); ok {
+			a.reset()
+		}
+	}
+	m.updateSnapshot()
+}
+
 func (m *StandardMeter) tick() {
+	m.tickMutex.Lock()
+	defer m.tickMutex.Unlock()
 	m.a1.Tick()
 	m.a5.Tick()
 	m.a15.Tick()
 	m.updateSnapshot()
+	m.updateRateBounds()
+}
+
+// updateRateBounds folds the current one-minute rate into the high- and
+// low-water marks.  It is only called from tick, which holds tickMutex, so
+// the bounds are never written concurrently except by reset.
+func (m *StandardMeter) updateRateBounds() {
+	rate1 := m.a1.Rate()
+	if atomic.CompareAndSwapUint32(&m.ticked, 0, 1) {
+		atomic.StoreUint64(&m.snapshot.rateMax, math.Float64bits(rate1))
+		atomic.StoreUint64(&m.snapshot.rateMin, math.Float64bits(rate1))
+		return
+	}
+	if rate1 > m.RateMax() {
+		atomic.StoreUint64(&m.snapshot.rateMax, math.Float64bits(rate1))
+	}
+	if rate1 < m.RateMin() {
+		atomic.StoreUint64(&m.snapshot.rateMin, math.Float64bits(rate1))
+	}
 }
 
 // meterArbiter ticks meters every 5s from a single goroutine.
@@ -0,0 +1,212 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CustomMeters are Meters whose moving averages are configured by the caller
+// rather than fixed at one, five and fifteen minutes.  Rates returns the
+// moving average rates of events per second in the order of the alphas the
+// meter was constructed with.
+type CustomMeter interface {
+	Meter
+	Rates() []float64
+}
+
+// NewCustomMeter constructs a new StandardCustomMeter with one moving average
+// for each of the given alphas, ticked every tick, and launches a goroutine.
+// EWMAAlpha computes the alpha which averages over a given window, so
+//
+//	NewCustomMeter([]float64{
+//		metrics.EWMAAlpha(10*time.Second, time.Second),
+//		metrics.EWMAAlpha(30*time.Second, time.Second),
+//	}, time.Second)
+//
+// constructs a meter with ten- and thirty-second rates.
+// Be sure to call Stop() once the meter is of no use to allow for garbage collection.
+func NewCustomMeter(alphas []float64, tick time.Duration) CustomMeter {
+	if UseNilMetrics {
+		return NilCustomMeter{}
+	}
+	m := newStandardCustomMeter(alphas, tick)
+	go m.run(tick)
+	return m
+}
+
+// NewRegisteredCustomMeter constructs and registers a new
+// StandardCustomMeter and launches a goroutine.
+// Be sure to unregister the meter from the registry once it is of no use to
+// allow for garbage collection.
+func NewRegisteredCustomMeter(name string, r Registry, alphas []float64, tick time.Duration) CustomMeter {
+	c := NewCustomMeter(alphas, tick)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// CustomMeterSnapshot is a read-only copy of another CustomMeter.
+type CustomMeterSnapshot struct {
+	*MeterSnapshot
+	rates []float64
+}
+
+// Rates returns the moving average rates of events per second at the time
+// the snapshot was taken.
+func (m *CustomMeterSnapshot) Rates() []float64 {
+	return append([]float64(nil), m.rates...)
+}
+
+// Snapshot returns the snapshot.
+func (m *CustomMeterSnapshot) Snapshot() Meter { return m }
+
+// NilCustomMeter is a no-op CustomMeter.
+type NilCustomMeter struct {
+	NilMeter
+}
+
+// Rates is a no-op.
+func (NilCustomMeter) Rates() []float64 { return nil }
+
+// Snapshot is a no-op.
+func (NilCustomMeter) Snapshot() Meter { return NilCustomMeter{} }
+
+// StandardCustomMeter is the standard implementation of a CustomMeter.  It
+// ticks itself from its own goroutine rather than the shared five-second
+// tick arbiter.  Rate1, Rate5 and Rate15 are always zero; RateMax and
+// RateMin are the highest and lowest values of the first of its rates seen
+// on any tick.
+type StandardCustomMeter struct {
+	count     int64 // /!\ accessed atomically so must be first to ensure 64-bit alignment
+	startTime int64
+	rateMax   uint64
+	rateMin   uint64
+	ewmas     []*StandardEWMA
+	stop      chan struct{}
+	stopOnce  sync.Once
+	stopped   uint32
+	ticked    uint32
+}
+
+func newStandardCustomMeter(alphas []float64, tick time.Duration) *StandardCustomMeter {
+	m := &StandardCustomMeter{
+		startTime: time.Now().UnixNano(),
+		ewmas:     make([]*StandardEWMA, len(alphas)),
+		stop:      make(chan struct{}),
+	}
+	for i, alpha := range alphas {
+		m.ewmas[i] = &StandardEWMA{alpha: alpha, interval: tick}
+	}
+	return m
+}
+
+// Count returns the number of events recorded.
+func (m *StandardCustomMeter) Count() int64 {
+	return atomic.LoadInt64(&m.count)
+}
+
+// Mark records the occurance of n events.
+func (m *StandardCustomMeter) Mark(n int64) {
+	if atomic.LoadUint32(&m.stopped) == 1 {
+		return
+	}
+	atomic.AddInt64(&m.count, n)
+	for _, a := range m.ewmas {
+		a.Update(n)
+	}
+}
+
+// Rate1 returns zero since a StandardCustomMeter has no one-minute rate.
+func (m *StandardCustomMeter) Rate1() float64 { return 0.0 }
+
+// Rate5 returns zero since a StandardCustomMeter has no five-minute rate.
+func (m *StandardCustomMeter) Rate5() float64 { return 0.0 }
+
+// Rate15 returns zero since a StandardCustomMeter has no fifteen-minute rate.
+func (m *StandardCustomMeter) Rate15() float64 { return 0.0 }
+
+// RateMax returns the highest first rate seen on any tick.
+func (m *StandardCustomMeter) RateMax() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&m.rateMax))
+}
+
+// RateMean returns the meter's mean rate of events per second.
+func (m *StandardCustomMeter) RateMean() float64 {
+	elapsed := time.Duration(time.Now().UnixNano() - atomic.LoadInt64(&m.startTime))
+	return float64(m.Count()) / elapsed.Seconds()
+}
+
+// RateMin returns the lowest first rate seen on any tick.
+func (m *StandardCustomMeter) RateMin() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&m.rateMin))
+}
+
+// Rates returns the moving average rates of events per second in the order
+// of the alphas the meter was constructed with.
+func (m *StandardCustomMeter) Rates() []float64 {
+	rates := make([]float64, len(m.ewmas))
+	for i, a := range m.ewmas {
+		rates[i] = a.Rate()
+	}
+	return rates
+}
+
+// Snapshot returns a read-only copy of the meter.
+func (m *StandardCustomMeter) Snapshot() Meter {
+	return &CustomMeterSnapshot{
+		MeterSnapshot: &MeterSnapshot{
+			count:    m.Count(),
+			rateMean: math.Float64bits(m.RateMean()),
+			rateMax:  atomic.LoadUint64(&m.rateMax),
+			rateMin:  atomic.LoadUint64(&m.rateMin),
+		},
+		rates: m.Rates(),
+	}
+}
+
+// Stop stops the meter's goroutine.  Using a meter after Stop is undefined;
+// the StandardCustomMeter currently ignores Mark and its rates stop decaying.
+func (m *StandardCustomMeter) Stop() {
+	m.stopOnce.Do(func() {
+		atomic.StoreUint32(&m.stopped, 1)
+		close(m.stop)
+	})
+}
+
+func (m *StandardCustomMeter) run(tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.tick()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+func (m *StandardCustomMeter) tick() {
+	for _, a := range m.ewmas {
+		a.Tick()
+	}
+	if 0 == len(m.ewmas) {
+		return
+	}
+	rate := m.ewmas[0].Rate()
+	if atomic.CompareAndSwapUint32(&m.ticked, 0, 1) {
+		atomic.StoreUint64(&m.rateMax, math.Float64bits(rate))
+		atomic.StoreUint64(&m.rateMin, math.Float64bits(rate))
+		return
+	}
+	if rate > m.RateMax() {
+		atomic.StoreUint64(&m.rateMax, math.Float64bits(rate))
+	}
+	if rate < m.RateMin() {
+		atomic.StoreUint64(&m.rateMin, math.Float64bits(rate))
+	}
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func BenchmarkCustomMeter(b *testing.B) {
+	m := NewCustomMeter([]float64{EWMAAlpha(10*time.Second, time.Second)}, time.Second)
+	defer m.Stop()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Mark(1)
+	}
+}
+
+func TestEWMAAlpha(t *testing.T) {
+	if a, b := EWMAAlpha(time.Minute, 5*time.Second), 1-math.Exp(-5.0/60.0/1); a != b {
+		t.Errorf("EWMAAlpha(time.Minute, 5*time.Second): %v != %v\n", b, a)
+	}
+}
+
+func TestCustomMeterRates(t *testing.T) {
+	alphas := []float64{EWMAAlpha(10*time.Second, time.Second), EWMAAlpha(30*time.Second, time.Second)}
+	m := newStandardCustomMeter(alphas, time.Second)
+	m.Mark(10)
+	m.tick()
+	rates := m.Rates()
+	if 2 != len(rates) || 10 != rates[0] || 10 != rates[1] {
+		t.Fatal(rates)
+	}
+	m.tick()
+	rates = m.Rates()
+	for i, alpha := range alphas {
+		if expected := 10 * (1 - alpha); math.Abs(expected-rates[i]) > 1e-9 {
+			t.Errorf("m.Rates()[%d]: %v != %v\n", i, expected, rates[i])
+		}
+	}
+	if rates[0] >= rates[1] {
+		t.Errorf("shorter window decayed slower: %v\n", rates)
+	}
+	if max := m.RateMax(); 10 != max {
+		t.Errorf("m.RateMax(): 10 != %v\n", max)
+	}
+	if min := m.RateMin(); rates[0] != min {
+		t.Errorf("m.RateMin(): %v != %v\n", rates[0], min)
+	}
+}
+
+func TestCustomMeterSnapshot(t *testing.T) {
+	m := newStandardCustomMeter([]float64{EWMAAlpha(time.Minute, time.Second)}, time.Second)
+	m.Mark(5)
+	m.tick()
+	s := m.Snapshot().(CustomMeter)
+	m.Mark(5)
+	m.tick()
+	if count := s.Count(); 5 != count {
+		t.Errorf("s.Count(): 5 != %v\n", count)
+	}
+	if rates := s.Rates(); 1 != len(rates) || 5 != rates[0] {
+		t.Fatal(rates)
+	}
+}
+
+func TestCustomMeterStop(t *testing.T) {
+	r := NewRegistry()
+	m := NewRegisteredCustomMeter("foo", r, []float64{EWMAAlpha(time.Second, time.Millisecond)}, time.Millisecond)
+	m.Mark(1)
+	r.Unregister("foo")
+	m.Mark(1)
+	if count := m.Count(); 1 != count {
+		t.Errorf("m.Count(): 1 != %v\n", count)
+	}
+}
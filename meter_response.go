@@ -0,0 +1,71 @@
+package metrics
+
+// ResponseMeter bundles the Meters which together record responses: Total
+// is marked by every response and Errors by failed ones only, so that the
+// error ratio may be computed from the pair rather than kept separately.
+type ResponseMeter struct {
+	Total  Meter
+	Errors Meter
+}
+
+// NewResponseMeter constructs a new ResponseMeter with new Meters and
+// launches their goroutines.
+// Be sure to call Stop() once the meter is of no use to allow for garbage collection.
+func NewResponseMeter() *ResponseMeter {
+	return &ResponseMeter{
+		Total:  NewMeter(),
+		Errors: NewMeter(),
+	}
+}
+
+// NewRegisteredResponseMeter constructs a new ResponseMeter and registers
+// its Meters under the given name suffixed with ".total" and ".errors".
+// Be sure to unregister the meters from the registry once they are of no use
+// to allow for garbage collection.
+func NewRegisteredResponseMeter(name string, r Registry) *ResponseMeter {
+	m := NewResponseMeter()
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name+".total", m.Total)
+	r.Register(name+".errors", m.Errors)
+	return m
+}
+
+// ErrorRatio returns the fraction of responses which failed, from the
+// one-minute moving average rates, or zero if there have been none.
+func (m *ResponseMeter) ErrorRatio() float64 {
+	total := m.Total.Rate1()
+	if 0 == total {
+		return 0
+	}
+	return m.Errors.Rate1() / total
+}
+
+// MarkErr records a failed response.
+func (m *ResponseMeter) MarkErr() {
+	m.Total.Mark(1)
+	m.Errors.Mark(1)
+}
+
+// MarkOK records a successful response.
+func (m *ResponseMeter) MarkOK() {
+	m.Total.Mark(1)
+}
+
+// Stop stops both meters.
+func (m *ResponseMeter) Stop() {
+	m.Total.Stop()
+	m.Errors.Stop()
+}
+
+// Tick ticks both meters, if they support it, as StandardMeter.Tick.
+func (m *ResponseMeter) Tick() {
+	for _, meter := range []Meter{m.Total, m.Errors} {
+		if t, ok := meter.(interface {
+			Tick()
+		}); ok {
+			t.Tick()
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestResponseMeterErrorRatio(t *testing.T) {
+	r := NewRegistry()
+	m := NewRegisteredResponseMeter("http", r)
+	defer m.Stop()
+	if ratio := m.ErrorRatio(); 0 != ratio {
+		t.Errorf("m.ErrorRatio(): 0 != %v\n", ratio)
+	}
+	for i := 0; i < 3; i++ {
+		m.MarkOK()
+	}
+	m.MarkErr()
+	m.Tick()
+	if ratio := m.ErrorRatio(); math.Abs(0.25-ratio) > 1e-9 {
+		t.Errorf("m.ErrorRatio(): 0.25 != %v\n", ratio)
+	}
+	if count := r.Get("http.total").(Meter).Count(); 4 != count {
+		t.Errorf("http.total: 4 != %v\n", count)
+	}
+	if count := r.Get("http.errors").(Meter).Count(); 1 != count {
+		t.Errorf("http.errors: 1 != %v\n", count)
+	}
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import "sync/atomic"
+
+// NewSampledMeter constructs a new SampledMeter which records every rate-th
+// call to Mark, and launches a goroutine.
+// Be sure to call Stop() once the meter is of no use to allow for garbage collection.
+func NewSampledMeter(rate int) Meter {
+	if UseNilMetrics {
+		return NilMeter{}
+	}
+	if rate < 1 {
+		rate = 1
+	}
+	return &SampledMeter{rate: int64(rate), meter: NewMeter()}
+}
+
+// NewRegisteredSampledMeter constructs and registers a new SampledMeter and
+// launches a goroutine.
+// Be sure to unregister the meter from the registry once it is of no use to
+// allow for garbage collection.
+func NewRegisteredSampledMeter(name string, r Registry, rate int) Meter {
+	c := NewSampledMeter(rate)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// SampledMeter is a Meter which trades exactness for throughput under very
+// high event rates.  Only every rate-th call to Mark is recorded, scaled by
+// rate, so the other calls cost a single atomic increment instead of the
+// several atomic operations and clock read of StandardMeter.Mark.
+//
+// The count and rates are estimates.  The count lags the true count by up to
+// rate-1 calls' worth of events, and when calls mark differing numbers of
+// events the count is only correct on average, with an error which grows
+// with the variance of n and with rate.  The moving averages inherit the
+// same relative error and are burstier, since each recorded call adds rate
+// calls' worth of events at once, so choose rate well below the number of
+// calls expected per five-second tick.
+type SampledMeter struct {
+	calls int64
+	rate  int64
+	meter Meter
+}
+
+// Count returns the estimated number of events recorded.
+func (m *SampledMeter) Count() int64 { return m.meter.Count() }
+
+// Mark records the occurance of n events, if this is a sampled call.
+func (m *SampledMeter) Mark(n int64) {
+	if 0 == atomic.AddInt64(&m.calls, 1)%m.rate {
+		m.meter.Mark(n * m.rate)
+	}
+}
+
+// Rate1 returns the estimated one-minute moving average rate of events per
+// second.
+func (m *SampledMeter) Rate1() float64 { return m.meter.Rate1() }
+
+// Rate5 returns the estimated five-minute moving average rate of events per
+// second.
+func (m *SampledMeter) Rate5() float64 { return m.meter.Rate5() }
+
+// Rate15 returns the estimated fifteen-minute moving average rate of events
+// per second.
+func (m *SampledMeter) Rate15() float64 { return m.meter.Rate15() }
+
+// RateMax returns the highest estimated one-minute rate seen on any tick.
+func (m *SampledMeter) RateMax() float64 { return m.meter.RateMax() }
+
+// RateMean returns the estimated mean rate of events per second.
+func (m *SampledMeter) RateMean() float64 { return m.meter.RateMean() }
+
+// RateMin returns the lowest estimated one-minute rate seen on any tick.
+func (m *SampledMeter) RateMin() float64 { return m.meter.RateMin() }
+
+// Snapshot returns a read-only copy of the meter.
+func (m *SampledMeter) Snapshot() Meter { return m.meter.Snapshot() }
+
+// Stop stops the meter.
+func (m *SampledMeter) Stop() { m.meter.Stop() }
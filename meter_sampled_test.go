@@ -0,0 +1,56 @@
+package metrics
+
+import "testing"
+
+func BenchmarkSampledMeter(b *testing.B) {
+	m := NewSampledMeter(100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Mark(1)
+	}
+}
+
+func BenchmarkSampledMeterParallel(b *testing.B) {
+	m := NewSampledMeter(100)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			m.Mark(1)
+		}
+	})
+}
+
+func TestSampledMeter(t *testing.T) {
+	m := NewSampledMeter(10)
+	defer m.Stop()
+	for i := 0; i < 95; i++ {
+		m.Mark(1)
+	}
+	if count := m.Count(); 90 != count {
+		t.Errorf("m.Count(): 90 != %v\n", count)
+	}
+	if snapshot := m.Snapshot(); 90 != snapshot.Count() {
+		t.Errorf("snapshot.Count(): 90 != %v\n", snapshot.Count())
+	}
+}
+
+func TestSampledMeterRateOne(t *testing.T) {
+	m := NewSampledMeter(0)
+	defer m.Stop()
+	m.Mark(3)
+	if count := m.Count(); 3 != count {
+		t.Errorf("m.Count(): 3 != %v\n", count)
+	}
+}
+
+func TestSampledMeterStop(t *testing.T) {
+	l := len(arbiter.meters)
+	m := NewSampledMeter(10)
+	if len(arbiter.meters) != l+1 {
+		t.Errorf("arbiter.meters: %d != %d\n", l+1, len(arbiter.meters))
+	}
+	m.Stop()
+	if len(arbiter.meters) != l {
+		t.Errorf("arbiter.meters: %d != %d\n", l, len(arbiter.meters))
+	}
+}
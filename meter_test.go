@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"math"
 	"math/rand"
 	"sync"
 	"testing"
@@ -110,3 +111,65 @@ func TestMeterZero(t *testing.T) {
 		t.Errorf("m.Count(): 0 != %v\n", count)
 	}
 }
+
+func TestMeterRateBounds(t *testing.T) {
+	m := newStandardMeter()
+	if max, min := m.RateMax(), m.RateMin(); 0.0 != max || 0.0 != min {
+		t.Errorf("m.RateMax(), m.RateMin(): 0.0, 0.0 != %v, %v\n", max, min)
+	}
+	m.Mark(300)
+	m.tick()
+	rate1 := m.Rate1()
+	if max, min := m.RateMax(), m.RateMin(); rate1 != max || rate1 != min {
+		t.Errorf("m.RateMax(), m.RateMin(): %v, %v != %v, %v\n", rate1, rate1, max, min)
+	}
+	m.tick()
+	if max, min := m.RateMax(), m.RateMin(); rate1 != max || min >= rate1 || m.Rate1() != min {
+		t.Errorf("m.RateMax(), m.RateMin(): %v, %v != %v, %v\n", rate1, m.Rate1(), max, min)
+	}
+	m.Mark(3000)
+	m.tick()
+	if max := m.RateMax(); m.Rate1() != max {
+		t.Errorf("m.RateMax(): %v != %v\n", m.Rate1(), max)
+	}
+	if snapshot := m.Snapshot(); m.RateMax() != snapshot.RateMax() || m.RateMin() != snapshot.RateMin() {
+		t.Fatal(snapshot)
+	}
+}
+
+func TestMeterResetCount(t *testing.T) {
+	m := newStandardMeter()
+	m.Mark(10)
+	m.tick()
+	rate1, rate5, rate15 := m.Rate1(), m.Rate5(), m.Rate15()
+	m.ResetCount()
+	if count := m.Count(); 0 != count {
+		t.Errorf("m.Count(): 0 != %v\n", count)
+	}
+	if rate := m.Rate1(); rate1 != rate || 0 == rate {
+		t.Errorf("m.Rate1(): %v != %v\n", rate1, rate)
+	}
+	if m.Rate5() != rate5 || m.Rate15() != rate15 {
+		t.Errorf("m.Rate5(), m.Rate15(): %v, %v != %v, %v\n", rate5, rate15, m.Rate5(), m.Rate15())
+	}
+	m.Mark(3)
+	if count := m.Count(); 3 != count {
+		t.Errorf("m.Count(): 3 != %v\n", count)
+	}
+	if snapshot := m.Snapshot(); 3 != snapshot.Count() || rate1 != snapshot.Rate1() {
+		t.Errorf("m.Snapshot(): %v %v\n", snapshot.Count(), snapshot.Rate1())
+	}
+}
+
+func TestMeterTick(t *testing.T) {
+	m := NewMeter().(*StandardMeter)
+	defer m.Stop()
+	m.Mark(300)
+	m.Tick()
+	if rate := m.Rate1(); math.Abs(60-rate) > 1e-9 {
+		t.Errorf("m.Rate1(): 60 != %v\n", rate)
+	}
+	if rate := m.Snapshot().Rate15(); math.Abs(60-rate) > 1e-9 {
+		t.Errorf("m.Snapshot().Rate15(): 60 != %v\n", rate)
+	}
+}
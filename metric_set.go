@@ -0,0 +1,70 @@
+package metrics
+
+// MetricSet constructs a group of related metrics registered under a common
+// prefix, returning each as its own type:
+//
+//	s := NewMetricSet(r, "http")
+//	requests := s.Counter("requests") // registered as "http.requests"
+//	latency := s.Timer("latency")     // registered as "http.latency"
+//
+// Each method returns the metric already registered under the name, if
+// any, as GetOrRegister does, and panics if it is of another kind.
+type MetricSet struct {
+	r      Registry
+	prefix string
+}
+
+// NewMetricSet returns a MetricSet registering metrics in r, or in the
+// DefaultRegistry if r is nil, under the given prefix followed by
+// ScopeSeparator.  An empty prefix registers metrics under their names.
+func NewMetricSet(r Registry, prefix string) *MetricSet {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	if "" != prefix {
+		prefix += ScopeSeparator
+	}
+	return &MetricSet{r: r, prefix: prefix}
+}
+
+// Counter returns the Counter registered under the given name, registering
+// a new StandardCounter if there is none.
+func (s *MetricSet) Counter(name string) Counter {
+	return GetOrRegisterCounter(s.prefix+name, s.r)
+}
+
+// DecimalCounter returns the DecimalCounter registered under the given
+// name, registering a new StandardDecimalCounter if there is none.
+func (s *MetricSet) DecimalCounter(name string) DecimalCounter {
+	return GetOrRegisterDecimalCounter(s.prefix+name, s.r)
+}
+
+// Gauge returns the Gauge registered under the given name, registering a new
+// StandardGauge if there is none.
+func (s *MetricSet) Gauge(name string) Gauge {
+	return GetOrRegisterGauge(s.prefix+name, s.r)
+}
+
+// GaugeFloat64 returns the GaugeFloat64 registered under the given name,
+// registering a new StandardGaugeFloat64 if there is none.
+func (s *MetricSet) GaugeFloat64(name string) GaugeFloat64 {
+	return GetOrRegisterGaugeFloat64(s.prefix+name, s.r)
+}
+
+// Histogram returns the Histogram registered under the given name,
+// registering a new StandardHistogram of the given Sample if there is none.
+func (s *MetricSet) Histogram(name string, sample Sample) Histogram {
+	return GetOrRegisterHistogram(s.prefix+name, s.r, sample)
+}
+
+// Meter returns the Meter registered under the given name, registering a new
+// StandardMeter if there is none.
+func (s *MetricSet) Meter(name string) Meter {
+	return GetOrRegisterMeter(s.prefix+name, s.r)
+}
+
+// Timer returns the Timer registered under the given name, registering a new
+// StandardTimer if there is none.
+func (s *MetricSet) Timer(name string) Timer {
+	return GetOrRegisterTimer(s.prefix+name, s.r)
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import "testing"
+
+func TestMetricSet(t *testing.T) {
+	r := NewRegistry()
+	s := NewMetricSet(r, "http")
+	c := s.Counter("requests")
+	s.DecimalCounter("bytes")
+	s.Gauge("inflight")
+	s.GaugeFloat64("load")
+	s.Histogram("sizes", NewUniformSample(100))
+	s.Meter("errors")
+	tm := s.Timer("latency")
+	defer r.UnregisterAll()
+	for _, name := range []string{"requests", "bytes", "inflight", "load", "sizes", "errors", "latency"} {
+		if nil == r.Get("http."+name) {
+			t.Errorf("r.Get(http.%s): nil\n", name)
+		}
+	}
+	if r.Get("http.requests") != c || r.Get("http.latency") != tm {
+		t.Fatal(r.GetAll())
+	}
+	if s.Counter("requests") != c {
+		t.Fatal(s.Counter("requests"))
+	}
+}
+
+func TestMetricSetNoPrefix(t *testing.T) {
+	r := NewRegistry()
+	NewMetricSet(r, "").Counter("requests").Inc(47)
+	if c := GetOrRegisterCounter("requests", r); 47 != c.Count() {
+		t.Fatal(c)
+	}
+}
@@ -5,9 +5,45 @@
 // Coda Hale's original work: <https://github.com/codahale/metrics>
 package metrics
 
+import (
+	"fmt"
+	"log"
+)
+
 // UseNilMetrics is checked by the constructor functions for all of the
 // standard metrics.  If it is true, the metric returned is a stub.
 //
 // This global kill-switch helps quantify the observer effect and makes
 // for less cluttered pprof profiles.
 var UseNilMetrics bool = false
+
+// mapName returns the given name transformed by the given exporter Mapper,
+// or unchanged if the Mapper is nil.
+func mapName(mapper func(string) string, name string) string {
+	if nil == mapper {
+		return name
+	}
+	return mapper(name)
+}
+
+// recoverFlush calls an exporter's flush function, returning a panic raised
+// by it as an error so that the exporter survives to flush again at the next
+// interval.
+func recoverFlush(flush func() error) (err error) {
+	defer func() {
+		if p := recover(); nil != p {
+			err = fmt.Errorf("panic during flush: %v", p)
+		}
+	}()
+	return flush()
+}
+
+// logFlushError logs an exporter's error with the given Logger, or the
+// standard logger if it is nil.
+func logFlushError(l Logger, err error) {
+	if nil == l {
+		log.Println(err)
+	} else {
+		l.Printf("%v", err)
+	}
+}
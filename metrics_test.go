@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"log"
 	"sync"
+	"sync/atomic"
 	"testing"
 )
 
@@ -122,3 +123,47 @@ func Example() {
 	// Output: 17
 	// 1
 }
+
+// recordingLogger is a Logger which keeps what it is given to log.
+type recordingLogger struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func (l *recordingLogger) Lines() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+// panicOnce returns a function which panics the first time it is called and
+// returns v thereafter.
+func panicOnce(v int64) func() int64 {
+	var calls int32
+	return func() int64 {
+		if 1 == atomic.AddInt32(&calls, 1) {
+			panic("nil metric")
+		}
+		return v
+	}
+}
+
+func TestRecoverFlush(t *testing.T) {
+	if err := recoverFlush(func() error { panic("nil metric") }); nil == err || "panic during flush: nil metric" != err.Error() {
+		t.Fatal(err)
+	}
+	if err := recoverFlush(func() error { return nil }); nil != err {
+		t.Fatal(err)
+	}
+	l := &recordingLogger{}
+	logFlushError(l, recoverFlush(func() error { panic("nil metric") }))
+	if lines := l.Lines(); 1 != len(lines) || "panic during flush: nil metric" != lines[0] {
+		t.Fatal(lines)
+	}
+}
@@ -0,0 +1,239 @@
+// Package metricspb serializes a go-metrics registry in the compact binary
+// protobuf encoding described by metrics.proto.
+package metricspb
+
+import (
+	"errors"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// quantiles are the percentiles encoded for each Histogram and Timer, which
+// are those reported by Registry.GetAll.
+var quantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
+// MarshalProto returns the metrics in the given registry encoded as a
+// Registry message.  Like Registry.GetAll, it runs every healthcheck.
+func MarshalProto(r metrics.Registry) ([]byte, error) {
+	e := &encoder{}
+	r.Each(func(name string, i interface{}) {
+		e.message(1, func(e *encoder) {
+			e.string(1, name)
+			switch metric := i.(type) {
+			case metrics.Counter:
+				e.message(2, func(e *encoder) {
+					e.int64(1, metric.Count())
+				})
+			case metrics.DecimalCounter:
+				e.message(9, func(e *encoder) {
+					e.double(1, metric.Count())
+				})
+			case metrics.Gauge:
+				e.message(3, func(e *encoder) {
+					e.int64(1, metric.Value())
+				})
+			case metrics.GaugeFloat64:
+				e.message(4, func(e *encoder) {
+					e.double(1, metric.Value())
+				})
+			case metrics.Healthcheck:
+				metric.Check()
+				e.message(8, func(e *encoder) {
+					if err := metric.Error(); nil != err {
+						e.string(1, err.Error())
+					}
+				})
+			case metrics.Histogram:
+				h := metric.Snapshot()
+				e.message(5, func(e *encoder) {
+					marshalHistogram(e, h.Count(), h.Min(), h.Max(), h.Mean(), h.StdDev(), h.Percentiles(quantiles))
+				})
+			case metrics.Meter:
+				m := metric.Snapshot()
+				e.message(6, func(e *encoder) {
+					marshalMeter(e, m.Count(), m.Rate1(), m.Rate5(), m.Rate15(), m.RateMean(), m.RateMax(), m.RateMin())
+				})
+			case metrics.Timer:
+				t := metric.Snapshot()
+				e.message(7, func(e *encoder) {
+					e.message(1, func(e *encoder) {
+						marshalHistogram(e, t.Count(), t.Min(), t.Max(), t.Mean(), t.StdDev(), t.Percentiles(quantiles))
+					})
+					e.message(2, func(e *encoder) {
+						marshalMeter(e, t.Count(), t.Rate1(), t.Rate5(), t.Rate15(), t.RateMean(), 0, 0)
+					})
+				})
+			}
+		})
+	})
+	return e.b, nil
+}
+
+func marshalHistogram(e *encoder, count, min, max int64, mean, stdDev float64, ps []float64) {
+	e.int64(1, count)
+	e.int64(2, min)
+	e.int64(3, max)
+	e.double(4, mean)
+	e.double(5, stdDev)
+	for i, q := range quantiles {
+		e.message(6, func(e *encoder) {
+			e.double(1, q)
+			e.double(2, ps[i])
+		})
+	}
+}
+
+func marshalMeter(e *encoder, count int64, rate1, rate5, rate15, rateMean, rateMax, rateMin float64) {
+	e.int64(1, count)
+	e.double(2, rate1)
+	e.double(3, rate5)
+	e.double(4, rate15)
+	e.double(5, rateMean)
+	e.double(6, rateMax)
+	e.double(7, rateMin)
+}
+
+// UnmarshalProto constructs a new registry from a Registry message produced
+// by MarshalProto.  Counters, DecimalCounters, Gauges and GaugeFloat64s are
+// restored exactly and Healthchecks are restored with their error and are
+// not re-checked.  Histograms, Meters and Timers are restored as read-only
+// snapshots of their encoded statistics, like by metrics.RegistryFromJSON.
+func UnmarshalProto(b []byte) (metrics.Registry, error) {
+	r := metrics.NewRegistry()
+	err := decode(b, func(f field) error {
+		if 1 != f.num || wireBytes != f.wireType {
+			return nil
+		}
+		var name string
+		var metric interface{}
+		err := decode(f.data, func(f field) (err error) {
+			switch f.num {
+			case 1:
+				name = string(f.data)
+			case 2:
+				c := metrics.NewCounter()
+				err = decodeScalar(f.data, func(f field) { c.Inc(f.int64()) })
+				metric = c
+			case 3:
+				g := metrics.NewGauge()
+				err = decodeScalar(f.data, func(f field) { g.Update(f.int64()) })
+				metric = g
+			case 4:
+				g := metrics.NewGaugeFloat64()
+				err = decodeScalar(f.data, func(f field) { g.Update(f.double()) })
+				metric = g
+			case 5:
+				metric, err = unmarshalHistogram(f.data)
+			case 6:
+				metric, err = unmarshalMeter(f.data)
+			case 7:
+				var h metrics.Histogram = metrics.NewStaticHistogram(0, 0, 0, 0, 0, nil, nil)
+				var m metrics.Meter = metrics.NewStaticMeter(0, 0, 0, 0, 0, 0, 0)
+				err = decode(f.data, func(f field) (err error) {
+					switch f.num {
+					case 1:
+						h, err = unmarshalHistogram(f.data)
+					case 2:
+						m, err = unmarshalMeter(f.data)
+					}
+					return
+				})
+				metric = metrics.NewStaticTimer(h, m)
+			case 8:
+				var msg string
+				err = decodeScalar(f.data, func(f field) { msg = string(f.data) })
+				h := metrics.NewHealthcheck(func(metrics.Healthcheck) {})
+				if "" != msg {
+					h.Unhealthy(errors.New(msg))
+				}
+				metric = h
+			case 9:
+				c := metrics.NewDecimalCounter()
+				err = decodeScalar(f.data, func(f field) { c.Add(f.double()) })
+				metric = c
+			}
+			return
+		})
+		if nil != err {
+			return err
+		}
+		if nil != metric {
+			r.Register(name, metric)
+		}
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return r, nil
+}
+
+// decodeScalar calls fn with the first field of a single-field message, if
+// it is present.
+func decodeScalar(b []byte, fn func(field)) error {
+	return decode(b, func(f field) error {
+		if 1 == f.num {
+			fn(f)
+		}
+		return nil
+	})
+}
+
+// unmarshalHistogram decodes a Histogram message into a read-only snapshot.
+func unmarshalHistogram(b []byte) (metrics.Histogram, error) {
+	var count, min, max int64
+	var mean, stdDev float64
+	var ps, scores []float64
+	err := decode(b, func(f field) error {
+		switch f.num {
+		case 1:
+			count = f.int64()
+		case 2:
+			min = f.int64()
+		case 3:
+			max = f.int64()
+		case 4:
+			mean = f.double()
+		case 5:
+			stdDev = f.double()
+		case 6:
+			var q, v float64
+			if err := decode(f.data, func(f field) error {
+				switch f.num {
+				case 1:
+					q = f.double()
+				case 2:
+					v = f.double()
+				}
+				return nil
+			}); nil != err {
+				return err
+			}
+			ps, scores = append(ps, q), append(scores, v)
+		}
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return metrics.NewStaticHistogram(count, min, max, mean, stdDev, ps, scores), nil
+}
+
+// unmarshalMeter decodes a Meter message into a read-only snapshot.
+func unmarshalMeter(b []byte) (metrics.Meter, error) {
+	var count int64
+	rates := make([]float64, 6) // 1m, 5m, 15m, mean, max and min
+	err := decode(b, func(f field) error {
+		switch {
+		case 1 == f.num:
+			count = f.int64()
+		case 1 < f.num && f.num < 2+len(rates):
+			rates[f.num-2] = f.double()
+		}
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return metrics.NewStaticMeter(count, rates[0], rates[1], rates[2], rates[3], rates[4], rates[5]), nil
+}
@@ -0,0 +1,110 @@
+package metricspb
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestRoundTrip(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.NewRegisteredCounter("counter", r).Inc(-47)
+	metrics.NewRegisteredGauge("gauge", r).Update(23)
+	metrics.NewRegisteredGaugeFloat64("gaugefloat64", r).Update(2)
+	metrics.NewRegisteredDecimalCounter("decimalcounter", r).Add(2.5)
+	r.Register("healthcheck", metrics.NewHealthcheck(func(h metrics.Healthcheck) {
+		h.Unhealthy(errors.New("down"))
+	}))
+	h := metrics.NewRegisteredHistogram("histogram", r, metrics.NewUniformSample(100))
+	timer := metrics.NewRegisteredTimer("timer", r)
+	defer timer.Stop()
+	m := metrics.NewRegisteredMeter("meter", r)
+	defer m.Stop()
+	for i := int64(1); i <= 100; i++ {
+		h.Update(i)
+		timer.Update(time.Duration(i))
+	}
+	m.Mark(7)
+
+	b, err := MarshalProto(r)
+	if nil != err {
+		t.Fatal(err)
+	}
+	restored, err := UnmarshalProto(b)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if c := restored.Get("counter").(metrics.Counter); -47 != c.Count() {
+		t.Errorf("counter: -47 != %v\n", c.Count())
+	}
+	if g := restored.Get("gauge").(metrics.Gauge); 23 != g.Value() {
+		t.Errorf("gauge: 23 != %v\n", g.Value())
+	}
+	if g, ok := restored.Get("gaugefloat64").(metrics.GaugeFloat64); !ok || 2 != g.Value() {
+		t.Errorf("gaugefloat64: 2 != %v\n", restored.Get("gaugefloat64"))
+	}
+	if c := restored.Get("decimalcounter").(metrics.DecimalCounter); 2.5 != c.Count() {
+		t.Errorf("decimalcounter: 2.5 != %v\n", c.Count())
+	}
+	if err := restored.Get("healthcheck").(metrics.Healthcheck).Error(); nil == err || "down" != err.Error() {
+		t.Errorf("healthcheck: down != %v\n", err)
+	}
+	rh := restored.Get("histogram").(metrics.Histogram)
+	if 100 != rh.Count() || 1 != rh.Min() || 100 != rh.Max() || h.Mean() != rh.Mean() {
+		t.Errorf("histogram: %v %v %v %v\n", rh.Count(), rh.Min(), rh.Max(), rh.Mean())
+	}
+	if p := rh.Percentile(0.99); h.Percentile(0.99) != p {
+		t.Errorf("histogram: %v != %v\n", h.Percentile(0.99), p)
+	}
+	rt := restored.Get("timer").(metrics.Timer)
+	if 100 != rt.Count() || 100 != rt.Max() || timer.StdDev() != rt.StdDev() {
+		t.Errorf("timer: %v %v %v\n", rt.Count(), rt.Max(), rt.StdDev())
+	}
+	if rm := restored.Get("meter").(metrics.Meter); 7 != rm.Count() {
+		t.Errorf("meter: 7 != %v\n", rm.Count())
+	}
+}
+
+func TestRoundTripLargeCounts(t *testing.T) {
+	const n = 1<<53 + 1
+	r := metrics.NewRegistry()
+	h := metrics.NewRegisteredHistogram("histogram", r, metrics.NewUniformSample(100))
+	h.Update(n)
+	r.Register("meter", metrics.NewStaticMeter(n, 0, 0, 0, 0, 0, 0))
+	b, err := MarshalProto(r)
+	if nil != err {
+		t.Fatal(err)
+	}
+	restored, err := UnmarshalProto(b)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if max := restored.Get("histogram").(metrics.Histogram).Max(); n != max {
+		t.Errorf("histogram: %v != %v\n", int64(n), max)
+	}
+	if count := restored.Get("meter").(metrics.Meter).Count(); n != count {
+		t.Errorf("meter: %v != %v\n", int64(n), count)
+	}
+}
+
+func TestMarshalProtoEncoding(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.NewRegisteredCounter("c", r).Inc(1)
+	b, err := MarshalProto(r)
+	if nil != err {
+		t.Fatal(err)
+	}
+	// Registry{metrics: [Metric{name: "c", counter: Counter{count: 1}}]}
+	expected := []byte{0x0a, 0x07, 0x0a, 0x01, 'c', 0x12, 0x02, 0x08, 0x01}
+	if string(expected) != string(b) {
+		t.Fatalf("% x != % x\n", expected, b)
+	}
+}
+
+func TestUnmarshalProtoTruncated(t *testing.T) {
+	if _, err := UnmarshalProto([]byte{0x0a, 0x07, 0x0a}); nil == err {
+		t.Fatal(err)
+	}
+}
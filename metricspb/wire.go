@@ -0,0 +1,124 @@
+package metricspb
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// The protobuf wire format is simple enough to write by hand for the few
+// scalar and message fields of metrics.proto, which spares users of this
+// package a dependency on a protobuf runtime.
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("metricspb: truncated message")
+
+// encoder appends fields to a message.  As in proto3, scalar fields holding
+// their zero value are omitted.
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) tag(field, wireType int) {
+	e.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (e *encoder) varint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	e.b = append(e.b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func (e *encoder) int64(field int, v int64) {
+	if 0 != v {
+		e.tag(field, wireVarint)
+		e.varint(uint64(v))
+	}
+}
+
+func (e *encoder) double(field int, v float64) {
+	if 0 != v || math.Signbit(v) {
+		e.tag(field, wireFixed64)
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+		e.b = append(e.b, buf[:]...)
+	}
+}
+
+func (e *encoder) string(field int, s string) {
+	if "" != s {
+		e.tag(field, wireBytes)
+		e.varint(uint64(len(s)))
+		e.b = append(e.b, s...)
+	}
+}
+
+// message encodes a length-delimited submessage, which is written even when
+// empty so that its presence in a oneof is preserved.
+func (e *encoder) message(field int, f func(*encoder)) {
+	sub := &encoder{}
+	f(sub)
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(sub.b)))
+	e.b = append(e.b, sub.b...)
+}
+
+// field is a single decoded field.  Varint and fixed-width values are held
+// in v and length-delimited values in data.
+type field struct {
+	num      int
+	wireType int
+	v        uint64
+	data     []byte
+}
+
+func (f field) int64() int64 { return int64(f.v) }
+
+func (f field) double() float64 { return math.Float64frombits(f.v) }
+
+// decode calls fn for each field of the message in b, skipping nothing so
+// that fn may ignore unknown fields as it pleases.
+func decode(b []byte, fn func(field) error) error {
+	for 0 != len(b) {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errTruncated
+		}
+		b = b[n:]
+		f := field{num: int(key >> 3), wireType: int(key & 7)}
+		switch f.wireType {
+		case wireVarint:
+			if f.v, n = binary.Uvarint(b); n <= 0 {
+				return errTruncated
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return errTruncated
+			}
+			f.v, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return errTruncated
+			}
+			f.data, b = b[n:n+int(l)], b[n+int(l):]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errTruncated
+			}
+			f.v, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		default:
+			return errors.New("metricspb: unsupported wire type")
+		}
+		if err := fn(f); nil != err {
+			return err
+		}
+	}
+	return nil
+}
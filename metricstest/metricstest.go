@@ -0,0 +1,113 @@
+// Package metricstest provides helpers for asserting the contents of a
+// go-metrics registry in tests.
+//
+// Each helper fetches the named metric from the registry, asserts that it
+// is of the expected type and compares its value, failing the test with
+// Fatalf if the metric is missing, of another type or has another value.
+package metricstest
+
+import (
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// AssertCounter asserts that the Counter registered under name counts want.
+func AssertCounter(t testing.TB, r metrics.Registry, name string, want int64) {
+	t.Helper()
+	c, ok := get(t, r, name).(metrics.Counter)
+	if !ok {
+		t.Fatalf("%s: %T is not a Counter", name, r.Get(name))
+	}
+	if got := c.Count(); want != got {
+		t.Fatalf("%s.Count(): %v != %v", name, want, got)
+	}
+}
+
+// AssertGauge asserts that the Gauge registered under name has the value
+// want.
+func AssertGauge(t testing.TB, r metrics.Registry, name string, want int64) {
+	t.Helper()
+	g, ok := get(t, r, name).(metrics.Gauge)
+	if !ok {
+		t.Fatalf("%s: %T is not a Gauge", name, r.Get(name))
+	}
+	if got := g.Value(); want != got {
+		t.Fatalf("%s.Value(): %v != %v", name, want, got)
+	}
+}
+
+// AssertGaugeFloat64 asserts that the GaugeFloat64 registered under name has
+// the value want.
+func AssertGaugeFloat64(t testing.TB, r metrics.Registry, name string, want float64) {
+	t.Helper()
+	g, ok := get(t, r, name).(metrics.GaugeFloat64)
+	if !ok {
+		t.Fatalf("%s: %T is not a GaugeFloat64", name, r.Get(name))
+	}
+	if got := g.Value(); want != got {
+		t.Fatalf("%s.Value(): %v != %v", name, want, got)
+	}
+}
+
+// AssertHistogramCount asserts that the Histogram registered under name has
+// recorded want values.
+func AssertHistogramCount(t testing.TB, r metrics.Registry, name string, want int64) {
+	t.Helper()
+	h, ok := get(t, r, name).(metrics.Histogram)
+	if !ok {
+		t.Fatalf("%s: %T is not a Histogram", name, r.Get(name))
+	}
+	if got := h.Count(); want != got {
+		t.Fatalf("%s.Count(): %v != %v", name, want, got)
+	}
+}
+
+// AssertMeterCount asserts that the Meter registered under name has been
+// marked with want events.
+func AssertMeterCount(t testing.TB, r metrics.Registry, name string, want int64) {
+	t.Helper()
+	m, ok := get(t, r, name).(metrics.Meter)
+	if !ok {
+		t.Fatalf("%s: %T is not a Meter", name, r.Get(name))
+	}
+	if got := m.Count(); want != got {
+		t.Fatalf("%s.Count(): %v != %v", name, want, got)
+	}
+}
+
+// AssertRegistered asserts that a metric is registered under name.
+func AssertRegistered(t testing.TB, r metrics.Registry, name string) {
+	t.Helper()
+	get(t, r, name)
+}
+
+// AssertTimerCount asserts that the Timer registered under name has
+// recorded want durations.
+func AssertTimerCount(t testing.TB, r metrics.Registry, name string, want int64) {
+	t.Helper()
+	tm, ok := get(t, r, name).(metrics.Timer)
+	if !ok {
+		t.Fatalf("%s: %T is not a Timer", name, r.Get(name))
+	}
+	if got := tm.Count(); want != got {
+		t.Fatalf("%s.Count(): %v != %v", name, want, got)
+	}
+}
+
+// AssertUnregistered asserts that no metric is registered under name.
+func AssertUnregistered(t testing.TB, r metrics.Registry, name string) {
+	t.Helper()
+	if i := r.Get(name); nil != i {
+		t.Fatalf("%s: %T is registered", name, i)
+	}
+}
+
+func get(t testing.TB, r metrics.Registry, name string) interface{} {
+	t.Helper()
+	i := r.Get(name)
+	if nil == i {
+		t.Fatalf("%s: not registered", name)
+	}
+	return i
+}
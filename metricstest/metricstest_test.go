@@ -0,0 +1,77 @@
+package metricstest
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// recorder is a testing.TB which records the failure rather than ending the
+// test.  Fatalf stops the calling goroutine, so helpers under test must be
+// run on their own goroutine by fails.
+type recorder struct {
+	testing.TB
+	failure string
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.failure = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+func (r *recorder) Helper() {}
+
+func fails(t *testing.T, f func(testing.TB)) string {
+	r := &recorder{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(r)
+	}()
+	<-done
+	return r.failure
+}
+
+func TestAssertions(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.NewRegisteredCounter("counter", r).Inc(47)
+	metrics.NewRegisteredGauge("gauge", r).Update(23)
+	metrics.NewRegisteredGaugeFloat64("gaugefloat64", r).Update(1.5)
+	metrics.NewRegisteredHistogram("histogram", r, metrics.NewUniformSample(10)).Update(1)
+	m := metrics.NewRegisteredMeter("meter", r)
+	defer m.Stop()
+	m.Mark(3)
+	tm := metrics.NewRegisteredTimer("timer", r)
+	defer tm.Stop()
+	tm.Update(time.Second)
+
+	AssertCounter(t, r, "counter", 47)
+	AssertGauge(t, r, "gauge", 23)
+	AssertGaugeFloat64(t, r, "gaugefloat64", 1.5)
+	AssertHistogramCount(t, r, "histogram", 1)
+	AssertMeterCount(t, r, "meter", 3)
+	AssertTimerCount(t, r, "timer", 1)
+	AssertRegistered(t, r, "counter")
+	AssertUnregistered(t, r, "missing")
+}
+
+func TestAssertionFailures(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.NewRegisteredCounter("counter", r).Inc(47)
+
+	if failure := fails(t, func(tb testing.TB) { AssertCounter(tb, r, "counter", 1) }); "counter.Count(): 1 != 47" != failure {
+		t.Error(failure)
+	}
+	if failure := fails(t, func(tb testing.TB) { AssertCounter(tb, r, "missing", 1) }); "missing: not registered" != failure {
+		t.Error(failure)
+	}
+	if failure := fails(t, func(tb testing.TB) { AssertTimerCount(tb, r, "counter", 1) }); "counter: *metrics.StandardCounter is not a Timer" != failure {
+		t.Error(failure)
+	}
+	if failure := fails(t, func(tb testing.TB) { AssertUnregistered(tb, r, "counter") }); "counter: *metrics.StandardCounter is registered" != failure {
+		t.Error(failure)
+	}
+}
@@ -1,7 +1,11 @@
 package metrics
 
 import (
+	"io/ioutil"
 	"net"
+	"strconv"
+	"strings"
+	"testing"
 	"time"
 )
 
@@ -19,3 +23,62 @@ func ExampleOpenTSDBWithConfig() {
 		DurationUnit:  time.Millisecond,
 	})
 }
+
+func TestOpenTSDBOnce(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	ch := make(chan string)
+	go func() {
+		conn, err := l.Accept()
+		if nil != err {
+			ch <- ""
+			return
+		}
+		defer conn.Close()
+		b, _ := ioutil.ReadAll(conn)
+		ch <- string(b)
+	}()
+
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(47)
+	NewRegisteredGauge("bar", r).Update(3)
+	before := time.Now().UnixNano() / int64(time.Millisecond)
+	err = OpenTSDBOnce(OpenTSDBConfig{
+		Addr:         l.Addr().(*net.TCPAddr),
+		Registry:     r,
+		DurationUnit: time.Nanosecond,
+		Prefix:       "app",
+		Tags:         map[string]string{"env": "test", "host": "web1"},
+		Milliseconds: true,
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(<-ch), "\n")
+	if 2 != len(lines) {
+		t.Fatal(lines)
+	}
+	got := make(map[string]bool)
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if 6 != len(fields) || "put" != fields[0] {
+			t.Fatal(line)
+		}
+		ts, err := strconv.ParseInt(fields[2], 10, 64)
+		if nil != err || ts < before {
+			t.Errorf("timestamp: %v < %v\n", fields[2], before)
+		}
+		got[strings.Join(append(fields[:2:2], fields[3:]...), " ")] = true
+	}
+	for _, line := range []string{
+		"put app.foo.count 47 env=test host=web1",
+		"put app.bar.value 3 env=test host=web1",
+	} {
+		if !got[line] {
+			t.Errorf("missing %q in %q\n", line, lines)
+		}
+	}
+}
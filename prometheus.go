@@ -0,0 +1,212 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PrometheusQuantiles are the quantiles exported for each Histogram and Timer
+// by WritePrometheus.
+var PrometheusQuantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
+// PrometheusHandler returns an http.Handler which serves the metrics in the
+// given registry in the OpenMetrics text format, with exemplars, if the
+// request's Accept header includes application/openmetrics-text, and in the
+// Prometheus text exposition format otherwise.
+func PrometheusHandler(r Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text") {
+			w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+			WriteOpenMetrics(r, w)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WritePrometheus(r, w)
+	})
+}
+
+// WritePrometheus writes the metrics in the given registry to the given
+// io.Writer in the Prometheus text exposition format.  Metric names are
+// sanitized to valid Prometheus identifiers and written in sorted order.
+// Counters are suffixed with _total and Histograms and Timers are written
+// as summaries.  The children of a LabeledCounter are written with their
+// labels.  ExemplarHistograms are written as histograms of their buckets,
+// without exemplars.  Descriptions attached by Describe are written as HELP
+// lines.
+func WritePrometheus(r Registry, w io.Writer) {
+	writePrometheus(r, w, false)
+}
+
+// WriteOpenMetrics writes the metrics in the given registry to the given
+// io.Writer in the OpenMetrics text format, like WritePrometheus but naming
+// counter families without their _total suffix, writing the exemplars of
+// ExemplarHistograms' buckets and ending with "# EOF".
+func WriteOpenMetrics(r Registry, w io.Writer) {
+	writePrometheus(r, w, true)
+	fmt.Fprintln(w, "# EOF")
+}
+
+func writePrometheus(r Registry, w io.Writer, openMetrics bool) {
+	namedMetrics := sortedMetrics(r)
+	var describer interface {
+		Help(string) string
+	}
+	if base, _ := findPrefix(r, ""); nil != base {
+		describer, _ = base.(interface {
+			Help(string) string
+		})
+	}
+
+	for _, namedMetric := range namedMetrics {
+		name := PrometheusName(namedMetric.name)
+		counter := name + "_total" // the family name of a counter
+		if openMetrics {
+			counter = name
+		}
+		help := func(family string) {
+			if nil != describer {
+				if s := describer.Help(namedMetric.name); "" != s {
+					fmt.Fprintf(w, "# HELP %s %s\n", family, prometheusHelpEscaper.Replace(s))
+				}
+			}
+		}
+		switch metric := namedMetric.m.(type) {
+		case LabeledCounter:
+			help(counter)
+			fmt.Fprintf(w, "# TYPE %s counter\n", counter)
+			n := 0
+			metric.Each(func(labels map[string]string, c Counter) {
+				n++
+				fmt.Fprintf(w, "%s_total%s %d\n", name, prometheusLabels(labels), c.Count())
+			})
+			if 0 == n {
+				fmt.Fprintf(w, "%s_total%s %d\n", name, prometheusLabels(metric.Labels()), metric.Count())
+			}
+		case Counter:
+			help(counter)
+			fmt.Fprintf(w, "# TYPE %s counter\n", counter)
+			fmt.Fprintf(w, "%s_total %d\n", name, metric.Count())
+		case Gauge:
+			help(name)
+			fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+			fmt.Fprintf(w, "%s %d\n", name, metric.Value())
+		case GaugeFloat64:
+			help(name)
+			fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+			fmt.Fprintf(w, "%s %s\n", name, prometheusFloat(metric.Value()))
+		case ExemplarHistogram:
+			help(name)
+			writePrometheusHistogram(w, name, metric, openMetrics)
+		case Histogram:
+			help(name)
+			h := metric.Snapshot()
+			writePrometheusSummary(w, name, h.Percentiles(PrometheusQuantiles), h.Sum(), h.Count())
+		case Meter:
+			m := metric.Snapshot()
+			help(counter)
+			fmt.Fprintf(w, "# TYPE %s counter\n", counter)
+			fmt.Fprintf(w, "%s_total %d\n", name, m.Count())
+			writePrometheusGauge(w, name+"_rate1", m.Rate1())
+			writePrometheusGauge(w, name+"_rate5", m.Rate5())
+			writePrometheusGauge(w, name+"_rate15", m.Rate15())
+			writePrometheusGauge(w, name+"_rate_mean", m.RateMean())
+			writePrometheusGauge(w, name+"_rate_max", m.RateMax())
+			writePrometheusGauge(w, name+"_rate_min", m.RateMin())
+		case Timer:
+			help(name)
+			t := metric.Snapshot()
+			writePrometheusSummary(w, name, t.Percentiles(PrometheusQuantiles), t.Sum(), t.Count())
+		}
+	}
+}
+
+// PrometheusName sanitizes a metric name into a valid Prometheus identifier
+// by replacing every invalid character, including '.' and '-', with '_'.
+func PrometheusName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '_' == c || ':' == c || '0' <= c && c <= '9' && i > 0) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func writePrometheusGauge(w io.Writer, name string, v float64) {
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+	fmt.Fprintf(w, "%s %s\n", name, prometheusFloat(v))
+}
+
+func writePrometheusSummary(w io.Writer, name string, ps []float64, sum, count int64) {
+	fmt.Fprintf(w, "# TYPE %s summary\n", name)
+	for i, q := range PrometheusQuantiles {
+		fmt.Fprintf(w, "%s{quantile=\"%s\"} %s\n", name, prometheusFloat(q), prometheusFloat(ps[i]))
+	}
+	fmt.Fprintf(w, "%s_sum %d\n", name, sum)
+	fmt.Fprintf(w, "%s_count %d\n", name, count)
+}
+
+// writePrometheusHistogram writes the buckets of the given histogram with
+// their exact sum, and with their exemplars if openMetrics is set.  A
+// histogram with no buckets, such as a NilExemplarHistogram, is written as
+// an empty +Inf bucket.
+func writePrometheusHistogram(w io.Writer, name string, h ExemplarHistogram, openMetrics bool) {
+	buckets, sum := h.Buckets(), h.BucketSum()
+	if 0 == len(buckets) {
+		buckets, sum = []HistogramBucket{{UpperBound: math.Inf(1)}}, 0
+	}
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for _, b := range buckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d", name, prometheusFloat(b.UpperBound), b.Count)
+		if e := b.Exemplar; nil != e && openMetrics {
+			fmt.Fprintf(
+				w,
+				" # {trace_id=\"%s\"} %d %s",
+				prometheusEscaper.Replace(e.TraceID),
+				e.Value,
+				prometheusFloat(float64(e.Timestamp.UnixNano())/1e9),
+			)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%s_sum %d\n", name, sum)
+	fmt.Fprintf(w, "%s_count %d\n", name, buckets[len(buckets)-1].Count)
+}
+
+func prometheusFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// prometheusLabels formats a label set as a sorted Prometheus label block.
+func prometheusLabels(labels map[string]string) string {
+	if 0 == len(labels) {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", PrometheusName(k), prometheusEscaper.Replace(labels[k]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var prometheusEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+var prometheusHelpEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
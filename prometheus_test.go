@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrometheusName(t *testing.T) {
+	if name := PrometheusName("http.requests-total"); "http_requests_total" != name {
+		t.Fatal(name)
+	}
+	if name := PrometheusName("9lives"); "_lives" != name {
+		t.Fatal(name)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo.count", r).Inc(47)
+	NewRegisteredGauge("bar-gauge", r).Update(3)
+	h := NewRegisteredHistogram("baz", r, NewUniformSample(100))
+	h.Update(1)
+	h.Update(3)
+	b := &bytes.Buffer{}
+	WritePrometheus(r, b)
+	for _, line := range []string{
+		"# TYPE bar_gauge gauge\nbar_gauge 3\n",
+		"# TYPE baz summary\n",
+		"baz{quantile=\"0.5\"} 2\n",
+		"baz_sum 4\nbaz_count 2\n",
+		"# TYPE foo_count_total counter\nfoo_count_total 47\n",
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("missing %q in:\n%s", line, b.String())
+		}
+	}
+	if i, j := strings.Index(b.String(), "bar_gauge"), strings.Index(b.String(), "foo_count"); i > j {
+		t.Error("metrics not sorted")
+	}
+}
+
+func TestWritePrometheusLabeledCounter(t *testing.T) {
+	r := NewRegistry()
+	c := NewRegisteredLabeledCounter("requests", r, map[string]string{"host": "a"})
+	c.With(map[string]string{"code": "200"}).Inc(2)
+	b := &bytes.Buffer{}
+	WritePrometheus(r, b)
+	if s := "requests_total{code=\"200\",host=\"a\"} 2\n"; !strings.Contains(b.String(), s) {
+		t.Errorf("missing %q in:\n%s", s, b.String())
+	}
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r).Inc(1)
+	w := httptest.NewRecorder()
+	PrometheusHandler(r).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatal(ct)
+	}
+	if !strings.Contains(w.Body.String(), "foo_total 1\n") {
+		t.Fatal(w.Body.String())
+	}
+}
+
+func TestWritePrometheusHelp(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	NewRegisteredCounter("foo", r).Inc(47)
+	NewRegisteredGauge("bar", r).Update(3)
+	r.Describe("foo", "Requests served.\nIncluding \\ errors.")
+	b := &bytes.Buffer{}
+	WritePrometheus(r, b)
+	if s := "# HELP foo_total Requests served.\\nIncluding \\\\ errors.\n# TYPE foo_total counter\n"; !strings.Contains(b.String(), s) {
+		t.Errorf("missing %q in:\n%s", s, b.String())
+	}
+	if strings.Contains(b.String(), "# HELP bar") {
+		t.Errorf("unexpected HELP in:\n%s", b.String())
+	}
+
+	p := NewPrefixedChildRegistry(r, "prefix.").(*PrefixedRegistry)
+	NewRegisteredGauge("baz", p).Update(1)
+	p.Describe("baz", "Bazzes.")
+	b.Reset()
+	WritePrometheus(p, b)
+	if s := "# HELP prefix_baz Bazzes.\n"; !strings.Contains(b.String(), s) {
+		t.Errorf("missing %q in:\n%s", s, b.String())
+	}
+}
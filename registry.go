@@ -1,21 +1,123 @@
 package metrics
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"path"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // DuplicateMetric is the error returned by Registry.Register when a metric
 // already exists.  If you mean to Register that metric you must first
-// Unregister the existing metric.
+// Unregister the existing metric.  Every DuplicateMetric matches
+// ErrDuplicateMetric under errors.Is.
 type DuplicateMetric string
 
+// ErrDuplicateMetric may be compared with errors.Is to detect a
+// DuplicateMetric regardless of the name it carries.
+var ErrDuplicateMetric = errors.New("duplicate metric")
+
 func (err DuplicateMetric) Error() string {
 	return fmt.Sprintf("duplicate metric: %s", string(err))
 }
 
+// Is reports whether target is ErrDuplicateMetric, for use by errors.Is.
+func (err DuplicateMetric) Is(target error) bool {
+	return ErrDuplicateMetric == target
+}
+
+// Name returns the name of the metric which was already registered.
+func (err DuplicateMetric) Name() string {
+	return string(err)
+}
+
+// MetricTypeMismatch is the error returned by GetOrRegisterE when the metric
+// already registered under a name is not of the requested kind, such as a
+// Gauge where a Counter was requested.
+type MetricTypeMismatch struct {
+	Name      string
+	Existing  reflect.Type // Type of the metric already registered
+	Requested reflect.Type // Metric interface requested, such as Counter
+}
+
+func (err MetricTypeMismatch) Error() string {
+	return fmt.Sprintf("metric %s is a %v, not a %v", err.Name, err.Existing, err.Requested)
+}
+
+// UnsupportedMetric is the error returned by RegisterStrict when the metric
+// is not of any kind a registry holds, such as Counter or Timer, which
+// Register silently ignores.
+type UnsupportedMetric struct {
+	Name   string
+	Metric interface{}
+}
+
+func (err UnsupportedMetric) Error() string {
+	return fmt.Sprintf("unsupported metric: %s is a %T, not a metric", err.Name, err.Metric)
+}
+
+// NameValidator, if not nil, is called by StandardRegistry with every name
+// before a metric is registered under it, and a name for which it returns an
+// error is rejected with an InvalidMetricName.  Register returns the error,
+// while GetOrRegister returns the metric without registering it.  Names are
+// not validated by default; see GraphiteNameValidator.
+var NameValidator func(name string) error
+
+// InvalidMetricName is the error returned by Registry.Register when the
+// NameValidator rejects a name.
+type InvalidMetricName struct {
+	Name string
+	Err  error // Error returned by the NameValidator
+}
+
+func (err InvalidMetricName) Error() string {
+	return fmt.Sprintf("invalid metric name %q: %v", err.Name, err.Err)
+}
+
+// Unwrap returns the error returned by the NameValidator.
+func (err InvalidMetricName) Unwrap() error {
+	return err.Err
+}
+
+// validateName returns an InvalidMetricName if the NameValidator rejects the
+// given name.
+func validateName(name string) error {
+	if nil == NameValidator {
+		return nil
+	}
+	if err := NameValidator(name); nil != err {
+		return InvalidMetricName{Name: name, Err: err}
+	}
+	return nil
+}
+
+// ScopeSeparator separates the names of nested scopes in the prefixes of the
+// registries returned by Scope.
+var ScopeSeparator = "."
+
+// ErrTooManyMetrics is the error returned by BoundedRegistry.Register when
+// the registry already holds its maximum number of metrics.
+var ErrTooManyMetrics = errors.New("too many metrics")
+
+// ErrMetricNotFound is the error returned by Alias when no metric is
+// registered under the existing name.
+var ErrMetricNotFound = errors.New("metric not found")
+
+// ErrUnsupportedOperation is the error returned by a PrefixedRegistry when
+// its underlying registry does not support the operation, such as Alias or
+// RegisterAll.
+var ErrUnsupportedOperation = errors.New("operation not supported by registry")
+
+// ErrReadOnlyRegistry is the error returned by Register on a registry
+// returned by MergedRegistry or ReadOnly.
+var ErrReadOnlyRegistry = errors.New("read-only registry")
+
 // A Registry holds references to a set of metrics by name and can iterate
 // over them, calling callback functions provided by the user.
 //
@@ -53,22 +155,218 @@ type Registry interface {
 // The standard implementation of a Registry is a mutex-protected map
 // of names to metrics.
 type StandardRegistry struct {
-	metrics map[string]interface{}
-	mutex   sync.RWMutex
+	metrics  map[string]interface{}
+	mutex    sync.RWMutex
+	observed map[string]observation
+	aliases  map[string]string // alias to existing name
+	help     map[string]string
+	units    map[string]string
+	rates    map[string]float64
+
+	listening    int32 // set once any listener is added
+	onRegister   []func(string, interface{})
+	onUnregister []func(string)
+	events       []registryEvent // committed but not yet delivered
+	notifyMutex  sync.Mutex      // serializes delivery of events
+
+	iterating int         // iterations in progress, guarded by mutex
+	stopping  []Stoppable // unregistered while iterating, stopped by release
+}
+
+// registryEvent is a registration or unregistration awaiting delivery to
+// the registry's listeners.
+type registryEvent struct {
+	name       string
+	metric     interface{}
+	registered bool
+}
+
+// observation is the state in which a metric was last seen by EachWithTime
+// and the time that state was first seen.
+type observation struct {
+	fingerprint interface{}
+	updated     time.Time
 }
 
 // Create a new registry.
 func NewRegistry() Registry {
-	return &StandardRegistry{metrics: make(map[string]interface{})}
+	return &StandardRegistry{
+		metrics:  make(map[string]interface{}),
+		observed: make(map[string]observation),
+		aliases:  make(map[string]string),
+		help:     make(map[string]string),
+		units:    make(map[string]string),
+		rates:    make(map[string]float64),
+	}
 }
 
-// Call the given function for each registered metric.
+// Snapshot returns a new registry holding a read-only, point-in-time copy of
+// every metric in the given registry, taken via each metric's Snapshot
+// method.  Exporters may iterate the snapshot to avoid reading values which
+// change mid-scrape.  Healthchecks are copied with their current status and
+// are not re-run by the copy's Check method.
+func Snapshot(r Registry) Registry {
+	snapshot := NewRegistry()
+	r.Each(func(name string, i interface{}) {
+		switch metric := i.(type) {
+		case Counter:
+			snapshot.Register(name, metric.Snapshot())
+		case DecimalCounter:
+			snapshot.Register(name, metric.Snapshot())
+		case Gauge:
+			snapshot.Register(name, metric.Snapshot())
+		case GaugeFloat64:
+			snapshot.Register(name, metric.Snapshot())
+		case Healthcheck:
+			snapshot.Register(name, &StandardHealthcheck{metric.Error(), func(Healthcheck) {}})
+		case Histogram:
+			snapshot.Register(name, metric.Snapshot())
+		case Meter:
+			snapshot.Register(name, metric.Snapshot())
+		case Timer:
+			snapshot.Register(name, metric.Snapshot())
+		}
+	})
+	return snapshot
+}
+
+// Alias registers the metric registered under the existing name under the
+// alias as well, so that both names report the same metric, i.e. while
+// migrating dashboards from an old name to a new one.  Returns
+// ErrMetricNotFound if no metric is registered under the existing name and a
+// DuplicateMetric if a metric is already registered under the alias.
+// Unregistering the existing name also unregisters its aliases, while
+// unregistering an alias leaves the metric registered under its other names.
+func (r *StandardRegistry) Alias(existing, alias string) error {
+	r.mutex.Lock()
+	defer r.notify() // after the unlock
+	defer r.mutex.Unlock()
+	return r.alias(existing, alias)
+}
+
+// alias must be called with the mutex held.
+func (r *StandardRegistry) alias(existing, alias string) error {
+	i, ok := r.metrics[existing]
+	if !ok {
+		return ErrMetricNotFound
+	}
+	if _, ok := r.metrics[alias]; ok {
+		return DuplicateMetric(alias)
+	}
+	if err := validateName(alias); nil != err {
+		return err
+	}
+	if original, ok := r.aliases[existing]; ok {
+		existing = original
+	}
+	r.metrics[alias] = i
+	r.observed[alias] = r.observed[existing]
+	r.aliases[alias] = existing
+	r.event(alias, i, true)
+	return nil
+}
+
+// Describe attaches a human-readable description to the metric registered
+// under the given name, which exporters such as WritePrometheus may report.
+// The description is forgotten when the metric is unregistered.
+func (r *StandardRegistry) Describe(name, help string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.help[name] = help
+}
+
+// SetUnit attaches a unit, such as "bytes" or "requests", to the metric
+// registered under the given name, which exporters such as InfluxDB may
+// report so that dashboards can format its values.  Unlike a description,
+// it is meant for machines.  The unit is forgotten when the metric is
+// unregistered.
+func (r *StandardRegistry) SetUnit(name, unit string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.units[name] = unit
+}
+
+// SetSampleRate sets the fraction of the values of the metric registered
+// under the given name which StatsD is sent, each with a |@rate suffix so
+// that the server scales its counts back up.  A rate which is not between
+// zero and one sends every value.  The rate is forgotten when the metric is
+// unregistered.  DogStatsD ignores it, since it sends every metric as
+// already-aggregated gauges rather than individual values.
+func (r *StandardRegistry) SetSampleRate(name string, rate float64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.rates[name] = rate
+}
+
+// Call the given function for each registered metric.  A metric remains
+// valid for the duration of the call even if it is concurrently
+// unregistered: stopping it is deferred until no Each or EachWithTime is in
+// progress.
 func (r *StandardRegistry) Each(f func(string, interface{})) {
-	for name, i := range r.registered() {
+	metrics := r.hold()
+	defer r.release()
+	for name, i := range metrics {
 		f(name, i)
 	}
 }
 
+// EachSorted calls the given function for each registered metric in
+// lexicographic order of name.  The registry is not locked while the
+// function is called, so it may register and unregister metrics.
+func (r *StandardRegistry) EachSorted(f func(string, interface{})) {
+	eachSorted(r, f)
+}
+
+// EachMatching calls f for each registered metric for which pred returns
+// true, i.e. to export only the Timers.  Like Each, it iterates a copy of
+// the registry taken under the lock, so neither pred nor f is called with
+// the registry locked.
+func (r *StandardRegistry) EachMatching(pred func(name string, metric interface{}) bool, f func(string, interface{})) {
+	eachMatching(r, pred, f)
+}
+
+// EachWithTime calls the given function for each registered metric along
+// with the time it was last updated.
+//
+// Metrics are not wrapped, so updates are detected by comparing each metric's
+// observable state, such as a Counter's count or a Timer's count and sum,
+// with its state on the previous call.  The time reported is therefore the
+// time of the call which first saw the change, or of registration, and is
+// only as precise as the interval between calls.  Updates made before the
+// first call after registration are reported at the time of registration,
+// and an update which leaves the state unchanged, such as setting a Gauge to
+// its current value, is not seen.  Likewise a metric which changes and then
+// changes back between two calls, such as a Counter incremented and then
+// decremented, is not seen to have been updated at all.
+func (r *StandardRegistry) EachWithTime(f func(name string, metric interface{}, updated time.Time)) {
+	metrics := r.hold()
+	defer r.release()
+	fingerprints := make(map[string]interface{}, len(metrics))
+	for name, i := range metrics {
+		fingerprints[name] = metricFingerprint(i)
+	}
+	now := time.Now()
+	updated := make(map[string]time.Time, len(metrics))
+	r.mutex.Lock()
+	for name, fingerprint := range fingerprints {
+		o, ok := r.observed[name]
+		if !ok {
+			continue // unregistered meanwhile
+		}
+		if nil == o.fingerprint {
+			o.fingerprint = fingerprint
+		} else if o.fingerprint != fingerprint {
+			o.fingerprint, o.updated = fingerprint, now
+		}
+		r.observed[name] = o
+		updated[name] = o.updated
+	}
+	r.mutex.Unlock()
+	for name, t := range updated {
+		f(name, metrics[name], t)
+	}
+}
+
 // Get the metric by the given name or nil if none is registered.
 func (r *StandardRegistry) Get(name string) interface{} {
 	r.mutex.RLock()
@@ -76,6 +374,40 @@ func (r *StandardRegistry) Get(name string) interface{} {
 	return r.metrics[name]
 }
 
+// Len returns the number of registered metrics.
+func (r *StandardRegistry) Len() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return len(r.metrics)
+}
+
+// Help returns the description of the metric registered under the given
+// name, or the empty string if it has not been described.
+func (r *StandardRegistry) Help(name string) string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.help[name]
+}
+
+// Unit returns the unit of the metric registered under the given name, or
+// the empty string if none has been set.
+func (r *StandardRegistry) Unit(name string) string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.units[name]
+}
+
+// SampleRate returns the sample rate of the metric registered under the
+// given name, or one if none has been set.
+func (r *StandardRegistry) SampleRate(name string) float64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if rate, ok := r.rates[name]; ok {
+		return rate
+	}
+	return 1
+}
+
 // Gets an existing metric or creates and registers a new one. Threadsafe
 // alternative to calling Get and Register on failure.
 // The interface can be the metric to register if not found in registry,
@@ -91,6 +423,7 @@ func (r *StandardRegistry) GetOrRegister(name string, i interface{}) interface{}
 
 	// only take the write lock if we'll be modifying the metrics map
 	r.mutex.Lock()
+	defer r.notify() // after the unlock
 	defer r.mutex.Unlock()
 	if metric, ok := r.metrics[name]; ok {
 		return metric
@@ -102,14 +435,66 @@ func (r *StandardRegistry) GetOrRegister(name string, i interface{}) interface{}
 	return i
 }
 
+// GetOrRegisterFunc is like GetOrRegister but takes the constructor as a
+// func() interface{}, which it calls only if no metric is registered under
+// the given name, without the reflection GetOrRegister uses to detect one.
+func (r *StandardRegistry) GetOrRegisterFunc(name string, ctor func() interface{}) interface{} {
+	r.mutex.RLock()
+	metric, ok := r.metrics[name]
+	r.mutex.RUnlock()
+	if ok {
+		return metric
+	}
+
+	r.mutex.Lock()
+	defer r.notify() // after the unlock
+	defer r.mutex.Unlock()
+	if metric, ok := r.metrics[name]; ok {
+		return metric
+	}
+	i := ctor()
+	r.register(name, i)
+	return i
+}
+
+// GetOrRegisterE is like GetOrRegister but returns a MetricTypeMismatch if
+// the metric already registered under the given name is not of the same
+// kind, such as Counter or Timer, as the given metric or the metric returned
+// by the given function, and an InvalidMetricName if the NameValidator
+// rejects the name of a metric not yet registered.
+func (r *StandardRegistry) GetOrRegisterE(name string, i interface{}) (interface{}, error) {
+	return getOrRegisterE(r, name, i)
+}
+
 // Register the given metric under the given name.  Returns a DuplicateMetric
-// if a metric by the given name is already registered.
+// if a metric by the given name is already registered, or an
+// InvalidMetricName if the NameValidator rejects the name.
 func (r *StandardRegistry) Register(name string, i interface{}) error {
 	r.mutex.Lock()
+	defer r.notify() // after the unlock
 	defer r.mutex.Unlock()
 	return r.register(name, i)
 }
 
+// RegisterStrict is like Register but returns an UnsupportedMetric rather
+// than ignoring a metric which is not of any kind the registry holds.
+func (r *StandardRegistry) RegisterStrict(name string, i interface{}) error {
+	if err := checkSupported(name, i); nil != err {
+		return err
+	}
+	return r.Register(name, i)
+}
+
+// RegisterAll registers each of the given metrics under its name, or none
+// of them if any name is already registered, in which case it returns a
+// DuplicateMetric naming the first such name in sorted order.
+func (r *StandardRegistry) RegisterAll(metrics map[string]interface{}) error {
+	r.mutex.Lock()
+	defer r.notify() // after the unlock
+	defer r.mutex.Unlock()
+	return r.registerAll(metrics)
+}
+
 // Run all registered healthchecks.
 func (r *StandardRegistry) RunHealthchecks() {
 	r.mutex.RLock()
@@ -125,180 +510,969 @@ func (r *StandardRegistry) RunHealthchecks() {
 func (r *StandardRegistry) GetAll() map[string]map[string]interface{} {
 	data := make(map[string]map[string]interface{})
 	r.Each(func(name string, i interface{}) {
-		values := make(map[string]interface{})
-		switch metric := i.(type) {
-		case Counter:
-			values["count"] = metric.Count()
-		case Gauge:
-			values["value"] = metric.Value()
-		case GaugeFloat64:
-			values["value"] = metric.Value()
-		case Healthcheck:
-			values["error"] = nil
-			metric.Check()
-			if err := metric.Error(); nil != err {
-				values["error"] = metric.Error().Error()
-			}
-		case Histogram:
-			h := metric.Snapshot()
-			ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
-			values["count"] = h.Count()
-			values["min"] = h.Min()
-			values["max"] = h.Max()
-			values["mean"] = h.Mean()
-			values["stddev"] = h.StdDev()
-			values["median"] = ps[0]
-			values["75%"] = ps[1]
-			values["95%"] = ps[2]
-			values["99%"] = ps[3]
-			values["99.9%"] = ps[4]
-		case Meter:
-			m := metric.Snapshot()
-			values["count"] = m.Count()
-			values["1m.rate"] = m.Rate1()
-			values["5m.rate"] = m.Rate5()
-			values["15m.rate"] = m.Rate15()
-			values["mean.rate"] = m.RateMean()
-		case Timer:
-			t := metric.Snapshot()
-			ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
-			values["count"] = t.Count()
-			values["min"] = t.Min()
-			values["max"] = t.Max()
-			values["mean"] = t.Mean()
-			values["stddev"] = t.StdDev()
-			values["median"] = ps[0]
-			values["75%"] = ps[1]
-			values["95%"] = ps[2]
-			values["99%"] = ps[3]
-			values["99.9%"] = ps[4]
-			values["1m.rate"] = t.Rate1()
-			values["5m.rate"] = t.Rate5()
-			values["15m.rate"] = t.Rate15()
-			values["mean.rate"] = t.RateMean()
-		}
-		data[name] = values
+		_, data[name] = metricValues(i)
 	})
 	return data
 }
 
+// metricValues returns the kind of the given metric, such as "counter" or
+// "timer", and its values as reported by GetAll.  Healthchecks are checked.
+// The kind is empty and the values are empty for unsupported metrics.
+func metricValues(i interface{}) (string, map[string]interface{}) {
+	var kind string
+	values := make(map[string]interface{})
+	switch metric := i.(type) {
+	case Counter:
+		kind = "counter"
+		values["count"] = metric.Count()
+	case DecimalCounter:
+		kind = "decimalcounter"
+		values["count"] = metric.Count()
+	case Gauge:
+		kind = "gauge"
+		values["value"] = metric.Value()
+	case GaugeFloat64:
+		kind = "gaugefloat64"
+		values["value"] = metric.Value()
+	case Healthcheck:
+		kind = "healthcheck"
+		values["error"] = nil
+		metric.Check()
+		if err := metric.Error(); nil != err {
+			values["error"] = metric.Error().Error()
+		}
+	case Histogram:
+		kind = "histogram"
+		h := metric.Snapshot()
+		ps := h.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+		values["count"] = h.Count()
+		values["min"] = h.Min()
+		values["max"] = h.Max()
+		values["mean"] = h.Mean()
+		values["stddev"] = h.StdDev()
+		values["variance"] = h.Variance()
+		values["median"] = ps[0]
+		values["75%"] = ps[1]
+		values["95%"] = ps[2]
+		values["99%"] = ps[3]
+		values["99.9%"] = ps[4]
+	case Meter:
+		kind = "meter"
+		m := metric.Snapshot()
+		values["count"] = m.Count()
+		values["1m.rate"] = m.Rate1()
+		values["5m.rate"] = m.Rate5()
+		values["15m.rate"] = m.Rate15()
+		values["mean.rate"] = m.RateMean()
+		values["max.rate"] = m.RateMax()
+		values["min.rate"] = m.RateMin()
+	case Timer:
+		kind = "timer"
+		t := metric.Snapshot()
+		ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+		values["count"] = t.Count()
+		values["min"] = t.Min()
+		values["max"] = t.Max()
+		values["mean"] = t.Mean()
+		values["stddev"] = t.StdDev()
+		values["variance"] = t.Variance()
+		values["median"] = ps[0]
+		values["75%"] = ps[1]
+		values["95%"] = ps[2]
+		values["99%"] = ps[3]
+		values["99.9%"] = ps[4]
+		values["1m.rate"] = t.Rate1()
+		values["5m.rate"] = t.Rate5()
+		values["15m.rate"] = t.Rate15()
+		values["mean.rate"] = t.RateMean()
+	}
+	return kind, values
+}
+
+// ChangedSince returns the names of the metrics which have been registered
+// or updated since t, as seen by EachWithTime, in sorted order.  It calls
+// EachWithTime itself, so a change is dated by the call which first noticed
+// it rather than by the update, and a metric which changed and changed back
+// since the previous call is not reported.
+func (r *StandardRegistry) ChangedSince(t time.Time) []string {
+	return changedSince(r, t)
+}
+
+// StaleAfter returns the names of the metrics which have not been updated
+// within d, as seen by EachWithTime.  It calls EachWithTime itself, and since
+// a change is dated by the call which first noticed it, a metric which changed
+// more than d ago but has not been observed since is reported fresh, while one
+// which changed within d and changed back before any call saw it is reported
+// stale.
+func (r *StandardRegistry) StaleAfter(d time.Duration) []string {
+	return staleAfter(r, d)
+}
+
+// Scope returns a PrefixedRegistry registering metrics in this registry
+// under the given name followed by ScopeSeparator.  It returns a
+// *PrefixedRegistry rather than a Registry so that scopes can be nested.
+func (r *StandardRegistry) Scope(name string) *PrefixedRegistry {
+	return &PrefixedRegistry{underlying: r, prefix: name + ScopeSeparator}
+}
+
 // Unregister the metric with the given name.
 func (r *StandardRegistry) Unregister(name string) {
 	r.mutex.Lock()
+	defer r.notify() // after the unlock
+	defer r.mutex.Unlock()
+	r.unregister(name)
+}
+
+// UnregisterMatching unregisters every metric whose name matches the given
+// glob pattern, in which '*' matches any sequence of characters other than
+// '/', '?' any one character other than '/' and '[...]' a character class,
+// as in path.Match, so "db.*" matches every metric under "db." whose name
+// contains no '/'.  A name such as "http./api/users" must be matched with a
+// pattern which spells out its slashes, such as "http.*/*/*".  A malformed
+// pattern matches nothing.
+// The metrics are unregistered under a single acquisition of the lock.
+// Returns the number of names unregistered, including aliases of the
+// matching metrics.
+func (r *StandardRegistry) UnregisterMatching(pattern string) int {
+	if _, err := path.Match(pattern, ""); nil != err {
+		return 0
+	}
+	r.mutex.Lock()
+	defer r.notify() // after the unlock
 	defer r.mutex.Unlock()
-	r.stop(name)
+	n := len(r.metrics)
+	for name := range r.metrics {
+		if matched, _ := path.Match(pattern, name); matched {
+			r.unregister(name)
+		}
+	}
+	return n - len(r.metrics)
+}
+
+// unregister must be called with the mutex held.
+func (r *StandardRegistry) unregister(name string) {
+	if _, ok := r.metrics[name]; ok {
+		r.event(name, nil, false)
+	}
+	if _, ok := r.aliases[name]; ok {
+		delete(r.aliases, name)
+	} else {
+		r.stop(name)
+		for alias, existing := range r.aliases {
+			if existing == name {
+				r.event(alias, nil, false)
+				delete(r.metrics, alias)
+				delete(r.observed, alias)
+				delete(r.aliases, alias)
+				delete(r.help, alias)
+				delete(r.units, alias)
+				delete(r.rates, alias)
+			}
+		}
+	}
 	delete(r.metrics, name)
+	delete(r.observed, name)
+	delete(r.help, name)
+	delete(r.units, name)
+	delete(r.rates, name)
 }
 
 // Unregister all metrics.  (Mostly for testing.)
 func (r *StandardRegistry) UnregisterAll() {
 	r.mutex.Lock()
+	defer r.notify() // after the unlock
 	defer r.mutex.Unlock()
 	for name, _ := range r.metrics {
-		r.stop(name)
+		if _, ok := r.aliases[name]; !ok {
+			r.stop(name)
+		}
+		r.event(name, nil, false)
 		delete(r.metrics, name)
+		delete(r.observed, name)
+	}
+	r.aliases = make(map[string]string)
+	r.help = make(map[string]string)
+	r.units = make(map[string]string)
+	r.rates = make(map[string]float64)
+}
+
+// registerAll must be called with the mutex held, which is what makes
+// checking every name before registering any of them all-or-nothing.
+func (r *StandardRegistry) registerAll(metrics map[string]interface{}) error {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := r.metrics[name]; ok {
+			return DuplicateMetric(name)
+		}
+		if err := validateName(name); nil != err {
+			return err
+		}
+	}
+	for _, name := range names {
+		r.register(name, metrics[name])
 	}
+	return nil
 }
 
 func (r *StandardRegistry) register(name string, i interface{}) error {
 	if _, ok := r.metrics[name]; ok {
 		return DuplicateMetric(name)
 	}
+	if err := validateName(name); nil != err {
+		return err
+	}
 	switch i.(type) {
-	case Counter, Gauge, GaugeFloat64, Healthcheck, Histogram, Meter, Timer:
+	case Counter, DecimalCounter, Gauge, GaugeFloat64, Healthcheck, Histogram, Meter, Timer:
+		r.unstop(i)
 		r.metrics[name] = i
+		r.observed[name] = observation{updated: time.Now()}
+		r.event(name, i, true)
 	}
 	return nil
 }
 
-func (r *StandardRegistry) registered() map[string]interface{} {
+// OnRegister adds a listener which is called with the name and metric of
+// every metric subsequently registered, including by GetOrRegister and
+// Alias.  See OnUnregister.
+func (r *StandardRegistry) OnRegister(f func(name string, metric interface{})) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	metrics := make(map[string]interface{}, len(r.metrics))
-	for name, i := range r.metrics {
-		metrics[name] = i
+	r.onRegister = append(r.onRegister, f)
+	atomic.StoreInt32(&r.listening, 1)
+}
+
+// OnUnregister adds a listener which is called with the name of every
+// metric subsequently unregistered, including the aliases unregistered with
+// it.
+//
+// Listeners are called synchronously once the change has been made and the
+// registry's lock released, one at a time in the order the changes were
+// made and, for each change, in the order the listeners were added.  Every
+// listener for a change has been called by the time the call which made it
+// returns.  Listeners must not call back into the registry, which
+// deadlocks.
+func (r *StandardRegistry) OnUnregister(f func(name string)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.onUnregister = append(r.onUnregister, f)
+	atomic.StoreInt32(&r.listening, 1)
+}
+
+// event queues a change for delivery by notify.  It must be called with the
+// mutex held.
+func (r *StandardRegistry) event(name string, metric interface{}, registered bool) {
+	if registered && 0 != len(r.onRegister) || !registered && 0 != len(r.onUnregister) {
+		r.events = append(r.events, registryEvent{name, metric, registered})
 	}
-	return metrics
 }
 
-func (r *StandardRegistry) stop(name string) {
-	if i, ok := r.metrics[name]; ok {
-		if s, ok := i.(Stoppable); ok {
-			s.Stop()
+// notify delivers queued changes to the listeners.  It must be called
+// without the mutex held.
+func (r *StandardRegistry) notify() {
+	if 0 == atomic.LoadInt32(&r.listening) {
+		return
+	}
+	r.notifyMutex.Lock()
+	defer r.notifyMutex.Unlock()
+	r.mutex.Lock()
+	events := r.events
+	r.events = nil
+	onRegister, onUnregister := r.onRegister, r.onUnregister
+	r.mutex.Unlock()
+	for _, e := range events {
+		if e.registered {
+			for _, f := range onRegister {
+				f(e.name, e.metric)
+			}
+		} else {
+			for _, f := range onUnregister {
+				f(e.name)
+			}
 		}
 	}
 }
 
-// Stoppable defines the metrics which has to be stopped.
-type Stoppable interface {
-	Stop()
+// metricFingerprint returns a comparable summary of a metric's state which
+// changes whenever the metric is updated.
+func metricFingerprint(i interface{}) interface{} {
+	switch metric := i.(type) {
+	case Counter:
+		return metric.Count()
+	case DecimalCounter:
+		return math.Float64bits(metric.Count())
+	case Gauge:
+		return metric.Value()
+	case GaugeFloat64:
+		return math.Float64bits(metric.Value())
+	case Healthcheck:
+		if err := metric.Error(); nil != err {
+			return err.Error()
+		}
+		return ""
+	case Histogram:
+		h := metric.Snapshot()
+		return [2]int64{h.Count(), h.Sum()}
+	case Meter:
+		return metric.Count()
+	case Timer:
+		t := metric.Snapshot()
+		return [2]int64{t.Count(), t.Sum()}
+	}
+	return nil
 }
 
-type PrefixedRegistry struct {
-	underlying Registry
-	prefix     string
+// metricKinds are the interfaces which distinguish the kinds of metric a
+// registry holds, in the order of the type switch in register.
+var metricKinds = []reflect.Type{
+	reflect.TypeOf((*Counter)(nil)).Elem(),
+	reflect.TypeOf((*DecimalCounter)(nil)).Elem(),
+	reflect.TypeOf((*Gauge)(nil)).Elem(),
+	reflect.TypeOf((*GaugeFloat64)(nil)).Elem(),
+	reflect.TypeOf((*Healthcheck)(nil)).Elem(),
+	reflect.TypeOf((*Histogram)(nil)).Elem(),
+	reflect.TypeOf((*Meter)(nil)).Elem(),
+	reflect.TypeOf((*Timer)(nil)).Elem(),
 }
 
-func NewPrefixedRegistry(prefix string) Registry {
-	return &PrefixedRegistry{
-		underlying: NewRegistry(),
-		prefix:     prefix,
+// metricKind returns the first of metricKinds implemented by t, or nil.
+func metricKind(t reflect.Type) reflect.Type {
+	for _, kind := range metricKinds {
+		if t.Implements(kind) {
+			return kind
+		}
 	}
+	return nil
 }
 
-func NewPrefixedChildRegistry(parent Registry, prefix string) Registry {
-	return &PrefixedRegistry{
-		underlying: parent,
-		prefix:     prefix,
+// checkSupported returns an UnsupportedMetric if i is not of any of
+// metricKinds.
+func checkSupported(name string, i interface{}) error {
+	if t := reflect.TypeOf(i); nil == t || nil == metricKind(t) {
+		return UnsupportedMetric{Name: name, Metric: i}
 	}
+	return nil
 }
 
-// Call the given function for each registered metric.
-func (r *PrefixedRegistry) Each(fn func(string, interface{})) {
-	wrappedFn := func(prefix string) func(string, interface{}) {
-		return func(name string, iface interface{}) {
-			if strings.HasPrefix(name, prefix) {
-				fn(name, iface)
-			} else {
-				return
-			}
-		}
+// registerStrict registers i in r using r's RegisterStrict if it has one,
+// and otherwise checks i itself before using Register.
+func registerStrict(r Registry, name string, i interface{}) error {
+	if s, ok := r.(interface {
+		RegisterStrict(string, interface{}) error
+	}); ok {
+		return s.RegisterStrict(name, i)
+	}
+	if err := checkSupported(name, i); nil != err {
+		return err
 	}
+	return r.Register(name, i)
+}
 
-	baseRegistry, prefix := findPrefix(r, "")
-	baseRegistry.Each(wrappedFn(prefix))
+// getOrRegisterFunc calls r's GetOrRegisterFunc if it has one or falls back
+// to GetOrRegister.
+func getOrRegisterFunc(r Registry, name string, ctor func() interface{}) interface{} {
+	if f, ok := r.(interface {
+		GetOrRegisterFunc(string, func() interface{}) interface{}
+	}); ok {
+		return f.GetOrRegisterFunc(name, ctor)
+	}
+	return r.GetOrRegister(name, ctor)
 }
 
-func findPrefix(registry Registry, prefix string) (Registry, string) {
-	switch r := registry.(type) {
-	case *PrefixedRegistry:
-		return findPrefix(r.underlying, r.prefix+prefix)
-	case *StandardRegistry:
-		return r, prefix
+func getOrRegisterE(r Registry, name string, i interface{}) (interface{}, error) {
+	var requested reflect.Type
+	if t := reflect.TypeOf(i); nil != t {
+		if reflect.Func == t.Kind() && 1 == t.NumOut() {
+			t = t.Out(0)
+		}
+		requested = metricKind(t)
 	}
-	return nil, ""
+	if nil == r.Get(name) {
+		if err := validateName(name); nil != err {
+			return nil, err
+		}
+	}
+	metric := r.GetOrRegister(name, i)
+	if nil == requested || nil == metric {
+		return metric, nil
+	}
+	if existing := reflect.TypeOf(metric); requested != metricKind(existing) {
+		return nil, MetricTypeMismatch{Name: name, Existing: existing, Requested: requested}
+	}
+	return metric, nil
 }
 
-// Get the metric by the given name or nil if none is registered.
-func (r *PrefixedRegistry) Get(name string) interface{} {
-	realName := r.prefix + name
-	return r.underlying.Get(realName)
+func changedSince(r interface {
+	EachWithTime(func(string, interface{}, time.Time))
+}, t time.Time) []string {
+	var names []string
+	r.EachWithTime(func(name string, _ interface{}, updated time.Time) {
+		if !updated.Before(t) {
+			names = append(names, name)
+		}
+	})
+	sort.Strings(names)
+	return names
 }
 
-// Gets an existing metric or registers the given one.
-// The interface can be the metric to register if not found in registry,
-// or a function returning the metric for lazy instantiation.
-func (r *PrefixedRegistry) GetOrRegister(name string, metric interface{}) interface{} {
-	realName := r.prefix + name
-	return r.underlying.GetOrRegister(realName, metric)
+// changedFilter returns a function reporting whether the named metric has
+// changed since t, or nil if r cannot tell, in which case every metric
+// should be treated as changed.  Exporters use it to skip unchanged metrics,
+// calling it on every flush, including the first with a zero t, so that
+// EachWithTime keeps observing each metric's changes.
+func changedFilter(r Registry, t time.Time) func(string) bool {
+	c, ok := r.(interface {
+		ChangedSince(time.Time) []string
+	})
+	if !ok || !tracksUpdates(r) {
+		return nil
+	}
+	changed := make(map[string]bool)
+	for _, name := range c.ChangedSince(t) {
+		changed[name] = true
+	}
+	return func(name string) bool { return changed[name] }
 }
 
-// Register the given metric under the given name. The name will be prefixed.
+// tracksUpdates returns whether r tracks the times its metrics are updated,
+// which a PrefixedRegistry does only if its base registry does.
+func tracksUpdates(r Registry) bool {
+	if p, ok := r.(*PrefixedRegistry); ok {
+		r, _ = findPrefix(p, "")
+	}
+	_, ok := r.(interface {
+		EachWithTime(func(string, interface{}, time.Time))
+	})
+	return ok
+}
+
+// staleAfter returns the names of the metrics in r which EachWithTime reports
+// as not updated within d, sorted.
+func staleAfter(r interface {
+	EachWithTime(func(string, interface{}, time.Time))
+}, d time.Duration) []string {
+	var names []string
+	cutoff := time.Now().Add(-d)
+	r.EachWithTime(func(name string, _ interface{}, updated time.Time) {
+		if updated.Before(cutoff) {
+			names = append(names, name)
+		}
+	})
+	sort.Strings(names)
+	return names
+}
+
+func (r *StandardRegistry) registered() map[string]interface{} {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	metrics := make(map[string]interface{}, len(r.metrics))
+	for name, i := range r.metrics {
+		metrics[name] = i
+	}
+	return metrics
+}
+
+// hold returns a copy of the registry for an iteration, during which
+// stopping any metric unregistered is deferred.  Every call must be
+// followed by a call to release.
+func (r *StandardRegistry) hold() map[string]interface{} {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.iterating++
+	metrics := make(map[string]interface{}, len(r.metrics))
+	for name, i := range r.metrics {
+		metrics[name] = i
+	}
+	return metrics
+}
+
+// release ends an iteration begun by hold, stopping the metrics unregistered
+// meanwhile once no iteration is in progress.
+func (r *StandardRegistry) release() {
+	r.mutex.Lock()
+	r.iterating--
+	var stopping []Stoppable
+	if 0 == r.iterating {
+		stopping, r.stopping = r.stopping, nil
+	}
+	r.mutex.Unlock()
+	for _, s := range stopping {
+		s.Stop()
+	}
+}
+
+// stop must be called with the mutex held.
+func (r *StandardRegistry) stop(name string) {
+	if i, ok := r.metrics[name]; ok {
+		if s, ok := i.(Stoppable); ok {
+			if 0 < r.iterating {
+				r.stopping = append(r.stopping, s)
+			} else {
+				s.Stop()
+			}
+		}
+	}
+}
+
+// unstop cancels the deferred stop of a metric which is registered again
+// before the iterations which deferred it have finished.  It must be called
+// with the mutex held.
+func (r *StandardRegistry) unstop(i interface{}) {
+	if 0 == len(r.stopping) || !reflect.TypeOf(i).Comparable() {
+		return
+	}
+	for j, s := range r.stopping {
+		if s == i {
+			r.stopping = append(r.stopping[:j], r.stopping[j+1:]...)
+			return
+		}
+	}
+}
+
+// Stoppable defines the metrics which has to be stopped.
+type Stoppable interface {
+	Stop()
+}
+
+// BoundedRegistry is a StandardRegistry which refuses to hold more than a
+// fixed number of metrics, guarding against unbounded metric names.
+type BoundedRegistry struct {
+	*StandardRegistry
+	max int
+}
+
+// NewBoundedRegistry creates a new registry which holds at most max metrics.
+func NewBoundedRegistry(max int) Registry {
+	return &BoundedRegistry{
+		StandardRegistry: NewRegistry().(*StandardRegistry),
+		max:              max,
+	}
+}
+
+// Gets an existing metric or creates and registers a new one.  Once the
+// registry is full, new metrics are returned to the caller without being
+// registered, since GetOrRegister cannot return ErrTooManyMetrics.
+func (r *BoundedRegistry) GetOrRegister(name string, i interface{}) interface{} {
+	r.mutex.RLock()
+	metric, ok := r.metrics[name]
+	r.mutex.RUnlock()
+	if ok {
+		return metric
+	}
+
+	r.mutex.Lock()
+	defer r.notify() // after the unlock
+	defer r.mutex.Unlock()
+	if metric, ok := r.metrics[name]; ok {
+		return metric
+	}
+	if v := reflect.ValueOf(i); v.Kind() == reflect.Func {
+		i = v.Call(nil)[0].Interface()
+	}
+	if len(r.metrics) < r.max {
+		r.register(name, i)
+	}
+	return i
+}
+
+// GetOrRegisterFunc is like GetOrRegister but takes the constructor as a
+// func() interface{}.  See StandardRegistry.GetOrRegisterFunc.
+func (r *BoundedRegistry) GetOrRegisterFunc(name string, ctor func() interface{}) interface{} {
+	r.mutex.RLock()
+	metric, ok := r.metrics[name]
+	r.mutex.RUnlock()
+	if ok {
+		return metric
+	}
+
+	r.mutex.Lock()
+	defer r.notify() // after the unlock
+	defer r.mutex.Unlock()
+	if metric, ok := r.metrics[name]; ok {
+		return metric
+	}
+	i := ctor()
+	if len(r.metrics) < r.max {
+		r.register(name, i)
+	}
+	return i
+}
+
+// GetOrRegisterE is like GetOrRegister but returns a MetricTypeMismatch if
+// the metric already registered under the given name is of another kind.
+// See StandardRegistry.GetOrRegisterE.
+func (r *BoundedRegistry) GetOrRegisterE(name string, i interface{}) (interface{}, error) {
+	return getOrRegisterE(r, name, i)
+}
+
+// Register the given metric under the given name.  Returns a DuplicateMetric
+// if a metric by the given name is already registered or ErrTooManyMetrics
+// if the registry is full.
+func (r *BoundedRegistry) Register(name string, i interface{}) error {
+	r.mutex.Lock()
+	defer r.notify() // after the unlock
+	defer r.mutex.Unlock()
+	if _, ok := r.metrics[name]; !ok && len(r.metrics) >= r.max {
+		return ErrTooManyMetrics
+	}
+	return r.register(name, i)
+}
+
+// Alias registers the metric registered under the existing name under the
+// alias as well, returning ErrTooManyMetrics if the registry is full, since
+// the alias counts towards its maximum.  See StandardRegistry.Alias.
+func (r *BoundedRegistry) Alias(existing, alias string) error {
+	r.mutex.Lock()
+	defer r.notify() // after the unlock
+	defer r.mutex.Unlock()
+	if _, ok := r.metrics[alias]; !ok && len(r.metrics) >= r.max {
+		return ErrTooManyMetrics
+	}
+	return r.alias(existing, alias)
+}
+
+// RegisterStrict is like Register but returns an UnsupportedMetric rather
+// than ignoring a metric which is not of any kind the registry holds.
+func (r *BoundedRegistry) RegisterStrict(name string, i interface{}) error {
+	if err := checkSupported(name, i); nil != err {
+		return err
+	}
+	return r.Register(name, i)
+}
+
+// RegisterAll registers each of the given metrics under its name, or none
+// of them if any name is already registered or the registry cannot hold
+// them all, in which case it returns a DuplicateMetric or ErrTooManyMetrics.
+func (r *BoundedRegistry) RegisterAll(metrics map[string]interface{}) error {
+	r.mutex.Lock()
+	defer r.notify() // after the unlock
+	defer r.mutex.Unlock()
+	if len(r.metrics)+len(metrics) > r.max {
+		for name := range metrics {
+			if _, ok := r.metrics[name]; ok {
+				return DuplicateMetric(name)
+			}
+		}
+		return ErrTooManyMetrics
+	}
+	return r.registerAll(metrics)
+}
+
+// Scope returns a PrefixedRegistry registering metrics in this registry
+// under the given name followed by ScopeSeparator.
+func (r *BoundedRegistry) Scope(name string) *PrefixedRegistry {
+	return &PrefixedRegistry{underlying: r, prefix: name + ScopeSeparator}
+}
+
+type PrefixedRegistry struct {
+	underlying Registry
+	prefix     string
+	sep        string            // separator used by Scope, ScopeSeparator if empty
+	tags       map[string]string // tags encoded in every name
+	encode     TagEncoder
+}
+
+// A TagEncoder returns the name under which a metric with the given name and
+// tags is registered.  Given no tags it must return the name unchanged, and
+// it must not change the beginning of the name, so that a PrefixedRegistry
+// still finds its metrics by prefix.
+type TagEncoder func(name string, tags map[string]string) string
+
+// GraphiteTagEncoder encodes tags in the Graphite tagged series format
+// name;k1=v1;k2=v2, sorted by key.
+func GraphiteTagEncoder(name string, tags map[string]string) string {
+	for _, k := range sortedTagKeys(tags) {
+		name += ";" + k + "=" + tags[k]
+	}
+	return name
+}
+
+// DottedTagEncoder encodes tags as further dotted components of the name
+// name.k1.v1.k2.v2, sorted by key, for backends without tag support.
+func DottedTagEncoder(name string, tags map[string]string) string {
+	for _, k := range sortedTagKeys(tags) {
+		name += "." + k + "." + tags[k]
+	}
+	return name
+}
+
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func NewPrefixedRegistry(prefix string) Registry {
+	return &PrefixedRegistry{
+		underlying: NewRegistry(),
+		prefix:     prefix,
+	}
+}
+
+func NewPrefixedChildRegistry(parent Registry, prefix string) Registry {
+	return &PrefixedRegistry{
+		underlying: parent,
+		prefix:     prefix,
+	}
+}
+
+// NewPrefixedRegistryWithSep returns a PrefixedRegistry registering metrics
+// in parent, or in a new registry if parent is nil, under the given prefix
+// followed by sep.  Its scopes are joined by sep too, i.e.
+//
+//	NewPrefixedRegistryWithSep(r, "app", "_").Scope("http")
+//
+// registers metrics in r under the prefix "app_http_".
+func NewPrefixedRegistryWithSep(parent Registry, prefix, sep string) *PrefixedRegistry {
+	if nil == parent {
+		parent = NewRegistry()
+	}
+	return &PrefixedRegistry{
+		underlying: parent,
+		prefix:     prefix + sep,
+		sep:        sep,
+	}
+}
+
+// NewTaggedRegistry returns a PrefixedRegistry with no prefix registering
+// metrics in parent, or in a new registry if parent is nil, under names which
+// encode the given tags using encode, or GraphiteTagEncoder if encode is nil.
+// Further tags may be added with WithTags.
+func NewTaggedRegistry(parent Registry, tags map[string]string, encode TagEncoder) *PrefixedRegistry {
+	if nil == parent {
+		parent = NewRegistry()
+	}
+	return &PrefixedRegistry{
+		underlying: parent,
+		tags:       copyLabels(tags),
+		encode:     encode,
+	}
+}
+
+// Call the given function for each registered metric.
+func (r *PrefixedRegistry) Each(fn func(string, interface{})) {
+	wrappedFn := func(prefix string) func(string, interface{}) {
+		return func(name string, iface interface{}) {
+			if strings.HasPrefix(name, prefix) {
+				fn(name, iface)
+			} else {
+				return
+			}
+		}
+	}
+
+	baseRegistry, prefix := findPrefix(r, "")
+	if nil == baseRegistry {
+		return
+	}
+	baseRegistry.Each(wrappedFn(prefix))
+}
+
+// EachSorted calls the given function for each metric with the registry's
+// prefix in lexicographic order of name.  See StandardRegistry.EachSorted.
+func (r *PrefixedRegistry) EachSorted(fn func(string, interface{})) {
+	eachSorted(r, fn)
+}
+
+// EachMatching calls fn for each metric with the registry's prefix for which
+// pred returns true.  See StandardRegistry.EachMatching.
+func (r *PrefixedRegistry) EachMatching(pred func(name string, metric interface{}) bool, fn func(string, interface{})) {
+	eachMatching(r, pred, fn)
+}
+
+func findPrefix(registry Registry, prefix string) (Registry, string) {
+	switch r := registry.(type) {
+	case *PrefixedRegistry:
+		return findPrefix(r.underlying, r.prefix+prefix)
+	case *StandardRegistry:
+		return r, prefix
+	case *BoundedRegistry:
+		return r, prefix
+	case *mergedRegistry:
+		return r, prefix
+	case *readOnlyRegistry:
+		return findPrefix(r.underlying, prefix)
+	case NilRegistry, *NilRegistry:
+		return registry, prefix
+	}
+	return nil, ""
+}
+
+// Alias registers the metric registered under the existing name under the
+// alias as well.  Both names will be prefixed.  See StandardRegistry.Alias.
+// It returns ErrUnsupportedOperation if the underlying registry has no
+// Alias.
+func (r *PrefixedRegistry) Alias(existing, alias string) error {
+	a, ok := r.underlying.(interface {
+		Alias(string, string) error
+	})
+	if !ok {
+		return ErrUnsupportedOperation
+	}
+	return a.Alias(r.name(existing), r.name(alias))
+}
+
+// Describe attaches a description to the metric registered under the given
+// name, which will be prefixed.  See StandardRegistry.Describe.  It is a
+// no-op if the underlying registry cannot store descriptions.
+func (r *PrefixedRegistry) Describe(name, help string) {
+	if d, ok := r.underlying.(interface {
+		Describe(string, string)
+	}); ok {
+		d.Describe(r.name(name), help)
+	}
+}
+
+// EachWithTime calls the given function for each registered metric along
+// with the time it was last updated.  See StandardRegistry.EachWithTime.
+// Update times are only tracked by StandardRegistry and the registries built
+// on it; for any other underlying registry fn is called with the zero time,
+// so every metric appears never to have been updated.
+func (r *PrefixedRegistry) EachWithTime(fn func(string, interface{}, time.Time)) {
+	wrappedFn := func(prefix string) func(string, interface{}, time.Time) {
+		return func(name string, iface interface{}, updated time.Time) {
+			if strings.HasPrefix(name, prefix) {
+				fn(name, iface, updated)
+			}
+		}
+	}
+
+	baseRegistry, prefix := findPrefix(r, "")
+	if nil == baseRegistry {
+		return
+	}
+	if e, ok := baseRegistry.(interface {
+		EachWithTime(func(string, interface{}, time.Time))
+	}); ok {
+		e.EachWithTime(wrappedFn(prefix))
+		return
+	}
+	each := wrappedFn(prefix)
+	baseRegistry.Each(func(name string, iface interface{}) {
+		each(name, iface, time.Time{})
+	})
+}
+
+// Get the metric by the given name or nil if none is registered.
+func (r *PrefixedRegistry) Get(name string) interface{} {
+	realName := r.name(name)
+	return r.underlying.Get(realName)
+}
+
+// Help returns the description of the metric registered under the given
+// name, which will be prefixed.  See StandardRegistry.Help.
+func (r *PrefixedRegistry) Help(name string) string {
+	if h, ok := r.underlying.(interface {
+		Help(string) string
+	}); ok {
+		return h.Help(r.name(name))
+	}
+	return ""
+}
+
+// SetUnit attaches a unit to the metric registered under the given name,
+// which will be prefixed.  See StandardRegistry.SetUnit.  It is a no-op if
+// the underlying registry cannot store units.
+func (r *PrefixedRegistry) SetUnit(name, unit string) {
+	if u, ok := r.underlying.(interface {
+		SetUnit(string, string)
+	}); ok {
+		u.SetUnit(r.name(name), unit)
+	}
+}
+
+// Unit returns the unit of the metric registered under the given name,
+// which will be prefixed.  See StandardRegistry.Unit.
+func (r *PrefixedRegistry) Unit(name string) string {
+	if u, ok := r.underlying.(interface {
+		Unit(string) string
+	}); ok {
+		return u.Unit(r.name(name))
+	}
+	return ""
+}
+
+// SetSampleRate sets the sample rate of the metric registered under the
+// given name, which will be prefixed.  See StandardRegistry.SetSampleRate.
+// It is a no-op if the underlying registry cannot store sample rates.
+func (r *PrefixedRegistry) SetSampleRate(name string, rate float64) {
+	if s, ok := r.underlying.(interface {
+		SetSampleRate(string, float64)
+	}); ok {
+		s.SetSampleRate(r.name(name), rate)
+	}
+}
+
+// SampleRate returns the sample rate of the metric registered under the
+// given name, which will be prefixed.  See StandardRegistry.SampleRate.
+func (r *PrefixedRegistry) SampleRate(name string) float64 {
+	if s, ok := r.underlying.(interface {
+		SampleRate(string) float64
+	}); ok {
+		return s.SampleRate(r.name(name))
+	}
+	return 1
+}
+
+// Gets an existing metric or registers the given one.
+// The interface can be the metric to register if not found in registry,
+// or a function returning the metric for lazy instantiation.
+func (r *PrefixedRegistry) GetOrRegister(name string, metric interface{}) interface{} {
+	realName := r.name(name)
+	return r.underlying.GetOrRegister(realName, metric)
+}
+
+// GetOrRegisterFunc is like GetOrRegister but takes the constructor as a
+// func() interface{}.  The name will be prefixed.  See
+// StandardRegistry.GetOrRegisterFunc.
+func (r *PrefixedRegistry) GetOrRegisterFunc(name string, ctor func() interface{}) interface{} {
+	return getOrRegisterFunc(r.underlying, r.name(name), ctor)
+}
+
+// GetOrRegisterE is like GetOrRegister but returns a MetricTypeMismatch if
+// the metric already registered under the given name is of another kind.
+// The name will be prefixed.  See StandardRegistry.GetOrRegisterE.
+func (r *PrefixedRegistry) GetOrRegisterE(name string, metric interface{}) (interface{}, error) {
+	return getOrRegisterE(r.underlying, r.name(name), metric)
+}
+
+// Register the given metric under the given name. The name will be prefixed.
 func (r *PrefixedRegistry) Register(name string, metric interface{}) error {
-	realName := r.prefix + name
+	realName := r.name(name)
 	return r.underlying.Register(realName, metric)
 }
 
+// RegisterStrict is like Register but returns an UnsupportedMetric rather
+// than ignoring a metric which is not of any kind the registry holds.  The
+// name will be prefixed.
+func (r *PrefixedRegistry) RegisterStrict(name string, metric interface{}) error {
+	return registerStrict(r.underlying, r.name(name), metric)
+}
+
+// RegisterAll registers each of the given metrics under its name, or none of
+// them.  The names will be prefixed.  See StandardRegistry.RegisterAll.
+// It returns ErrUnsupportedOperation if the underlying registry has no
+// RegisterAll.
+func (r *PrefixedRegistry) RegisterAll(metrics map[string]interface{}) error {
+	prefixed := make(map[string]interface{}, len(metrics))
+	for name, metric := range metrics {
+		prefixed[r.name(name)] = metric
+	}
+	a, ok := r.underlying.(interface {
+		RegisterAll(map[string]interface{}) error
+	})
+	if !ok {
+		return ErrUnsupportedOperation
+	}
+	return a.RegisterAll(prefixed)
+}
+
 // Run all registered healthchecks.
 func (r *PrefixedRegistry) RunHealthchecks() {
 	r.underlying.RunHealthchecks()
@@ -309,17 +1483,494 @@ func (r *PrefixedRegistry) GetAll() map[string]map[string]interface{} {
 	return r.underlying.GetAll()
 }
 
+// Scope returns a PrefixedRegistry whose prefix is this registry's prefix
+// followed by the given name and separator, so that
+//
+//	r.Scope("app").Scope("http").Scope("handler")
+//
+// registers metrics in r under the prefix "app.http.handler.".  The
+// separator is the one given to NewPrefixedRegistryWithSep, or
+// ScopeSeparator otherwise, and is also inserted between a non-empty prefix
+// which does not already end with it and the name.  The scope carries this
+// registry's tags.  It returns a *PrefixedRegistry rather than a Registry so
+// that scopes can be nested without type assertions.
+func (r *PrefixedRegistry) Scope(name string) *PrefixedRegistry {
+	sep := r.sep
+	if "" == sep {
+		sep = ScopeSeparator
+	}
+	prefix := r.prefix
+	if "" != prefix && !strings.HasSuffix(prefix, sep) {
+		prefix += sep
+	}
+	return &PrefixedRegistry{
+		underlying: r.underlying,
+		prefix:     prefix + name + sep,
+		sep:        r.sep,
+		tags:       r.tags,
+		encode:     r.encode,
+	}
+}
+
+// WithTags returns a PrefixedRegistry with the same prefix whose names also
+// encode the union of this registry's tags and the given tags, the latter
+// taking precedence.  The names are encoded by the registry's TagEncoder, or
+// GraphiteTagEncoder if it has none.
+func (r *PrefixedRegistry) WithTags(tags map[string]string) *PrefixedRegistry {
+	merged := copyLabels(r.tags)
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return &PrefixedRegistry{
+		underlying: r.underlying,
+		prefix:     r.prefix,
+		sep:        r.sep,
+		tags:       merged,
+		encode:     r.encode,
+	}
+}
+
+// name returns the name under which the given name is registered in the
+// underlying registry.
+func (r *PrefixedRegistry) name(name string) string {
+	if 0 == len(r.tags) {
+		return r.prefix + name
+	}
+	encode := r.encode
+	if nil == encode {
+		encode = GraphiteTagEncoder
+	}
+	return encode(r.prefix+name, r.tags)
+}
+
+// ChangedSince returns the names of the metrics which have been registered
+// or updated since t, as seen by EachWithTime.  See
+// StandardRegistry.ChangedSince.
+func (r *PrefixedRegistry) ChangedSince(t time.Time) []string {
+	return changedSince(r, t)
+}
+
+// StaleAfter returns the names of the metrics which have not been updated
+// within d, as seen by EachWithTime.  See StandardRegistry.StaleAfter.
+func (r *PrefixedRegistry) StaleAfter(d time.Duration) []string {
+	return staleAfter(r, d)
+}
+
 // Unregister the metric with the given name. The name will be prefixed.
 func (r *PrefixedRegistry) Unregister(name string) {
-	realName := r.prefix + name
+	realName := r.name(name)
 	r.underlying.Unregister(realName)
 }
 
+// UnregisterMatching unregisters every metric whose name, which will be
+// prefixed, matches the given glob pattern.  See
+// StandardRegistry.UnregisterMatching.
+func (r *PrefixedRegistry) UnregisterMatching(pattern string) int {
+	if u, ok := r.underlying.(interface {
+		UnregisterMatching(string) int
+	}); ok {
+		return u.UnregisterMatching(globEscaper.Replace(r.prefix) + pattern)
+	}
+	return 0
+}
+
+// globEscaper escapes the characters which are special in path.Match.
+var globEscaper = strings.NewReplacer("\\", "\\\\", "*", "\\*", "?", "\\?", "[", "\\[")
+
 // Unregister all metrics.  (Mostly for testing.)
 func (r *PrefixedRegistry) UnregisterAll() {
 	r.underlying.UnregisterAll()
 }
 
+// mergedRegistry is a read-only view of the union of several registries.
+type mergedRegistry struct {
+	registries []Registry
+}
+
+// MergedRegistry returns a read-only Registry presenting the union of the
+// metrics in the given registries, which remain independently writable.
+// Where a name is registered in more than one registry, the metric in the
+// later registry wins.  Register returns ErrReadOnlyRegistry, GetOrRegister
+// returns the given metric without registering it if the name is not found,
+// and Unregister and UnregisterAll are no-ops.
+func MergedRegistry(regs ...Registry) Registry {
+	return &mergedRegistry{registries: regs}
+}
+
+// Call the given function for each metric in the union.
+func (r *mergedRegistry) Each(f func(string, interface{})) {
+	for name, i := range r.merged() {
+		f(name, i)
+	}
+}
+
+// EachSorted calls the given function for each metric as seen by Each in
+// lexicographic order of name.
+func (r *mergedRegistry) EachSorted(f func(string, interface{})) {
+	eachSorted(r, f)
+}
+
+// EachMatching calls f for each metric as seen by Each for which pred
+// returns true.
+func (r *mergedRegistry) EachMatching(pred func(name string, metric interface{}) bool, f func(string, interface{})) {
+	eachMatching(r, pred, f)
+}
+
+// EachWithTime calls the given function for each metric in the union along
+// with the time it was last updated, as reported by the registry it is taken
+// from.  See StandardRegistry.EachWithTime.  Metrics from registries which do
+// not track update times are reported with the zero time.
+func (r *mergedRegistry) EachWithTime(f func(string, interface{}, time.Time)) {
+	type timed struct {
+		metric  interface{}
+		updated time.Time
+	}
+	metrics := make(map[string]timed)
+	for _, registry := range r.registries {
+		if e, ok := registry.(interface {
+			EachWithTime(func(string, interface{}, time.Time))
+		}); ok {
+			e.EachWithTime(func(name string, i interface{}, updated time.Time) {
+				metrics[name] = timed{i, updated}
+			})
+			continue
+		}
+		registry.Each(func(name string, i interface{}) {
+			metrics[name] = timed{metric: i}
+		})
+	}
+	for name, m := range metrics {
+		f(name, m.metric, m.updated)
+	}
+}
+
+// ChangedSince returns the names of the metrics in the union which have been
+// registered or updated since t, as seen by EachWithTime, in sorted order.
+// See StandardRegistry.ChangedSince.
+func (r *mergedRegistry) ChangedSince(t time.Time) []string {
+	return changedSince(r, t)
+}
+
+// StaleAfter returns the names of the metrics in the union which have not
+// been updated within d, as seen by EachWithTime.  See
+// StandardRegistry.StaleAfter.
+func (r *mergedRegistry) StaleAfter(d time.Duration) []string {
+	return staleAfter(r, d)
+}
+
+// Get the metric by the given name from the last registry which has it, or
+// nil if none has.
+func (r *mergedRegistry) Get(name string) interface{} {
+	for i := len(r.registries) - 1; i >= 0; i-- {
+		if metric := r.registries[i].Get(name); nil != metric {
+			return metric
+		}
+	}
+	return nil
+}
+
+// GetAll metrics in the union.
+func (r *mergedRegistry) GetAll() map[string]map[string]interface{} {
+	data := make(map[string]map[string]interface{})
+	for _, registry := range r.registries {
+		for name, values := range registry.GetAll() {
+			data[name] = values
+		}
+	}
+	return data
+}
+
+// Gets an existing metric or returns the given one without registering it.
+func (r *mergedRegistry) GetOrRegister(name string, i interface{}) interface{} {
+	if metric := r.Get(name); nil != metric {
+		return metric
+	}
+	if v := reflect.ValueOf(i); v.Kind() == reflect.Func {
+		i = v.Call(nil)[0].Interface()
+	}
+	return i
+}
+
+// Help returns the description of the named metric in the last registry
+// which describes it.
+func (r *mergedRegistry) Help(name string) string {
+	for i := len(r.registries) - 1; i >= 0; i-- {
+		if h, ok := r.registries[i].(interface {
+			Help(string) string
+		}); ok {
+			if help := h.Help(name); "" != help {
+				return help
+			}
+		}
+	}
+	return ""
+}
+
+// Unit returns the unit of the named metric in the last registry which sets
+// one.
+func (r *mergedRegistry) Unit(name string) string {
+	for i := len(r.registries) - 1; i >= 0; i-- {
+		if u, ok := r.registries[i].(interface {
+			Unit(string) string
+		}); ok {
+			if unit := u.Unit(name); "" != unit {
+				return unit
+			}
+		}
+	}
+	return ""
+}
+
+// SampleRate returns the sample rate of the named metric in the last
+// registry which sets one other than one.
+func (r *mergedRegistry) SampleRate(name string) float64 {
+	for i := len(r.registries) - 1; i >= 0; i-- {
+		if s, ok := r.registries[i].(interface {
+			SampleRate(string) float64
+		}); ok {
+			if rate := s.SampleRate(name); 1 != rate {
+				return rate
+			}
+		}
+	}
+	return 1
+}
+
+// Register returns ErrReadOnlyRegistry.
+func (r *mergedRegistry) Register(string, interface{}) error {
+	return ErrReadOnlyRegistry
+}
+
+// RegisterAll returns ErrReadOnlyRegistry.
+func (r *mergedRegistry) RegisterAll(map[string]interface{}) error {
+	return ErrReadOnlyRegistry
+}
+
+// Run all healthchecks in every registry.
+func (r *mergedRegistry) RunHealthchecks() {
+	for _, registry := range r.registries {
+		registry.RunHealthchecks()
+	}
+}
+
+// Unregister is a no-op.
+func (r *mergedRegistry) Unregister(string) {}
+
+// UnregisterAll is a no-op.
+func (r *mergedRegistry) UnregisterAll() {}
+
+func (r *mergedRegistry) merged() map[string]interface{} {
+	metrics := make(map[string]interface{})
+	for _, registry := range r.registries {
+		registry.Each(func(name string, i interface{}) {
+			metrics[name] = i
+		})
+	}
+	return metrics
+}
+
+// readOnlyRegistry is a read-only view of another registry.
+type readOnlyRegistry struct {
+	underlying Registry
+}
+
+// ReadOnly returns a Registry through which the metrics in the given
+// registry may be read but not registered or unregistered, such as to hand
+// to a plugin.  Register and RegisterAll return ErrReadOnlyRegistry, and
+// GetOrRegister of a name which is not registered, Unregister and
+// UnregisterAll panic with it.  The metrics themselves remain writable.
+func ReadOnly(r Registry) Registry {
+	return &readOnlyRegistry{underlying: r}
+}
+
+// Call the given function for each metric in the underlying registry.
+func (r *readOnlyRegistry) Each(f func(string, interface{})) {
+	r.underlying.Each(f)
+}
+
+// EachSorted calls the given function for each metric in lexicographic
+// order of name.
+func (r *readOnlyRegistry) EachSorted(f func(string, interface{})) {
+	eachSorted(r, f)
+}
+
+// EachMatching calls f for each metric for which pred returns true.
+func (r *readOnlyRegistry) EachMatching(pred func(name string, metric interface{}) bool, f func(string, interface{})) {
+	eachMatching(r, pred, f)
+}
+
+// Get the metric by the given name or nil if none is registered.
+func (r *readOnlyRegistry) Get(name string) interface{} {
+	return r.underlying.Get(name)
+}
+
+// GetAll metrics in the underlying registry.
+func (r *readOnlyRegistry) GetAll() map[string]map[string]interface{} {
+	return r.underlying.GetAll()
+}
+
+// Gets an existing metric, panicking with ErrReadOnlyRegistry if none is
+// registered under the given name.
+func (r *readOnlyRegistry) GetOrRegister(name string, _ interface{}) interface{} {
+	if metric := r.underlying.Get(name); nil != metric {
+		return metric
+	}
+	panic(ErrReadOnlyRegistry)
+}
+
+// Help returns the description of the named metric.  See
+// StandardRegistry.Help.
+func (r *readOnlyRegistry) Help(name string) string {
+	if h, ok := r.underlying.(interface {
+		Help(string) string
+	}); ok {
+		return h.Help(name)
+	}
+	return ""
+}
+
+// Unit returns the unit of the named metric.  See StandardRegistry.Unit.
+func (r *readOnlyRegistry) Unit(name string) string {
+	if u, ok := r.underlying.(interface {
+		Unit(string) string
+	}); ok {
+		return u.Unit(name)
+	}
+	return ""
+}
+
+// SampleRate returns the sample rate of the named metric.  See
+// StandardRegistry.SampleRate.
+func (r *readOnlyRegistry) SampleRate(name string) float64 {
+	if s, ok := r.underlying.(interface {
+		SampleRate(string) float64
+	}); ok {
+		return s.SampleRate(name)
+	}
+	return 1
+}
+
+// Register returns ErrReadOnlyRegistry.
+func (r *readOnlyRegistry) Register(string, interface{}) error {
+	return ErrReadOnlyRegistry
+}
+
+// RegisterAll returns ErrReadOnlyRegistry.
+func (r *readOnlyRegistry) RegisterAll(map[string]interface{}) error {
+	return ErrReadOnlyRegistry
+}
+
+// Run all registered healthchecks.
+func (r *readOnlyRegistry) RunHealthchecks() {
+	r.underlying.RunHealthchecks()
+}
+
+// Unregister panics with ErrReadOnlyRegistry.
+func (r *readOnlyRegistry) Unregister(string) {
+	panic(ErrReadOnlyRegistry)
+}
+
+// UnregisterAll panics with ErrReadOnlyRegistry.
+func (r *readOnlyRegistry) UnregisterAll() {
+	panic(ErrReadOnlyRegistry)
+}
+
+// NilRegistry is a Registry which holds no metrics and hands out no-op
+// metrics from GetOrRegister, so that metrics may be disabled by swapping in
+// a NilRegistry rather than by checking whether they are enabled.
+type NilRegistry struct{}
+
+// nilMetrics are the no-op metrics handed out by NilRegistry.GetOrRegister,
+// by the type they are requested as.
+var nilMetrics = map[reflect.Type]interface{}{
+	reflect.TypeOf((*Counter)(nil)).Elem():           NilCounter{},
+	reflect.TypeOf((*CustomMeter)(nil)).Elem():       NilCustomMeter{},
+	reflect.TypeOf((*DecimalCounter)(nil)).Elem():    NilDecimalCounter{},
+	reflect.TypeOf((*ExemplarHistogram)(nil)).Elem(): NilExemplarHistogram{},
+	reflect.TypeOf((*Gauge)(nil)).Elem():             NilGauge{},
+	reflect.TypeOf((*GaugeFloat64)(nil)).Elem():      NilGaugeFloat64{},
+	reflect.TypeOf((*Healthcheck)(nil)).Elem():       NilHealthcheck{},
+	reflect.TypeOf((*Histogram)(nil)).Elem():         NilHistogram{},
+	reflect.TypeOf((*LabeledCounter)(nil)).Elem():    NilLabeledCounter{},
+	reflect.TypeOf((*Meter)(nil)).Elem():             NilMeter{},
+	reflect.TypeOf((*Timer)(nil)).Elem():             NilTimer{},
+}
+
+// NewNilRegistry constructs a new NilRegistry.
+func NewNilRegistry() Registry {
+	return NilRegistry{}
+}
+
+// Each is a no-op.
+func (NilRegistry) Each(func(string, interface{})) {}
+
+// Get always returns nil.
+func (NilRegistry) Get(string) interface{} { return nil }
+
+// GetAll returns an empty map.
+func (NilRegistry) GetAll() map[string]map[string]interface{} {
+	return make(map[string]map[string]interface{})
+}
+
+// GetOrRegister returns the no-op metric, such as NilCounter, of the most
+// specific kind of the given metric or of the type returned by the given
+// function, which is not called.  A metric of no known kind, such as one
+// which a function returns as a concrete type, is returned or constructed
+// but not registered.
+func (NilRegistry) GetOrRegister(name string, i interface{}) interface{} {
+	t := reflect.TypeOf(i)
+	if nil == t {
+		return nil
+	}
+	if reflect.Func != t.Kind() {
+		if m := nilMetricOf(t); nil != m {
+			return m
+		}
+		return i
+	}
+	if 1 != t.NumOut() {
+		return nil
+	}
+	if m, ok := nilMetrics[t.Out(0)]; ok {
+		return m
+	}
+	return reflect.ValueOf(i).Call(nil)[0].Interface()
+}
+
+// nilMetricOf returns the no-op metric of the most specific kind which the
+// given type implements, such as NilLabeledCounter rather than NilCounter for
+// a LabeledCounter, or nil if there is no one such kind.
+func nilMetricOf(t reflect.Type) interface{} {
+	var kind reflect.Type
+	for k := range nilMetrics {
+		if !t.Implements(k) {
+			continue
+		}
+		switch {
+		case nil == kind || k.Implements(kind):
+			kind = k
+		case !kind.Implements(k):
+			return nil // implements two unrelated kinds
+		}
+	}
+	if nil == kind {
+		return nil
+	}
+	return nilMetrics[kind]
+}
+
+// Register is a no-op.
+func (NilRegistry) Register(string, interface{}) error { return nil }
+
+// RunHealthchecks is a no-op.
+func (NilRegistry) RunHealthchecks() {}
+
+// Unregister is a no-op.
+func (NilRegistry) Unregister(string) {}
+
+// UnregisterAll is a no-op.
+func (NilRegistry) UnregisterAll() {}
+
 var DefaultRegistry Registry = NewRegistry()
 
 // Call the given function for each registered metric.
@@ -338,20 +1989,35 @@ func GetOrRegister(name string, i interface{}) interface{} {
 	return DefaultRegistry.GetOrRegister(name, i)
 }
 
+// GetOrRegisterFunc gets an existing metric or calls ctor and registers the
+// metric it returns, without the reflection GetOrRegister uses.
+func GetOrRegisterFunc(name string, ctor func() interface{}) interface{} {
+	return getOrRegisterFunc(DefaultRegistry, name, ctor)
+}
+
 // Register the given metric under the given name.  Returns a DuplicateMetric
 // if a metric by the given name is already registered.
 func Register(name string, i interface{}) error {
 	return DefaultRegistry.Register(name, i)
 }
 
-// Register the given metric under the given name.  Panics if a metric by the
-// given name is already registered.
+// Register the given metric under the given name.  Panics with a
+// DuplicateMetric if a metric by the given name is already registered and
+// with an UnsupportedMetric if the metric is not of any kind the registry
+// holds.
 func MustRegister(name string, i interface{}) {
-	if err := Register(name, i); err != nil {
+	if err := RegisterStrict(name, i); err != nil {
 		panic(err)
 	}
 }
 
+// RegisterStrict registers the given metric under the given name like
+// Register, but returns an UnsupportedMetric rather than ignoring a metric
+// which is not of any kind the registry holds.
+func RegisterStrict(name string, i interface{}) error {
+	return registerStrict(DefaultRegistry, name, i)
+}
+
 // Run all registered healthchecks.
 func RunHealthchecks() {
 	DefaultRegistry.RunHealthchecks()
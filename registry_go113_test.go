@@ -0,0 +1,30 @@
+//go:build go1.13
+// +build go1.13
+
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDuplicateMetricIs(t *testing.T) {
+	r := NewRegistry()
+	r.Register("foo", NewCounter())
+	err := r.Register("foo", NewCounter())
+	if !errors.Is(err, ErrDuplicateMetric) {
+		t.Fatal(err)
+	}
+	wrapped := fmt.Errorf("registering: %w", err)
+	if !errors.Is(wrapped, ErrDuplicateMetric) {
+		t.Fatal(wrapped)
+	}
+	var dup DuplicateMetric
+	if !errors.As(wrapped, &dup) || "foo" != dup.Name() {
+		t.Fatal(wrapped)
+	}
+	if errors.Is(ErrTooManyMetrics, ErrDuplicateMetric) {
+		t.Fatal(ErrTooManyMetrics)
+	}
+}
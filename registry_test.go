@@ -1,8 +1,13 @@
 package metrics
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func BenchmarkRegistry(b *testing.B) {
@@ -24,6 +29,52 @@ func BenchmarkRegistryParallel(b *testing.B) {
 	})
 }
 
+func BenchmarkRegistryGetOrRegisterHit(b *testing.B) {
+	r := NewRegistry()
+	r.Register("foo", NewCounter())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.GetOrRegister("foo", NewCounter)
+	}
+}
+
+func BenchmarkRegistryGetOrRegisterFuncHit(b *testing.B) {
+	r := NewRegistry().(*StandardRegistry)
+	r.Register("foo", NewCounter())
+	ctor := func() interface{} { return NewCounter() }
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.GetOrRegisterFunc("foo", ctor)
+	}
+}
+
+func BenchmarkRegistryGetOrRegisterMiss(b *testing.B) {
+	r := NewRegistry()
+	names := benchmarkNames(b.N)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.GetOrRegister(names[i], NewCounter)
+	}
+}
+
+func BenchmarkRegistryGetOrRegisterFuncMiss(b *testing.B) {
+	r := NewRegistry().(*StandardRegistry)
+	names := benchmarkNames(b.N)
+	ctor := func() interface{} { return NewCounter() }
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.GetOrRegisterFunc(names[i], ctor)
+	}
+}
+
+func benchmarkNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("foo%d", i)
+	}
+	return names
+}
+
 func TestRegistry(t *testing.T) {
 	r := NewRegistry()
 	r.Register("foo", NewCounter())
@@ -80,6 +131,49 @@ func TestRegistryGet(t *testing.T) {
 	}
 }
 
+func TestRegistryGetTyped(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("counter", r).Inc(47)
+	r.Register("gauge", NewGauge())
+	r.Register("timer", NewTimer())
+	if c := GetCounter("counter", r); nil == c || 47 != c.Count() {
+		t.Fatal(c)
+	}
+	if g := GetGauge("gauge", r); nil == g {
+		t.Fatal(g)
+	}
+	if tm := GetTimer("timer", r); nil == tm {
+		t.Fatal(tm)
+	}
+}
+
+func TestRegistryGetTypedAbsent(t *testing.T) {
+	r := NewRegistry()
+	if c := GetCounter("foo", r); nil != c {
+		t.Fatal(c)
+	}
+	if h := GetHistogram("foo", r); nil != h {
+		t.Fatal(h)
+	}
+	if m := GetMeter("foo", r); nil != m {
+		t.Fatal(m)
+	}
+}
+
+func TestRegistryGetTypedWrongType(t *testing.T) {
+	r := NewRegistry()
+	r.Register("foo", NewGauge())
+	if c := GetCounter("foo", r); nil != c {
+		t.Fatal(c)
+	}
+	if g := GetGaugeFloat64("foo", r); nil != g {
+		t.Fatal(g)
+	}
+	if tm := GetTimer("foo", r); nil != tm {
+		t.Fatal(tm)
+	}
+}
+
 func TestRegistryGetOrRegister(t *testing.T) {
 	r := NewRegistry()
 
@@ -105,6 +199,37 @@ func TestRegistryGetOrRegister(t *testing.T) {
 	}
 }
 
+func TestRegistryGetOrRegisterFunc(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	calls := 0
+	ctor := func() interface{} {
+		calls++
+		return NewCounter()
+	}
+	c := r.GetOrRegisterFunc("foo", ctor)
+	if _, ok := c.(Counter); !ok {
+		t.Fatal(c)
+	}
+	if m := r.GetOrRegisterFunc("foo", ctor); m != c {
+		t.Fatal(m)
+	}
+	if 1 != calls {
+		t.Errorf("calls: 1 != %v\n", calls)
+	}
+	if m := r.Get("foo"); m != c {
+		t.Fatal(m)
+	}
+}
+
+func TestPrefixedRegistryGetOrRegisterFunc(t *testing.T) {
+	r := NewRegistry()
+	p := NewPrefixedChildRegistry(r, "prefix.").(*PrefixedRegistry)
+	c := p.GetOrRegisterFunc("foo", func() interface{} { return NewCounter() })
+	if m := r.Get("prefix.foo"); m != c {
+		t.Fatal(m)
+	}
+}
+
 func TestRegistryGetOrRegisterWithLazyInstantiation(t *testing.T) {
 	r := NewRegistry()
 
@@ -147,6 +272,88 @@ func TestRegistryUnregister(t *testing.T) {
 	}
 }
 
+func TestRegistryUnregisterAllStopsMeters(t *testing.T) {
+	l := len(arbiter.meters)
+	r := NewRegistry()
+	GetOrRegisterMeter("foo", r)
+	GetOrRegisterTimer("bar", r)
+	if len(arbiter.meters) != l+2 {
+		t.Errorf("arbiter.meters: %d != %d\n", l+2, len(arbiter.meters))
+	}
+	r.UnregisterAll()
+	if len(arbiter.meters) != l {
+		t.Errorf("arbiter.meters: %d != %d\n", l, len(arbiter.meters))
+	}
+}
+
+func TestRegistryEachDefersStop(t *testing.T) {
+	r := NewRegistry()
+	m := NewRegisteredMeter("foo", r).(*StandardMeter)
+	r.Each(func(name string, i interface{}) {
+		r.Unregister(name)
+		if 0 != atomic.LoadUint32(&m.stopped) {
+			t.Fatal("meter stopped during Each")
+		}
+		i.(Meter).Mark(1)
+	})
+	if 1 != atomic.LoadUint32(&m.stopped) {
+		t.Fatal("meter not stopped after Each")
+	}
+	if count := m.Count(); 1 != count {
+		t.Errorf("m.Count(): 1 != %v\n", count)
+	}
+}
+
+func TestRegistryEachReregisterCancelsStop(t *testing.T) {
+	r := NewRegistry()
+	m := NewRegisteredMeter("foo", r).(*StandardMeter)
+	r.Each(func(name string, i interface{}) {
+		r.Unregister(name)
+		r.Register(name, i)
+	})
+	if 0 != atomic.LoadUint32(&m.stopped) {
+		t.Fatal("re-registered meter stopped")
+	}
+	r.UnregisterAll()
+}
+
+func TestRegistryEachUnregisterAllRace(t *testing.T) {
+	r := NewRegistry()
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				r.Each(func(name string, i interface{}) {
+					switch m := i.(type) {
+					case Meter:
+						m.Mark(1)
+						m.Snapshot().Rate1()
+					case Timer:
+						m.Update(time.Millisecond)
+						m.Snapshot().Percentile(0.5)
+					}
+				})
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		GetOrRegisterMeter("meter", r)
+		GetOrRegisterTimer("timer", r)
+		r.UnregisterAll()
+	}
+	close(done)
+	wg.Wait()
+	r.UnregisterAll()
+}
+
 func TestPrefixedChildRegistryGetOrRegister(t *testing.T) {
 	r := NewRegistry()
 	pr := NewPrefixedChildRegistry(r, "prefix.")
@@ -361,3 +568,1005 @@ func TestConcurrentRegistryAccess(t *testing.T) {
 		t.Fatal(i)
 	}
 }
+
+func TestRegistrySnapshot(t *testing.T) {
+	r := NewRegistry()
+	c := NewRegisteredCounter("counter", r)
+	c.Inc(47)
+	tm := NewRegisteredTimer("timer", r)
+	defer tm.Stop()
+	tm.Update(10)
+	tm.Update(20)
+	l := NewRegisteredLabeledCounter("labeled", r, nil)
+	l.With(map[string]string{"code": "200"}).Inc(1)
+
+	s := Snapshot(r)
+	c.Inc(1)
+	tm.Update(30)
+	l.With(map[string]string{"code": "500"}).Inc(1)
+
+	if count := s.Get("counter").(Counter).Count(); 47 != count {
+		t.Errorf("counter: 47 != %v\n", count)
+	}
+	st := s.Get("timer").(Timer)
+	if count := st.Count(); 2 != count {
+		t.Errorf("timer count: 2 != %v\n", count)
+	}
+	if sum := st.Sum(); 30 != sum {
+		t.Errorf("timer sum: 30 != %v\n", sum)
+	}
+	i := 0
+	s.Get("labeled").(LabeledCounter).Each(func(labels map[string]string, c Counter) {
+		i++
+		if "200" != labels["code"] {
+			t.Fatal(labels)
+		}
+	})
+	if 1 != i {
+		t.Fatal(i)
+	}
+}
+
+func TestBoundedRegistry(t *testing.T) {
+	r := NewBoundedRegistry(2)
+	if err := r.Register("foo", NewCounter()); nil != err {
+		t.Fatal(err)
+	}
+	c := GetOrRegisterCounter("bar", r)
+	if err := r.Register("baz", NewCounter()); ErrTooManyMetrics != err {
+		t.Fatal(err)
+	}
+	if err := r.Register("foo", NewCounter()); ErrTooManyMetrics == err || nil == err {
+		t.Fatal(err)
+	}
+	if GetOrRegisterCounter("bar", r) != c {
+		t.Fatal("GetOrRegister on a registered name returned a new metric")
+	}
+	if nil == GetOrRegisterCounter("baz", r) || nil != r.Get("baz") {
+		t.Fatal(r.Get("baz"))
+	}
+	if l := r.(*BoundedRegistry).Len(); 2 != l {
+		t.Errorf("r.Len(): 2 != %v\n", l)
+	}
+	r.Unregister("foo")
+	if err := r.Register("baz", NewCounter()); nil != err {
+		t.Fatal(err)
+	}
+}
+
+func TestBoundedRegistryPrefixed(t *testing.T) {
+	r := NewPrefixedChildRegistry(NewBoundedRegistry(1), "prefix.")
+	r.Register("foo", NewCounter())
+	i := 0
+	r.Each(func(name string, m interface{}) {
+		i++
+		if "prefix.foo" != name {
+			t.Fatal(name)
+		}
+	})
+	if 1 != i {
+		t.Fatal(i)
+	}
+}
+
+func TestBoundedRegistryAlias(t *testing.T) {
+	r := NewBoundedRegistry(2).(*BoundedRegistry)
+	NewRegisteredCounter("foo", r)
+	if err := r.Alias("foo", "bar"); nil != err {
+		t.Fatal(err)
+	}
+	if err := r.Alias("foo", "baz"); ErrTooManyMetrics != err {
+		t.Fatal(err)
+	}
+	if err := r.Alias("foo", "bar"); nil == err || ErrTooManyMetrics == err {
+		t.Fatal(err)
+	}
+	if l := r.Len(); 2 != l {
+		t.Errorf("r.Len(): 2 != %v\n", l)
+	}
+	p := NewPrefixedChildRegistry(r, "").(*PrefixedRegistry)
+	if err := p.Alias("foo", "qux"); ErrTooManyMetrics != err {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistryEachWithTime(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	c := NewRegisteredCounter("foo", r)
+	NewRegisteredGauge("bar", r)
+	registered, barRegistered := r.observed["foo"].updated, r.observed["bar"].updated
+	times := func() map[string]time.Time {
+		m := make(map[string]time.Time)
+		r.EachWithTime(func(name string, i interface{}, updated time.Time) {
+			m[name] = updated
+		})
+		return m
+	}
+	if m := times(); 2 != len(m) || registered != m["foo"] {
+		t.Fatal(m)
+	}
+	time.Sleep(time.Millisecond)
+	c.Inc(1)
+	if m := times(); !m["foo"].After(registered) || barRegistered != m["bar"] {
+		t.Fatal(m)
+	}
+	updated := r.observed["foo"].updated
+	if m := times(); updated != m["foo"] {
+		t.Fatal(m)
+	}
+	r.Unregister("foo")
+	if _, ok := r.observed["foo"]; ok {
+		t.Fatal(r.observed)
+	}
+}
+
+func TestRegistryStaleAfter(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	c := NewRegisteredCounter("foo", r)
+	NewRegisteredGauge("bar", r)
+	NewRegisteredMeter("baz", r)
+	for name, o := range r.observed {
+		o.updated = o.updated.Add(-time.Hour)
+		r.observed[name] = o
+	}
+	r.EachWithTime(func(string, interface{}, time.Time) {})
+	c.Inc(1)
+	if names := r.StaleAfter(time.Minute); 2 != len(names) || "bar" != names[0] || "baz" != names[1] {
+		t.Fatal(names)
+	}
+	p := NewPrefixedChildRegistry(r, "b").(*PrefixedRegistry)
+	if names := p.StaleAfter(time.Minute); 2 != len(names) {
+		t.Fatal(names)
+	}
+}
+
+func TestPrefixedRegistryEachWithTimeUntracked(t *testing.T) {
+	a := NewRegistry()
+	NewRegisteredCounter("a.foo", a)
+	r := NewPrefixedChildRegistry(MergedRegistry(ReadOnly(a)), "a.").(*PrefixedRegistry)
+	i := 0
+	r.EachWithTime(func(name string, _ interface{}, updated time.Time) {
+		i++
+		if "a.foo" != name || !updated.IsZero() {
+			t.Fatal(name, updated)
+		}
+	})
+	if 1 != i {
+		t.Fatal(i)
+	}
+	if names := r.StaleAfter(time.Minute); 1 != len(names) {
+		t.Fatal(names)
+	}
+}
+
+func TestMergedRegistry(t *testing.T) {
+	a, b := NewRegistry(), NewRegistry()
+	NewRegisteredCounter("foo", a).Inc(1)
+	NewRegisteredCounter("bar", a).Inc(2)
+	NewRegisteredCounter("foo", b).Inc(3)
+	r := MergedRegistry(a, b)
+	counts := make(map[string]int64)
+	r.Each(func(name string, i interface{}) {
+		counts[name] = i.(Counter).Count()
+	})
+	if 2 != len(counts) || 3 != counts["foo"] || 2 != counts["bar"] {
+		t.Fatal(counts)
+	}
+	if c := r.Get("foo"); b.Get("foo") != c {
+		t.Fatal(c)
+	}
+	if c := r.Get("baz"); nil != c {
+		t.Fatal(c)
+	}
+	if all := r.GetAll(); int64(3) != all["foo"]["count"] {
+		t.Fatal(all)
+	}
+	if err := r.Register("baz", NewCounter()); ErrReadOnlyRegistry != err {
+		t.Fatal(err)
+	}
+	if c := GetOrRegisterCounter("baz", r); nil == c || nil != a.Get("baz") || nil != b.Get("baz") {
+		t.Fatal(c)
+	}
+	r.UnregisterAll()
+	if nil == a.Get("foo") {
+		t.Fatal("UnregisterAll modified an underlying registry")
+	}
+}
+
+func TestMergedRegistryPrefixed(t *testing.T) {
+	a := NewRegistry()
+	NewRegisteredCounter("prefix.foo", a)
+	NewRegisteredCounter("bar", a)
+	i := 0
+	NewPrefixedChildRegistry(MergedRegistry(a), "prefix.").Each(func(name string, m interface{}) {
+		i++
+		if "prefix.foo" != name {
+			t.Fatal(name)
+		}
+	})
+	if 1 != i {
+		t.Fatal(i)
+	}
+}
+
+func TestMergedRegistryEachWithTime(t *testing.T) {
+	a, b := NewRegistry().(*StandardRegistry), NewRegistry()
+	NewRegisteredCounter("foo", a)
+	c := NewRegisteredCounter("foo", b)
+	NewRegisteredCounter("bar", a)
+	r := MergedRegistry(a, b).(*mergedRegistry)
+	r.EachWithTime(func(name string, i interface{}, updated time.Time) {
+		if "foo" == name && c != i {
+			t.Fatal(i)
+		}
+		if updated.IsZero() {
+			t.Fatal(name, updated)
+		}
+	})
+	since := time.Now()
+	c.Inc(1)
+	if names := r.ChangedSince(since); 1 != len(names) || "foo" != names[0] {
+		t.Fatal(names)
+	}
+	p := NewPrefixedChildRegistry(r, "f").(*PrefixedRegistry)
+	if names := p.ChangedSince(since); 1 != len(names) || "foo" != names[0] {
+		t.Fatal(names)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	r := NewRegistry()
+	c := NewRegisteredCounter("foo", r)
+	ro := ReadOnly(r)
+	if m := ro.Get("foo"); c != m {
+		t.Errorf("ro.Get(\"foo\"): %v != %v\n", c, m)
+	}
+	if m := ro.GetOrRegister("foo", NewCounter); c != m {
+		t.Errorf("ro.GetOrRegister(\"foo\"): %v != %v\n", c, m)
+	}
+	i := 0
+	ro.Each(func(name string, _ interface{}) {
+		i++
+		if "foo" != name {
+			t.Fatal(name)
+		}
+	})
+	if 1 != i {
+		t.Fatal(i)
+	}
+	if err := ro.Register("bar", NewCounter()); ErrReadOnlyRegistry != err {
+		t.Fatal(err)
+	}
+	if err := ro.(*readOnlyRegistry).RegisterAll(map[string]interface{}{"bar": NewCounter()}); ErrReadOnlyRegistry != err {
+		t.Fatal(err)
+	}
+	if nil != r.Get("bar") {
+		t.Fatal("bar was registered")
+	}
+	for name, f := range map[string]func(){
+		"GetOrRegister": func() { ro.GetOrRegister("bar", NewCounter) },
+		"Unregister":    func() { ro.Unregister("foo") },
+		"UnregisterAll": func() { ro.UnregisterAll() },
+	} {
+		func() {
+			defer func() {
+				if v := recover(); ErrReadOnlyRegistry != v {
+					t.Errorf("%s(): %v != ErrReadOnlyRegistry\n", name, v)
+				}
+			}()
+			f()
+		}()
+	}
+	if c != r.Get("foo") {
+		t.Fatal("foo was unregistered")
+	}
+}
+
+func TestReadOnlyPrefixed(t *testing.T) {
+	r := NewRegistry()
+	c := NewRegisteredCounter("prefix.foo", r)
+	pr := NewPrefixedChildRegistry(ReadOnly(r), "prefix.")
+	if m := pr.Get("foo"); c != m {
+		t.Errorf("pr.Get(\"foo\"): %v != %v\n", c, m)
+	}
+	if err := pr.Register("bar", NewCounter()); ErrReadOnlyRegistry != err {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistryAlias(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	c := NewRegisteredCounter("new", r)
+	if err := r.Alias("new", "old"); nil != err {
+		t.Fatal(err)
+	}
+	c.Inc(47)
+	if old := r.Get("old"); c != old {
+		t.Fatal(old)
+	}
+	i := 0
+	r.Each(func(name string, m interface{}) {
+		i++
+		if 47 != m.(Counter).Count() {
+			t.Fatal(name, m)
+		}
+	})
+	if 2 != i {
+		t.Fatal(i)
+	}
+	if err := r.Register("old", NewCounter()); nil == err {
+		t.Fatal(err)
+	} else if _, ok := err.(DuplicateMetric); !ok {
+		t.Fatal(err)
+	}
+	if err := r.Alias("new", "old"); nil == err {
+		t.Fatal(err)
+	}
+	if err := r.Alias("missing", "other"); ErrMetricNotFound != err {
+		t.Fatal(err)
+	}
+	r.Unregister("new")
+	if nil != r.Get("old") {
+		t.Fatal(r.Get("old"))
+	}
+}
+
+func TestPrefixedRegistryAliasUnsupported(t *testing.T) {
+	for _, r := range []Registry{NewNilRegistry(), MergedRegistry(NewRegistry()), ReadOnly(NewRegistry())} {
+		p := NewPrefixedChildRegistry(r, "x.").(*PrefixedRegistry)
+		if err := p.Alias("a", "b"); ErrUnsupportedOperation != err {
+			t.Errorf("%T Alias(): ErrUnsupportedOperation != %v\n", r, err)
+		}
+	}
+}
+
+func TestRegistryUnregisterAlias(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	m := NewRegisteredMeter("new", r)
+	r.Alias("new", "old")
+	r.Alias("old", "older")
+	r.Unregister("old")
+	if nil == r.Get("new") || nil == r.Get("older") || nil != r.Get("old") {
+		t.Fatal(r.metrics)
+	}
+	if _, ok := arbiter.meters[m.(*StandardMeter)]; !ok {
+		t.Fatal("unregistering an alias stopped the meter")
+	}
+	r.Unregister("new")
+	if 0 != len(r.metrics) {
+		t.Fatal(r.metrics)
+	}
+}
+
+func TestPrefixedRegistryAlias(t *testing.T) {
+	r := NewRegistry()
+	p := NewPrefixedChildRegistry(r, "prefix.").(*PrefixedRegistry)
+	GetOrRegisterCounter("new", p)
+	if err := p.Alias("new", "old"); nil != err {
+		t.Fatal(err)
+	}
+	if nil == r.Get("prefix.old") {
+		t.Fatal(r.Get("prefix.old"))
+	}
+}
+
+func TestDuplicateMetric(t *testing.T) {
+	r := NewRegistry()
+	r.Register("foo", NewCounter())
+	err := r.Register("foo", NewCounter())
+	dup, ok := err.(DuplicateMetric)
+	if !ok {
+		t.Fatal(err)
+	}
+	if "foo" != dup.Name() {
+		t.Errorf("dup.Name(): foo != %v\n", dup.Name())
+	}
+	if "duplicate metric: foo" != dup.Error() {
+		t.Errorf("dup.Error(): duplicate metric: foo != %v\n", dup.Error())
+	}
+	if !dup.Is(ErrDuplicateMetric) {
+		t.Fatal(dup)
+	}
+}
+
+func TestRegistryRegisterAll(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	if err := r.RegisterAll(map[string]interface{}{
+		"foo": NewCounter(),
+		"bar": NewGauge(),
+	}); nil != err {
+		t.Fatal(err)
+	}
+	if 2 != r.Len() {
+		t.Fatal(r.Len())
+	}
+	err := r.RegisterAll(map[string]interface{}{
+		"baz": NewCounter(),
+		"foo": NewCounter(),
+		"qux": NewCounter(),
+	})
+	if dup, ok := err.(DuplicateMetric); !ok || "foo" != dup.Name() {
+		t.Fatal(err)
+	}
+	if nil != r.Get("baz") || nil != r.Get("qux") {
+		t.Fatal("RegisterAll registered metrics despite a duplicate")
+	}
+}
+
+func TestBoundedRegistryRegisterAll(t *testing.T) {
+	r := NewBoundedRegistry(2).(*BoundedRegistry)
+	r.Register("foo", NewCounter())
+	if err := r.RegisterAll(map[string]interface{}{
+		"bar": NewCounter(),
+		"baz": NewCounter(),
+	}); ErrTooManyMetrics != err {
+		t.Fatal(err)
+	}
+	if 1 != r.Len() {
+		t.Fatal(r.Len())
+	}
+	if err := r.RegisterAll(map[string]interface{}{"bar": NewCounter()}); nil != err {
+		t.Fatal(err)
+	}
+}
+
+func TestPrefixedRegistryRegisterAll(t *testing.T) {
+	r := NewPrefixedRegistry("prefix.").(*PrefixedRegistry)
+	if err := r.RegisterAll(map[string]interface{}{"foo": NewCounter()}); nil != err {
+		t.Fatal(err)
+	}
+	if nil == r.underlying.Get("prefix.foo") {
+		t.Fatal(r.underlying.Get("prefix.foo"))
+	}
+	if err := r.RegisterAll(map[string]interface{}{"foo": NewCounter()}); "duplicate metric: prefix.foo" != err.Error() {
+		t.Fatal(err)
+	}
+	p := NewPrefixedChildRegistry(NewNilRegistry(), "prefix.").(*PrefixedRegistry)
+	if err := p.RegisterAll(map[string]interface{}{"foo": NewCounter()}); ErrUnsupportedOperation != err {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistryScope(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	handler := r.Scope("app").Scope("http").Scope("handler")
+	NewRegisteredTimer("latency", handler)
+	if nil == r.Get("app.http.handler.latency") {
+		t.Fatal(r.registered())
+	}
+	if nil == handler.Get("latency") {
+		t.Fatal(handler.Get("latency"))
+	}
+	i := 0
+	r.Each(func(name string, _ interface{}) {
+		i++
+		if "app.http.handler.latency" != name {
+			t.Fatal(name)
+		}
+	})
+	if 1 != i {
+		t.Fatal(i)
+	}
+}
+
+func TestPrefixedRegistryScopeWithoutSeparator(t *testing.T) {
+	r := NewPrefixedRegistry("app").(*PrefixedRegistry)
+	NewRegisteredCounter("q", r.Scope("db"))
+	NewRegisteredCounter("requests", r.Scope("http").Scope("handler"))
+	if nil == r.underlying.Get("app.db.q") || nil == r.underlying.Get("app.http.handler.requests") {
+		t.Fatal(r.underlying.GetAll())
+	}
+}
+
+func TestRegistryScopeSeparator(t *testing.T) {
+	defer func(sep string) { ScopeSeparator = sep }(ScopeSeparator)
+	ScopeSeparator = "/"
+	r := NewPrefixedRegistry("app/").(*PrefixedRegistry)
+	NewRegisteredCounter("requests", r.Scope("http"))
+	if nil == r.underlying.Get("app/http/requests") {
+		t.Fatal(r.underlying.GetAll())
+	}
+}
+
+func TestPrefixedRegistryWithSep(t *testing.T) {
+	r := NewRegistry()
+	p := NewPrefixedRegistryWithSep(r, "app", "_")
+	NewRegisteredCounter("requests", p.Scope("http"))
+	NewRegisteredCounter("errors", p)
+	if nil == r.Get("app_http_requests") || nil == r.Get("app_errors") {
+		t.Fatal(r.GetAll())
+	}
+	i := 0
+	p.Each(func(name string, _ interface{}) {
+		i++
+	})
+	if 2 != i {
+		t.Fatal(i)
+	}
+	if nil == NewPrefixedRegistryWithSep(nil, "app", "_").underlying {
+		t.Fatal("nil parent")
+	}
+}
+
+func TestTaggedRegistryGraphite(t *testing.T) {
+	r := NewRegistry()
+	p := NewTaggedRegistry(r, map[string]string{"host": "a"}, GraphiteTagEncoder)
+	NewRegisteredCounter("requests", p.WithTags(map[string]string{"code": "200"}))
+	NewRegisteredCounter("errors", p)
+	if nil == r.Get("requests;code=200;host=a") || nil == r.Get("errors;host=a") {
+		t.Fatal(r.GetAll())
+	}
+	if nil == p.Get("errors") {
+		t.Fatal(p.Get("errors"))
+	}
+	p.Unregister("errors")
+	if nil != r.Get("errors;host=a") {
+		t.Fatal(r.GetAll())
+	}
+}
+
+func TestTaggedRegistryDotted(t *testing.T) {
+	r := NewRegistry()
+	p := NewTaggedRegistry(NewPrefixedRegistryWithSep(r, "app", "."), map[string]string{"host": "a"}, DottedTagEncoder)
+	NewRegisteredCounter("requests", p.Scope("http").WithTags(map[string]string{"host": "b", "code": "200"}))
+	if nil == r.Get("app.http.requests.code.200.host.b") {
+		t.Fatal(r.GetAll())
+	}
+	i := 0
+	p.Each(func(name string, _ interface{}) {
+		i++
+	})
+	if 1 != i {
+		t.Fatal(i)
+	}
+}
+
+func TestTaggedRegistryNoTags(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("requests", NewTaggedRegistry(r, nil, nil))
+	NewRegisteredCounter("errors", NewTaggedRegistry(r, nil, nil).WithTags(map[string]string{"code": "500"}))
+	if nil == r.Get("requests") || nil == r.Get("errors;code=500") {
+		t.Fatal(r.GetAll())
+	}
+}
+
+func TestRegistryDescribe(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	NewRegisteredCounter("foo", r)
+	r.Describe("foo", "Foos.")
+	if help := r.Help("foo"); "Foos." != help {
+		t.Errorf("r.Help(foo): Foos. != %v\n", help)
+	}
+	r.Unregister("foo")
+	NewRegisteredCounter("foo", r)
+	if help := r.Help("foo"); "" != help {
+		t.Errorf("r.Help(foo): \"\" != %v\n", help)
+	}
+	p := r.Scope("prefix")
+	p.Describe("bar", "Bars.")
+	if help := r.Help("prefix.bar"); "Bars." != help || p.Help("bar") != help {
+		t.Errorf("r.Help(prefix.bar): Bars. != %v\n", help)
+	}
+	q := NewPrefixedChildRegistry(NewNilRegistry(), "prefix.").(*PrefixedRegistry)
+	q.Describe("bar", "Bars.") // must not panic
+	if help := q.Help("bar"); "" != help {
+		t.Errorf("q.Help(bar): \"\" != %v\n", help)
+	}
+}
+
+func TestRegistrySetUnit(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	NewRegisteredGauge("foo", r)
+	r.SetUnit("foo", "bytes")
+	if unit := r.Unit("foo"); "bytes" != unit {
+		t.Errorf("r.Unit(foo): bytes != %v\n", unit)
+	}
+	if unit := MergedRegistry(r, NewRegistry()).(*mergedRegistry).Unit("foo"); "bytes" != unit {
+		t.Errorf("MergedRegistry(r).Unit(foo): bytes != %v\n", unit)
+	}
+	r.Unregister("foo")
+	if unit := r.Unit("foo"); "" != unit {
+		t.Errorf("r.Unit(foo): \"\" != %v\n", unit)
+	}
+	p := r.Scope("prefix")
+	p.SetUnit("bar", "requests")
+	if unit := r.Unit("prefix.bar"); "requests" != unit || p.Unit("bar") != unit {
+		t.Errorf("r.Unit(prefix.bar): requests != %v\n", unit)
+	}
+	q := NewPrefixedChildRegistry(NewNilRegistry(), "prefix.").(*PrefixedRegistry)
+	q.SetUnit("bar", "requests") // must not panic
+	if unit := q.Unit("bar"); "" != unit {
+		t.Errorf("q.Unit(bar): \"\" != %v\n", unit)
+	}
+}
+
+func TestRegistrySetSampleRate(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	NewRegisteredTimer("foo", r).Stop()
+	if rate := r.SampleRate("foo"); 1 != rate {
+		t.Errorf("r.SampleRate(foo): 1 != %v\n", rate)
+	}
+	r.SetSampleRate("foo", 0.1)
+	if rate := r.SampleRate("foo"); 0.1 != rate {
+		t.Errorf("r.SampleRate(foo): 0.1 != %v\n", rate)
+	}
+	if rate := MergedRegistry(r, NewRegistry()).(*mergedRegistry).SampleRate("foo"); 0.1 != rate {
+		t.Errorf("MergedRegistry(r).SampleRate(foo): 0.1 != %v\n", rate)
+	}
+	r.Unregister("foo")
+	if rate := r.SampleRate("foo"); 1 != rate {
+		t.Errorf("r.SampleRate(foo): 1 != %v\n", rate)
+	}
+	p := r.Scope("prefix")
+	p.SetSampleRate("bar", 0.5)
+	if rate := r.SampleRate("prefix.bar"); 0.5 != rate || p.SampleRate("bar") != rate {
+		t.Errorf("r.SampleRate(prefix.bar): 0.5 != %v\n", rate)
+	}
+	q := NewPrefixedChildRegistry(NewNilRegistry(), "prefix.").(*PrefixedRegistry)
+	q.SetSampleRate("bar", 0.5) // must not panic
+	if rate := q.SampleRate("bar"); 1 != rate {
+		t.Errorf("q.SampleRate(bar): 1 != %v\n", rate)
+	}
+}
+
+func TestRegistryChangedSince(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	c := NewRegisteredCounter("foo", r)
+	NewRegisteredCounter("bar", r)
+	if names := r.ChangedSince(time.Time{}); 2 != len(names) || "bar" != names[0] {
+		t.Fatal(names)
+	}
+	since := time.Now()
+	if names := r.ChangedSince(since); 0 != len(names) {
+		t.Fatal(names)
+	}
+	c.Inc(1)
+	if names := r.ChangedSince(since); 1 != len(names) || "foo" != names[0] {
+		t.Fatal(names)
+	}
+}
+
+func TestRegistryGetOrRegisterE(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	NewRegisteredGauge("foo", r)
+	_, err := r.GetOrRegisterE("foo", NewCounter)
+	if mismatch, ok := err.(MetricTypeMismatch); !ok || "foo" != mismatch.Name {
+		t.Fatal(err)
+	}
+	if s := err.Error(); "metric foo is a *metrics.StandardGauge, not a metrics.Counter" != s {
+		t.Fatal(s)
+	}
+	if _, err := r.GetOrRegisterE("foo", NewCounter()); nil == err {
+		t.Fatal(err)
+	}
+	if g, err := r.GetOrRegisterE("foo", NewGauge()); nil != err || r.Get("foo") != g {
+		t.Fatal(g, err)
+	}
+	c, err := r.GetOrRegisterE("bar", NewCounter)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err := r.GetOrRegisterE("bar", NewFunctionalCounter(func() int64 { return 0 })); nil != err {
+		t.Fatal(err)
+	}
+	c.(Counter).Inc(1)
+
+	p := r.Scope("prefix")
+	NewRegisteredMeter("baz", p).Stop()
+	if _, err := p.GetOrRegisterE("baz", NewTimer); nil == err {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistryEachSorted(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	for _, name := range []string{"c", "a", "d", "b"} {
+		r.Register(name, NewCounter())
+	}
+	var names []string
+	r.EachSorted(func(name string, i interface{}) {
+		names = append(names, name)
+		r.Unregister(name) // must not deadlock
+	})
+	if "a b c d" != strings.Join(names, " ") {
+		t.Fatal(names)
+	}
+	if 0 != len(r.metrics) {
+		t.Fatal(r.metrics)
+	}
+
+	p := NewPrefixedChildRegistry(r, "p.")
+	r.Register("z", NewCounter())
+	p.Register("y", NewCounter())
+	p.Register("x", NewCounter())
+	names = nil
+	p.(*PrefixedRegistry).EachSorted(func(name string, i interface{}) {
+		names = append(names, name)
+	})
+	if "p.x p.y" != strings.Join(names, " ") {
+		t.Fatal(names)
+	}
+}
+
+func TestRegistryEachMatching(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	r.Register("foo", NewTimer())
+	r.Register("bar", NewGauge())
+	r.Register("baz", NewTimer())
+	isTimer := func(name string, i interface{}) bool {
+		_, ok := i.(Timer)
+		return ok
+	}
+	n := 0
+	r.EachMatching(isTimer, func(name string, i interface{}) {
+		n++
+		if "foo" != name && "baz" != name {
+			t.Fatal(name)
+		}
+		r.Unregister(name) // must not deadlock
+	})
+	if 2 != n {
+		t.Errorf("n: 2 != %v\n", n)
+	}
+	if 1 != r.Len() {
+		t.Fatal(r.Len())
+	}
+
+	p := NewPrefixedChildRegistry(r, "p.")
+	p.Register("qux", NewTimer())
+	r.Register("quux", NewTimer())
+	var names []string
+	p.(*PrefixedRegistry).EachMatching(isTimer, func(name string, i interface{}) {
+		names = append(names, name)
+	})
+	if "p.qux" != strings.Join(names, " ") {
+		t.Fatal(names)
+	}
+}
+
+func TestNilRegistry(t *testing.T) {
+	r := NewNilRegistry()
+	if c := GetOrRegisterCounter("foo", r); (NilCounter{}) != c {
+		t.Fatal(c)
+	}
+	if g := GetOrRegisterGaugeFloat64("bar", r); (NilGaugeFloat64{}) != g {
+		t.Fatal(g)
+	}
+	if c := GetOrRegisterLabeledCounter("baz", r); (NilLabeledCounter{}) != c {
+		t.Fatal(c)
+	}
+	if m := r.GetOrRegister("qux", NewCounter()); (NilCounter{}) != m {
+		t.Fatal(m)
+	}
+	if m := r.GetOrRegister("a", NewLabeledCounter("a", nil)).(LabeledCounter); (NilLabeledCounter{}) != m {
+		t.Fatal(m)
+	}
+	if m := r.GetOrRegister("b", NewCustomMeter([]float64{0.5}, time.Second)).(CustomMeter); (NilCustomMeter{}) != m {
+		t.Fatal(m)
+	}
+	if m := r.GetOrRegister("c", NewHistogramWithExemplars(NewUniformSample(10), nil)).(ExemplarHistogram); (NilExemplarHistogram{}) != m {
+		t.Fatal(m)
+	}
+	NewRegisteredTimer("quux", r).Update(time.Second)
+	if nil != r.Get("quux") {
+		t.Fatal(r.Get("quux"))
+	}
+	r.Each(func(name string, i interface{}) {
+		t.Fatal(name, i)
+	})
+	if all := r.GetAll(); 0 != len(all) {
+		t.Fatal(all)
+	}
+}
+
+func TestPrefixedNilRegistry(t *testing.T) {
+	r := NewPrefixedChildRegistry(NewNilRegistry(), "a.").(*PrefixedRegistry)
+	GetOrRegisterCounter("foo", r).Inc(1)
+	r.Each(func(name string, _ interface{}) {
+		t.Fatal(name)
+	})
+	r.EachWithTime(func(name string, _ interface{}, _ time.Time) {
+		t.Fatal(name)
+	})
+	if names := r.ChangedSince(time.Time{}); 0 != len(names) {
+		t.Fatal(names)
+	}
+	if names := r.StaleAfter(time.Minute); 0 != len(names) {
+		t.Fatal(names)
+	}
+}
+
+func TestRegistryUnregisterMatching(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	for _, name := range []string{"db.queries", "db.pool.size", "dbx", "http.requests"} {
+		r.Register(name, NewCounter())
+	}
+	r.Alias("http.requests", "db.requests")
+	if n := r.UnregisterMatching("http.*"); 2 != n {
+		t.Errorf("r.UnregisterMatching(\"http.*\"): 2 != %v\n", n)
+	}
+	if n := r.UnregisterMatching("db.*"); 2 != n {
+		t.Errorf("r.UnregisterMatching(\"db.*\"): 2 != %v\n", n)
+	}
+	if 1 != len(r.metrics) || nil == r.Get("dbx") {
+		t.Fatal(r.metrics)
+	}
+	if n := r.UnregisterMatching("["); 0 != n {
+		t.Errorf("r.UnregisterMatching(\"[\"): 0 != %v\n", n)
+	}
+
+	r.Register("http./api/users", NewCounter())
+	if n := r.UnregisterMatching("http.*"); 0 != n {
+		t.Errorf("r.UnregisterMatching(\"http.*\"): 0 != %v\n", n)
+	}
+	if n := r.UnregisterMatching("http.*/*/*"); 1 != n {
+		t.Errorf("r.UnregisterMatching(\"http.*/*/*\"): 1 != %v\n", n)
+	}
+
+	p := NewPrefixedChildRegistry(r, "a*.").(*PrefixedRegistry)
+	p.Register("foo", NewCounter())
+	r.Register("ab.foo", NewCounter())
+	if n := p.UnregisterMatching("*"); 1 != n {
+		t.Errorf("p.UnregisterMatching(\"*\"): 1 != %v\n", n)
+	}
+	if nil == r.Get("ab.foo") {
+		t.Fatal("ab.foo unregistered")
+	}
+}
+
+func TestRegistryRegisterStrict(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	if err := r.RegisterStrict("foo", NewCounter()); nil != err {
+		t.Fatal(err)
+	}
+	if _, ok := r.RegisterStrict("foo", NewCounter()).(DuplicateMetric); !ok {
+		t.Fatal("duplicate not reported")
+	}
+	err := r.RegisterStrict("bar", "not a metric")
+	if u, ok := err.(UnsupportedMetric); !ok || "bar" != u.Name {
+		t.Fatal(err)
+	}
+	if s := err.Error(); "unsupported metric: bar is a string, not a metric" != s {
+		t.Fatal(s)
+	}
+	if nil != r.Get("bar") {
+		t.Fatal(r.Get("bar"))
+	}
+	p := NewPrefixedChildRegistry(r, "p.").(*PrefixedRegistry)
+	if _, ok := p.RegisterStrict("baz", nil).(UnsupportedMetric); !ok {
+		t.Fatal("nil metric not reported")
+	}
+}
+
+func TestMustRegister(t *testing.T) {
+	defer func(r Registry) { DefaultRegistry = r }(DefaultRegistry)
+	DefaultRegistry = NewRegistry()
+	MustRegister("foo", NewCounter())
+	for _, i := range []interface{}{NewCounter(), 47} {
+		func() {
+			defer func() {
+				if nil == recover() {
+					t.Errorf("MustRegister(%T) did not panic\n", i)
+				}
+			}()
+			MustRegister("foo", i)
+		}()
+	}
+}
+
+func TestRegistryListeners(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	var events []string
+	r.OnRegister(func(name string, metric interface{}) {
+		if _, ok := metric.(Counter); !ok {
+			t.Fatal(metric)
+		}
+		events = append(events, "+"+name)
+	})
+	r.OnUnregister(func(name string) {
+		events = append(events, "-"+name)
+	})
+	r.OnUnregister(func(name string) {
+		events = append(events, "-"+name+"again")
+	})
+	r.Register("foo", NewCounter())
+	r.GetOrRegister("foo", NewCounter)
+	r.GetOrRegister("bar", NewCounter)
+	r.Alias("foo", "baz")
+	r.Register("qux", "unsupported")
+	r.Unregister("foo")
+	r.Unregister("foo")
+	expected := "+foo +bar +baz -foo -fooagain -baz -bazagain"
+	if s := strings.Join(events, " "); expected != s {
+		t.Errorf("events: %v != %v\n", expected, s)
+	}
+}
+
+func TestRegistryListenersUnregisterAll(t *testing.T) {
+	r := NewBoundedRegistry(1).(*BoundedRegistry)
+	var names []string
+	r.OnRegister(func(name string, metric interface{}) {
+		names = append(names, name)
+	})
+	r.OnUnregister(func(name string) {
+		names = append(names, name)
+	})
+	r.Register("foo", NewCounter())
+	r.Register("bar", NewCounter())
+	r.UnregisterAll()
+	if s := strings.Join(names, " "); "foo foo" != s {
+		t.Errorf("names: foo foo != %v\n", s)
+	}
+}
+
+func TestRegistryListenersConcurrent(t *testing.T) {
+	r := NewRegistry().(*StandardRegistry)
+	var n int
+	r.OnRegister(func(string, interface{}) { n++ })
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r.GetOrRegister(fmt.Sprintf("foo%d.%d", i, j), NewCounter)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if 400 != n {
+		t.Errorf("n: 400 != %v\n", n)
+	}
+}
+
+func TestRegistryNameValidator(t *testing.T) {
+	defer func(v func(string) error) { NameValidator = v }(NameValidator)
+	NameValidator = GraphiteNameValidator
+	r := NewRegistry()
+	for _, name := range []string{"", "foo bar", "foo\tbar", "foo\x00", "foo..bar", ".foo"} {
+		err := r.Register(name, NewCounter())
+		if _, ok := err.(InvalidMetricName); !ok {
+			t.Errorf("r.Register(%q): %v\n", name, err)
+		}
+		if c := r.GetOrRegister(name, NewCounter); nil == c {
+			t.Errorf("r.GetOrRegister(%q): nil\n", name)
+		}
+		if _, err := r.(*StandardRegistry).GetOrRegisterE(name, NewCounter); nil == err {
+			t.Errorf("r.GetOrRegisterE(%q): nil\n", name)
+		}
+	}
+	if n := r.(*StandardRegistry).Len(); 0 != n {
+		t.Errorf("r.Len(): 0 != %v\n", n)
+	}
+	for _, name := range []string{"foo", "foo.bar", "foo.bar-baz_99"} {
+		if err := r.Register(name, NewCounter()); nil != err {
+			t.Errorf("r.Register(%q): %v\n", name, err)
+		}
+	}
+	if err := r.(*StandardRegistry).Alias("foo", "foo bar"); nil == err {
+		t.Fatal(err)
+	}
+	if err := r.(*StandardRegistry).RegisterAll(map[string]interface{}{"baz": NewCounter(), "baz qux": NewCounter()}); nil == err {
+		t.Fatal(err)
+	}
+	if nil != r.Get("baz") {
+		t.Fatal(r.Get("baz"))
+	}
+}
+
+func TestRegistryNameValidatorPermissive(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register("foo bar", NewCounter()); nil != err {
+		t.Fatal(err)
+	}
+}
+
+func TestInvalidMetricName(t *testing.T) {
+	err := InvalidMetricName{Name: "foo bar", Err: errors.New("contains ' '")}
+	if s := err.Error(); `invalid metric name "foo bar": contains ' '` != s {
+		t.Fatal(s)
+	}
+	if errors.Unwrap(err) != err.Err {
+		t.Fatal(errors.Unwrap(err))
+	}
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// RequestMetrics bundles the Timer, success Counter and error Counter which
+// together record a request.  Observe updates all three under a lock which
+// Snapshot also takes, so a snapshot never sees a request in the timer but
+// in neither counter.  Exporters which read the constituent metrics directly
+// see each of them independently.
+type RequestMetrics struct {
+	Timer     Timer
+	Successes Counter
+	Errors    Counter
+	mutex     sync.Mutex
+}
+
+// NewRequestMetrics constructs a new RequestMetrics with a new Timer and
+// Counters.
+func NewRequestMetrics() *RequestMetrics {
+	return &RequestMetrics{
+		Timer:     NewTimer(),
+		Successes: NewCounter(),
+		Errors:    NewCounter(),
+	}
+}
+
+// NewRegisteredRequestMetrics constructs a new RequestMetrics and registers
+// its Timer, success Counter and error Counter under the given name suffixed
+// with ".duration", ".successes" and ".errors".
+func NewRegisteredRequestMetrics(name string, r Registry) *RequestMetrics {
+	m := NewRequestMetrics()
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name+".duration", m.Timer)
+	r.Register(name+".successes", m.Successes)
+	r.Register(name+".errors", m.Errors)
+	return m
+}
+
+// Observe records a request which took the given duration and failed with
+// the given error, counting it as a success if the error is nil.
+func (m *RequestMetrics) Observe(d time.Duration, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.Timer.Update(d)
+	if nil == err {
+		m.Successes.Inc(1)
+	} else {
+		m.Errors.Inc(1)
+	}
+}
+
+// Snapshot returns a read-only copy of the request metrics taken between
+// calls to Observe.
+func (m *RequestMetrics) Snapshot() *RequestMetrics {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return &RequestMetrics{
+		Timer:     m.Timer.Snapshot(),
+		Successes: m.Successes.Snapshot(),
+		Errors:    m.Errors.Snapshot(),
+	}
+}
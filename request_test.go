@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRequestMetricsObserve(t *testing.T) {
+	r := NewRegistry()
+	m := NewRegisteredRequestMetrics("http", r)
+	m.Observe(time.Millisecond, nil)
+	m.Observe(2*time.Millisecond, errors.New("failed"))
+	if count := m.Timer.Count(); 2 != count {
+		t.Errorf("m.Timer.Count(): 2 != %v\n", count)
+	}
+	if count := r.Get("http.successes").(Counter).Count(); 1 != count {
+		t.Errorf("http.successes: 1 != %v\n", count)
+	}
+	if count := r.Get("http.errors").(Counter).Count(); 1 != count {
+		t.Errorf("http.errors: 1 != %v\n", count)
+	}
+	if nil == r.Get("http.duration") {
+		t.Fatal("http.duration not registered")
+	}
+}
+
+func TestRequestMetricsSnapshot(t *testing.T) {
+	m := NewRequestMetrics()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			m.Observe(time.Millisecond, nil)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		s := m.Snapshot()
+		if s.Timer.Count() != s.Successes.Count()+s.Errors.Count() {
+			t.Fatal(s.Timer.Count(), s.Successes.Count(), s.Errors.Count())
+		}
+	}
+	wg.Wait()
+}
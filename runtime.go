@@ -104,24 +104,7 @@ func CaptureRuntimeMemStatsOnce(r Registry) {
 	runtimeMetrics.MemStats.NumGC.Update(int64(memStats.NumGC - numGC))
 	runtimeMetrics.MemStats.GCCPUFraction.Update(gcCPUFraction(&memStats))
 
-	// <https://code.google.com/p/go/source/browse/src/pkg/runtime/mgc0.c>
-	i := numGC % uint32(len(memStats.PauseNs))
-	ii := memStats.NumGC % uint32(len(memStats.PauseNs))
-	if memStats.NumGC-numGC >= uint32(len(memStats.PauseNs)) {
-		for i = 0; i < uint32(len(memStats.PauseNs)); i++ {
-			runtimeMetrics.MemStats.PauseNs.Update(int64(memStats.PauseNs[i]))
-		}
-	} else {
-		if i > ii {
-			for ; i < uint32(len(memStats.PauseNs)); i++ {
-				runtimeMetrics.MemStats.PauseNs.Update(int64(memStats.PauseNs[i]))
-			}
-			i = 0
-		}
-		for ; i < ii; i++ {
-			runtimeMetrics.MemStats.PauseNs.Update(int64(memStats.PauseNs[i]))
-		}
-	}
+	recordGCPauses(runtimeMetrics.MemStats.PauseNs, &memStats, numGC)
 	frees = memStats.Frees
 	lookups = memStats.Lookups
 	mallocs = memStats.Mallocs
@@ -142,9 +125,37 @@ func CaptureRuntimeMemStatsOnce(r Registry) {
 	runtimeMetrics.NumThread.Update(int64(threadCreateProfile.Count()))
 }
 
+// recordGCPauses updates h with the pause time of every garbage collection
+// since the first numGC, read from the circular PauseNs buffer in which the
+// pause of collection n is at index (n-1)%256.  Only the last 256 pauses
+// are recorded if there have been more since the last capture.
+//
+// <https://code.google.com/p/go/source/browse/src/pkg/runtime/mgc0.c>
+func recordGCPauses(h Histogram, stats *runtime.MemStats, numGC uint32) {
+	i := numGC % uint32(len(stats.PauseNs))
+	ii := stats.NumGC % uint32(len(stats.PauseNs))
+	if stats.NumGC-numGC >= uint32(len(stats.PauseNs)) {
+		for i = 0; i < uint32(len(stats.PauseNs)); i++ {
+			h.Update(int64(stats.PauseNs[i]))
+		}
+	} else {
+		if i > ii {
+			for ; i < uint32(len(stats.PauseNs)); i++ {
+				h.Update(int64(stats.PauseNs[i]))
+			}
+			i = 0
+		}
+		for ; i < ii; i++ {
+			h.Update(int64(stats.PauseNs[i]))
+		}
+	}
+}
+
 // Register runtimeMetrics for the Go runtime statistics exported in runtime and
 // specifically runtime.MemStats.  The runtimeMetrics are named by their
-// fully-qualified Go symbols, i.e. runtime.MemStats.Alloc.
+// fully-qualified Go symbols, i.e. runtime.MemStats.Alloc.  The histogram of
+// garbage collection pause times, runtime.MemStats.PauseNs, is also
+// registered as runtime.GCPauses.
 func RegisterRuntimeMemStats(r Registry) {
 	runtimeMetrics.MemStats.Alloc = NewGauge()
 	runtimeMetrics.MemStats.BuckHashSys = NewGauge()
@@ -200,6 +211,7 @@ func RegisterRuntimeMemStats(r Registry) {
 	r.Register("runtime.MemStats.NumGC", runtimeMetrics.MemStats.NumGC)
 	r.Register("runtime.MemStats.GCCPUFraction", runtimeMetrics.MemStats.GCCPUFraction)
 	r.Register("runtime.MemStats.PauseNs", runtimeMetrics.MemStats.PauseNs)
+	r.Register("runtime.GCPauses", runtimeMetrics.MemStats.PauseNs)
 	r.Register("runtime.MemStats.PauseTotalNs", runtimeMetrics.MemStats.PauseTotalNs)
 	r.Register("runtime.MemStats.StackInuse", runtimeMetrics.MemStats.StackInuse)
 	r.Register("runtime.MemStats.StackSys", runtimeMetrics.MemStats.StackSys)
@@ -18,6 +18,7 @@ func BenchmarkRuntimeMemStats(b *testing.B) {
 func TestRuntimeMemStats(t *testing.T) {
 	r := NewRegistry()
 	RegisterRuntimeMemStats(r)
+	runtime.GC() // Finish any cycle started by allocations in earlier tests.
 	CaptureRuntimeMemStatsOnce(r)
 	zero := runtimeMetrics.MemStats.PauseNs.Count() // Get a "zero" since GC may have run before these tests.
 	runtime.GC()
@@ -47,6 +48,43 @@ func TestRuntimeMemStats(t *testing.T) {
 	}
 }
 
+func TestRecordGCPauses(t *testing.T) {
+	var stats runtime.MemStats
+	h := NewHistogram(NewUniformSample(1000))
+	stats.NumGC = 3
+	stats.PauseNs[0], stats.PauseNs[1], stats.PauseNs[2] = 10, 20, 30
+	recordGCPauses(h, &stats, 1)
+	if count := h.Count(); 2 != count {
+		t.Errorf("h.Count(): 2 != %v\n", count)
+	}
+	if sum := h.Sum(); 50 != sum {
+		t.Errorf("h.Sum(): 50 != %v\n", sum)
+	}
+
+	h.Clear()
+	stats.NumGC = 258
+	stats.PauseNs[254], stats.PauseNs[255] = 1000, 2000
+	stats.PauseNs[0], stats.PauseNs[1] = 3000, 4000
+	recordGCPauses(h, &stats, 254)
+	if count := h.Count(); 4 != count {
+		t.Errorf("h.Count(): 4 != %v\n", count)
+	}
+	if max := h.Max(); 4000 != max {
+		t.Errorf("h.Max(): 4000 != %v\n", max)
+	}
+	if p := h.Percentile(0.99); 4000 != p {
+		t.Errorf("h.Percentile(0.99): 4000 != %v\n", p)
+	}
+}
+
+func TestRuntimeGCPauses(t *testing.T) {
+	r := NewRegistry()
+	RegisterRuntimeMemStats(r)
+	if r.Get("runtime.GCPauses") != r.Get("runtime.MemStats.PauseNs") {
+		t.Fatal(r.Get("runtime.GCPauses"))
+	}
+}
+
 func TestRuntimeMemStatsNumThread(t *testing.T) {
 	r := NewRegistry()
 	RegisterRuntimeMemStats(r)
@@ -1,6 +1,9 @@
 package metrics
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"math"
 	"math/rand"
 	"sort"
@@ -10,6 +13,15 @@ import (
 
 const rescaleThreshold = time.Hour
 
+// MaxUniformSampleSize is a soft cap on the reservoir size of a
+// UniformSample, whose values are allocated up front.  NewUniformSample logs
+// a warning when given a larger size and NewUniformSampleChecked refuses it.
+var MaxUniformSampleSize = 1 << 20
+
+// ErrInvalidReservoirSize is the error returned by NewUniformSampleChecked
+// when the reservoir size is less than one.
+var ErrInvalidReservoirSize = errors.New("reservoir size must be at least one")
+
 // Samples maintain a statistically-significant selection of values from
 // a stream.
 type Sample interface {
@@ -36,7 +48,9 @@ type Sample interface {
 // <http://dimacs.rutgers.edu/~graham/pubs/papers/fwddecay.pdf>
 type ExpDecaySample struct {
 	alpha         float64
+	clock         Clock
 	count         int64
+	method        PercentileMethod
 	mutex         sync.Mutex
 	reservoirSize int
 	t0, t1        time.Time
@@ -46,13 +60,34 @@ type ExpDecaySample struct {
 // NewExpDecaySample constructs a new exponentially-decaying sample with the
 // given reservoir size and alpha.
 func NewExpDecaySample(reservoirSize int, alpha float64) Sample {
+	return NewExpDecaySampleWithClock(reservoirSize, alpha, WallClock)
+}
+
+// NewExpDecaySampleWithClock constructs a new exponentially-decaying sample
+// with the given reservoir size and alpha which reads the current time from
+// the given Clock.  This allows tests to advance time manually across the
+// rescale threshold.
+func NewExpDecaySampleWithClock(reservoirSize int, alpha float64, clock Clock) Sample {
+	return newExpDecaySample(reservoirSize, alpha, clock, PercentileWeibull)
+}
+
+// NewExpDecaySampleWithMethod constructs a new exponentially-decaying sample
+// with the given reservoir size and alpha which computes percentiles using
+// the given PercentileMethod.
+func NewExpDecaySampleWithMethod(reservoirSize int, alpha float64, method PercentileMethod) Sample {
+	return newExpDecaySample(reservoirSize, alpha, WallClock, method)
+}
+
+func newExpDecaySample(reservoirSize int, alpha float64, clock Clock, method PercentileMethod) Sample {
 	if UseNilMetrics {
 		return NilSample{}
 	}
 	s := &ExpDecaySample{
 		alpha:         alpha,
+		clock:         clock,
+		method:        method,
 		reservoirSize: reservoirSize,
-		t0:            time.Now(),
+		t0:            clock.Now(),
 		values:        newExpDecaySampleHeap(reservoirSize),
 	}
 	s.t1 = s.t0.Add(rescaleThreshold)
@@ -64,7 +99,7 @@ func (s *ExpDecaySample) Clear() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	s.count = 0
-	s.t0 = time.Now()
+	s.t0 = s.clock.Now()
 	s.t1 = s.t0.Add(rescaleThreshold)
 	s.values.Clear()
 }
@@ -96,13 +131,13 @@ func (s *ExpDecaySample) Min() int64 {
 
 // Percentile returns an arbitrary percentile of values in the sample.
 func (s *ExpDecaySample) Percentile(p float64) float64 {
-	return SamplePercentile(s.Values(), p)
+	return s.Percentiles([]float64{p})[0]
 }
 
 // Percentiles returns a slice of arbitrary percentiles of values in the
 // sample.
 func (s *ExpDecaySample) Percentiles(ps []float64) []float64 {
-	return SamplePercentiles(s.Values(), ps)
+	return SamplePercentilesWithMethod(s.Values(), ps, s.method)
 }
 
 // Size returns the size of the sample, which is at most the reservoir size.
@@ -123,6 +158,7 @@ func (s *ExpDecaySample) Snapshot() Sample {
 	}
 	return &SampleSnapshot{
 		count:  s.count,
+		method: s.method,
 		values: values,
 	}
 }
@@ -139,7 +175,7 @@ func (s *ExpDecaySample) Sum() int64 {
 
 // Update samples a new value.
 func (s *ExpDecaySample) Update(v int64) {
-	s.update(time.Now(), v)
+	s.update(s.clock.Now(), v)
 }
 
 // Values returns a copy of the values in the sample.
@@ -274,14 +310,54 @@ func SamplePercentile(values int64Slice, p float64) float64 {
 }
 
 // SamplePercentiles returns a slice of arbitrary percentiles of the slice of
-// int64.
+// int64, computed using PercentileWeibull.
 func SamplePercentiles(values int64Slice, ps []float64) []float64 {
+	return SamplePercentilesWithMethod(values, ps, PercentileWeibull)
+}
+
+// PercentileMethod selects how percentiles are computed from a sample's
+// values.
+type PercentileMethod int
+
+const (
+	// PercentileWeibull interpolates linearly between the two values nearest
+	// to the position p*(n+1), as Dropwizard Metrics does.  It is the
+	// default.
+	PercentileWeibull PercentileMethod = iota
+
+	// PercentileNearestRank returns the value at rank ceil(p*n), which is
+	// always a value in the sample.
+	PercentileNearestRank
+
+	// PercentileLinear interpolates linearly between the two values nearest
+	// to the position p*(n-1)+1, as numpy and spreadsheets do by default.
+	PercentileLinear
+)
+
+// SamplePercentilesWithMethod returns a slice of arbitrary percentiles of the
+// slice of int64, computed using the given PercentileMethod.  The slice is
+// sorted in place.
+func SamplePercentilesWithMethod(values int64Slice, ps []float64, method PercentileMethod) []float64 {
+	sort.Sort(values)
+	return sortedPercentiles(values, ps, method)
+}
+
+// sortedPercentiles is SamplePercentilesWithMethod for an already sorted
+// slice of int64.
+func sortedPercentiles(values int64Slice, ps []float64, method PercentileMethod) []float64 {
 	scores := make([]float64, len(ps))
 	size := len(values)
 	if size > 0 {
-		sort.Sort(values)
 		for i, p := range ps {
-			pos := p * float64(size+1)
+			var pos float64
+			switch method {
+			case PercentileNearestRank:
+				pos = math.Ceil(p * float64(size))
+			case PercentileLinear:
+				pos = p*float64(size-1) + 1
+			default:
+				pos = p * float64(size+1)
+			}
 			if pos < 1.0 {
 				scores[i] = float64(values[0])
 			} else if pos >= float64(size) {
@@ -296,10 +372,14 @@ func SamplePercentiles(values int64Slice, ps []float64) []float64 {
 	return scores
 }
 
-// SampleSnapshot is a read-only copy of another Sample.
+// SampleSnapshot is a read-only copy of another Sample.  Its values are
+// sorted by the first call for percentiles, which every later call reuses.
 type SampleSnapshot struct {
 	count  int64
+	method PercentileMethod
 	values []int64
+	sorted []int64
+	once   sync.Once
 }
 
 func NewSampleSnapshot(count int64, values []int64) *SampleSnapshot {
@@ -329,13 +409,18 @@ func (s *SampleSnapshot) Min() int64 { return SampleMin(s.values) }
 // Percentile returns an arbitrary percentile of values at the time the
 // snapshot was taken.
 func (s *SampleSnapshot) Percentile(p float64) float64 {
-	return SamplePercentile(s.values, p)
+	return s.Percentiles([]float64{p})[0]
 }
 
 // Percentiles returns a slice of arbitrary percentiles of values at the time
 // the snapshot was taken.
 func (s *SampleSnapshot) Percentiles(ps []float64) []float64 {
-	return SamplePercentiles(s.values, ps)
+	s.once.Do(func() {
+		s.sorted = make([]int64, len(s.values))
+		copy(s.sorted, s.values)
+		sort.Sort(int64Slice(s.sorted))
+	})
+	return sortedPercentiles(s.sorted, ps, s.method)
 }
 
 // Size returns the size of the sample at the time the snapshot was taken.
@@ -394,11 +479,63 @@ func SampleVariance(values []int64) float64 {
 	return sum / float64(len(values))
 }
 
+// runningStats are the exact count, minimum, maximum, sum, mean and variance
+// of every value recorded, kept without storing the values by the samples
+// which count rather than sample them.  The zero value is empty.
+type runningStats struct {
+	count, min, max, sum int64
+	mean, m2             float64
+}
+
+// update records a new value.  Welford's method keeps the variance exact.
+func (s *runningStats) update(v int64) {
+	if 0 == s.count || v < s.min {
+		s.min = v
+	}
+	if 0 == s.count || v > s.max {
+		s.max = v
+	}
+	s.count++
+	s.sum += v
+	delta := float64(v) - s.mean
+	s.mean += delta / float64(s.count)
+	s.m2 += delta * (float64(v) - s.mean)
+}
+
+// merge records every value recorded by o, combining the variances by Chan
+// et al's parallel form of Welford's method.
+func (s *runningStats) merge(o runningStats) {
+	if 0 == o.count {
+		return
+	}
+	if 0 == s.count || o.min < s.min {
+		s.min = o.min
+	}
+	if 0 == s.count || o.max > s.max {
+		s.max = o.max
+	}
+	s.sum += o.sum
+	count := s.count + o.count
+	delta := o.mean - s.mean
+	s.mean += delta * float64(o.count) / float64(count)
+	s.m2 += o.m2 + delta*delta*float64(s.count)*float64(o.count)/float64(count)
+	s.count = count
+}
+
+// variance returns the population variance of the values recorded.
+func (s *runningStats) variance() float64 {
+	if 0 == s.count {
+		return 0.0
+	}
+	return s.m2 / float64(s.count)
+}
+
 // A uniform sample using Vitter's Algorithm R.
 //
 // <http://www.cs.umd.edu/~samir/498/vitter.pdf>
 type UniformSample struct {
 	count         int64
+	method        PercentileMethod
 	mutex         sync.Mutex
 	reservoirSize int
 	values        []int64
@@ -407,15 +544,50 @@ type UniformSample struct {
 // NewUniformSample constructs a new uniform sample with the given reservoir
 // size.
 func NewUniformSample(reservoirSize int) Sample {
+	return NewUniformSampleWithMethod(reservoirSize, PercentileWeibull)
+}
+
+// NewUniformSampleChecked constructs a new uniform sample with the given
+// reservoir size, returning an error instead if the size is less than one or
+// greater than MaxUniformSampleSize.
+func NewUniformSampleChecked(reservoirSize int) (Sample, error) {
+	if err := checkUniformSampleSize(reservoirSize); nil != err {
+		return nil, err
+	}
+	return NewUniformSample(reservoirSize), nil
+}
+
+// NewUniformSampleWithMethod constructs a new uniform sample with the given
+// reservoir size which computes percentiles using the given PercentileMethod.
+// A size less than one is replaced by one.  Sizes outside of the range
+// accepted by NewUniformSampleChecked are logged.
+func NewUniformSampleWithMethod(reservoirSize int, method PercentileMethod) Sample {
 	if UseNilMetrics {
 		return NilSample{}
 	}
+	if err := checkUniformSampleSize(reservoirSize); nil != err {
+		log.Printf("WARNING: %v", err)
+		if reservoirSize < 1 {
+			reservoirSize = 1
+		}
+	}
 	return &UniformSample{
+		method:        method,
 		reservoirSize: reservoirSize,
 		values:        make([]int64, 0, reservoirSize),
 	}
 }
 
+func checkUniformSampleSize(reservoirSize int) error {
+	if reservoirSize < 1 {
+		return ErrInvalidReservoirSize
+	}
+	if reservoirSize > MaxUniformSampleSize {
+		return fmt.Errorf("uniform sample reservoir size %d exceeds MaxUniformSampleSize %d", reservoirSize, MaxUniformSampleSize)
+	}
+	return nil
+}
+
 // Clear clears all samples.
 func (s *UniformSample) Clear() {
 	s.mutex.Lock()
@@ -447,6 +619,44 @@ func (s *UniformSample) Mean() float64 {
 	return SampleMean(s.values)
 }
 
+// Merge combines the given UniformSample into this one so that it
+// approximates a uniform sample of the union of both streams.  Each value in
+// the merged reservoir is drawn without replacement from one reservoir or
+// the other with probability proportional to the number of values each
+// sample has recorded.  It returns ErrIncompatibleSamples, and changes
+// nothing, if the other sample is not a UniformSample.
+func (s *UniformSample) Merge(other Sample) error {
+	o, ok := other.(*UniformSample)
+	if !ok {
+		return ErrIncompatibleSamples
+	}
+	snapshot := o.Snapshot().(*SampleSnapshot) // so that s == other cannot deadlock
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	a := make([]int64, len(s.values))
+	copy(a, s.values)
+	b := snapshot.values
+	wa, wb := float64(s.count), float64(snapshot.count)
+	n := len(a) + len(b)
+	if n > s.reservoirSize {
+		n = s.reservoirSize
+	}
+	values := make([]int64, 0, s.reservoirSize)
+	for len(values) < n {
+		from := &b
+		if 0 == len(b) || 0 != len(a) && rand.Float64()*(wa+wb) < wa {
+			from = &a
+		}
+		j := rand.Intn(len(*from))
+		values = append(values, (*from)[j])
+		(*from)[j] = (*from)[len(*from)-1]
+		*from = (*from)[:len(*from)-1]
+	}
+	s.values = values
+	s.count += snapshot.count
+	return nil
+}
+
 // Min returns the minimum value in the sample, which may not be the minimum
 // value ever to be part of the sample.
 func (s *UniformSample) Min() int64 {
@@ -457,9 +667,7 @@ func (s *UniformSample) Min() int64 {
 
 // Percentile returns an arbitrary percentile of values in the sample.
 func (s *UniformSample) Percentile(p float64) float64 {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	return SamplePercentile(s.values, p)
+	return s.Percentiles([]float64{p})[0]
 }
 
 // Percentiles returns a slice of arbitrary percentiles of values in the
@@ -467,7 +675,39 @@ func (s *UniformSample) Percentile(p float64) float64 {
 func (s *UniformSample) Percentiles(ps []float64) []float64 {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	return SamplePercentiles(s.values, ps)
+	return SamplePercentilesWithMethod(s.values, ps, s.method)
+}
+
+// Resize changes the reservoir size to n, keeping the count and the values
+// already sampled.  A size less than one is replaced by one and sizes outside
+// of the range accepted by NewUniformSampleChecked are logged.
+//
+// Shrinking keeps a uniformly random subset of n of the values, so that the
+// sample remains a uniform sample of every value recorded.  Growing cannot
+// recover values which have already been discarded: until the reservoir
+// fills again every new value is kept, so the sample over-represents values
+// recorded after the resize, and only approaches a uniform sample of every
+// value recorded as further values replace the older survivors.
+func (s *UniformSample) Resize(n int) {
+	if err := checkUniformSampleSize(n); nil != err {
+		log.Printf("WARNING: %v", err)
+		if n < 1 {
+			n = 1
+		}
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.values) > n {
+		for i := 0; i < n; i++ {
+			j := i + rand.Intn(len(s.values)-i)
+			s.values[i], s.values[j] = s.values[j], s.values[i]
+		}
+		s.values = s.values[:n]
+	}
+	values := make([]int64, len(s.values), n)
+	copy(values, s.values)
+	s.reservoirSize = n
+	s.values = values
 }
 
 // Size returns the size of the sample, which is at most the reservoir size.
@@ -485,6 +725,7 @@ func (s *UniformSample) Snapshot() Sample {
 	copy(values, s.values)
 	return &SampleSnapshot{
 		count:  s.count,
+		method: s.method,
 		values: values,
 	}
 }
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+)
+
+// CompositeSample is a Sample which keeps exact running statistics of every
+// value alongside a reservoir Sample used only for percentiles.  Count, Max,
+// Mean, Min, StdDev, Sum and Variance are exact even when the extreme values
+// have been evicted from the reservoir; Percentile, Percentiles, Size and
+// Values come from the reservoir.
+type CompositeSample struct {
+	mutex     sync.Mutex
+	reservoir Sample
+
+	stats runningStats
+}
+
+// NewCompositeSample constructs a new CompositeSample which delegates
+// percentiles to the given reservoir.
+func NewCompositeSample(reservoir Sample) Sample {
+	if UseNilMetrics {
+		return NilSample{}
+	}
+	return &CompositeSample{reservoir: reservoir}
+}
+
+// Clear clears all samples.
+func (s *CompositeSample) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.reservoir.Clear()
+	s.stats = runningStats{}
+}
+
+// Count returns the number of samples recorded.
+func (s *CompositeSample) Count() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.count
+}
+
+// Max returns the maximum value recorded.
+func (s *CompositeSample) Max() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.max
+}
+
+// Mean returns the mean of the values recorded.
+func (s *CompositeSample) Mean() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.mean
+}
+
+// Min returns the minimum value recorded.
+func (s *CompositeSample) Min() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.min
+}
+
+// Percentile returns an arbitrary percentile of the values in the reservoir.
+func (s *CompositeSample) Percentile(p float64) float64 {
+	return s.reservoir.Percentile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of the values in the
+// reservoir.
+func (s *CompositeSample) Percentiles(ps []float64) []float64 {
+	return s.reservoir.Percentiles(ps)
+}
+
+// Size returns the size of the reservoir.
+func (s *CompositeSample) Size() int {
+	return s.reservoir.Size()
+}
+
+// Snapshot returns a read-only copy of the sample.
+func (s *CompositeSample) Snapshot() Sample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return &CompositeSample{
+		reservoir: s.reservoir.Snapshot(),
+		stats:     s.stats,
+	}
+}
+
+// StdDev returns the standard deviation of the values recorded.
+func (s *CompositeSample) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// Sum returns the sum of the values recorded.
+func (s *CompositeSample) Sum() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.sum
+}
+
+// Update samples a new value.
+func (s *CompositeSample) Update(v int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.reservoir.Update(v)
+	s.stats.update(v)
+}
+
+// Values returns a copy of the values in the reservoir.
+func (s *CompositeSample) Values() []int64 {
+	return s.reservoir.Values()
+}
+
+// Variance returns the variance of the values recorded.
+func (s *CompositeSample) Variance() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.variance()
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func BenchmarkCompositeSample(b *testing.B) {
+	s := NewCompositeSample(NewUniformSample(1028))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Update(int64(i))
+	}
+}
+
+func TestCompositeSampleExactMax(t *testing.T) {
+	reservoir := NewUniformSample(10)
+	s := NewCompositeSample(reservoir)
+	s.Update(1000000)
+	count, sum := int64(1), int64(1000000)
+	evicted := func() bool {
+		for _, v := range reservoir.Values() {
+			if 1000000 == v {
+				return false
+			}
+		}
+		return true
+	}
+	for i := int64(1); !evicted(); i++ {
+		s.Update(i)
+		count++
+		sum += i
+	}
+	if max := reservoir.Max(); 1000000 == max {
+		t.Fatal(max)
+	}
+	if max := s.Max(); 1000000 != max {
+		t.Errorf("s.Max(): 1000000 != %v\n", max)
+	}
+	if min := s.Min(); 1 != min {
+		t.Errorf("s.Min(): 1 != %v\n", min)
+	}
+	if c := s.Count(); count != c {
+		t.Errorf("s.Count(): %v != %v\n", count, c)
+	}
+	if total := s.Sum(); sum != total {
+		t.Errorf("s.Sum(): %v != %v\n", sum, total)
+	}
+	if mean := s.Mean(); math.Abs(float64(sum)/float64(count)-mean) > 1e-6 {
+		t.Errorf("s.Mean(): %v != %v\n", float64(sum)/float64(count), mean)
+	}
+}
+
+func TestCompositeSampleVariance(t *testing.T) {
+	s := NewCompositeSample(NewUniformSample(2))
+	for _, v := range []int64{2, 4, 4, 4, 5, 5, 7, 9} {
+		s.Update(v)
+	}
+	if v := s.Variance(); 4 != v {
+		t.Errorf("s.Variance(): 4 != %v\n", v)
+	}
+	if d := s.StdDev(); 2 != d {
+		t.Errorf("s.StdDev(): 2 != %v\n", d)
+	}
+}
+
+func TestCompositeSampleSnapshot(t *testing.T) {
+	s := NewCompositeSample(NewUniformSample(100))
+	for i := int64(1); i <= 100; i++ {
+		s.Update(i)
+	}
+	snapshot := s.Snapshot()
+	s.Update(1000)
+	if max := snapshot.Max(); 100 != max {
+		t.Errorf("snapshot.Max(): 100 != %v\n", max)
+	}
+	if p := snapshot.Percentile(0.5); 50.5 != p {
+		t.Errorf("snapshot.Percentile(0.5): 50.5 != %v\n", p)
+	}
+	s.Clear()
+	if count := s.Count(); 0 != count {
+		t.Errorf("s.Count(): 0 != %v\n", count)
+	}
+	if size := s.Size(); 0 != size {
+		t.Errorf("s.Size(): 0 != %v\n", size)
+	}
+}
+
+func TestCompositeSampleHistogram(t *testing.T) {
+	h := NewHistogram(NewCompositeSample(NewUniformSample(10)))
+	for i := int64(1); i <= 1000; i++ {
+		h.Update(i)
+	}
+	if max := h.Snapshot().Max(); 1000 != max {
+		t.Errorf("h.Snapshot().Max(): 1000 != %v\n", max)
+	}
+}
@@ -0,0 +1,286 @@
+package metrics
+
+import (
+	"errors"
+	"log"
+	"math"
+	"sort"
+	"sync"
+)
+
+// DefaultExponentialSampleFactor is the factor used by NewExponentialSample
+// in place of an invalid one.
+const DefaultExponentialSampleFactor = 2
+
+// ErrInvalidExponentialFactor is the error returned by
+// NewExponentialSampleChecked when the factor is not a finite number greater
+// than one.
+var ErrInvalidExponentialFactor = errors.New("exponential sample factor must be finite and greater than one")
+
+// ErrIncompatibleSamples is the error returned by ExponentialSample.Merge
+// when the samples' buckets do not line up, and by UniformSample.Merge when
+// the other sample is not a UniformSample.
+var ErrIncompatibleSamples = errors.New("incompatible samples")
+
+// ExponentialSample is a Sample which counts every value in sparse buckets
+// whose bounds grow by a constant factor, so that bucket i holds the values
+// in (factor^(i-1), factor^i] and negative values are recorded as zero.
+// Unlike the values in a reservoir, the bucket counts of samples with the
+// same factor may be summed by Merge, i.e. to aggregate the latencies of
+// many processes.  Percentiles are reported as the upper bound of their
+// bucket, clamped to the minimum and maximum, to within a relative error of
+// factor-1.  Count, Max, Mean, Min, StdDev, Sum and Variance are exact.
+type ExponentialSample struct {
+	mutex sync.Mutex
+
+	factor    float64
+	logFactor float64
+	counts    map[int]int64 // bucket index to count, for positive values
+	zeros     int64
+
+	stats runningStats
+}
+
+// NewExponentialSample constructs a new ExponentialSample whose bucket
+// bounds grow by the given factor, i.e. 2 for base-2 buckets or 1.1 for
+// percentiles to within 10%.  A factor which is not a finite number greater
+// than one is logged and replaced by DefaultExponentialSampleFactor.
+func NewExponentialSample(factor float64) Sample {
+	if UseNilMetrics {
+		return NilSample{}
+	}
+	if nil != checkExponentialFactor(factor) {
+		log.Printf("WARNING: %v: %v", ErrInvalidExponentialFactor, factor)
+		factor = DefaultExponentialSampleFactor
+	}
+	return &ExponentialSample{
+		factor:    factor,
+		logFactor: math.Log(factor),
+		counts:    make(map[int]int64),
+	}
+}
+
+// NewExponentialSampleChecked constructs a new ExponentialSample whose bucket
+// bounds grow by the given factor, returning ErrInvalidExponentialFactor
+// instead if the factor is not a finite number greater than one.
+func NewExponentialSampleChecked(factor float64) (Sample, error) {
+	if err := checkExponentialFactor(factor); nil != err {
+		return nil, err
+	}
+	return NewExponentialSample(factor), nil
+}
+
+func checkExponentialFactor(factor float64) error {
+	if !(factor > 1) || math.IsInf(factor, 1) {
+		return ErrInvalidExponentialFactor
+	}
+	return nil
+}
+
+// Clear clears all samples, zeroing the bucket counts in place so that the
+// buckets are reused rather than reallocated.
+func (s *ExponentialSample) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.counts {
+		s.counts[i] = 0
+	}
+	s.zeros = 0
+	s.stats = runningStats{}
+}
+
+// Count returns the number of samples recorded.
+func (s *ExponentialSample) Count() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.count
+}
+
+// Factor returns the factor by which the sample's bucket bounds grow.
+func (s *ExponentialSample) Factor() float64 {
+	return s.factor
+}
+
+// Max returns the maximum value recorded.
+func (s *ExponentialSample) Max() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.max
+}
+
+// Mean returns the mean of the values recorded.
+func (s *ExponentialSample) Mean() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.mean
+}
+
+// Merge adds the counts and statistics of the given sample to this one, as
+// if every value recorded by it had been recorded by this one.  It returns
+// ErrIncompatibleSamples, and changes nothing, if the other sample's factor
+// differs.
+func (s *ExponentialSample) Merge(other *ExponentialSample) error {
+	if s.factor != other.factor {
+		return ErrIncompatibleSamples
+	}
+	o := other.Snapshot().(*ExponentialSample) // so that s == other cannot deadlock
+	if 0 == o.stats.count {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, c := range o.counts {
+		s.counts[i] += c
+	}
+	s.zeros += o.zeros
+	s.stats.merge(o.stats)
+	return nil
+}
+
+// Min returns the minimum value recorded.
+func (s *ExponentialSample) Min() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.min
+}
+
+// Percentile returns an arbitrary percentile of values recorded.
+func (s *ExponentialSample) Percentile(p float64) float64 {
+	return s.Percentiles([]float64{p})[0]
+}
+
+// Percentiles returns a slice of arbitrary percentiles of values recorded.
+func (s *ExponentialSample) Percentiles(ps []float64) []float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	scores := make([]float64, len(ps))
+	if 0 == s.stats.count {
+		return scores
+	}
+	indexes := s.indexes()
+	for i, p := range ps {
+		rank := int64(math.Ceil(p * float64(s.stats.count)))
+		if rank < 1 {
+			rank = 1
+		}
+		seen := s.zeros
+		if seen < rank {
+			for _, j := range indexes {
+				if seen += s.counts[j]; seen >= rank {
+					scores[i] = s.upperBound(j)
+					break
+				}
+			}
+		}
+		if scores[i] > float64(s.stats.max) {
+			scores[i] = float64(s.stats.max)
+		} else if scores[i] < float64(s.stats.min) {
+			scores[i] = float64(s.stats.min)
+		}
+	}
+	return scores
+}
+
+// Size returns the number of samples recorded, since every value is counted.
+func (s *ExponentialSample) Size() int {
+	return int(s.Count())
+}
+
+// Snapshot returns an independent copy of the sample, which may itself be
+// merged into other samples.
+func (s *ExponentialSample) Snapshot() Sample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	snapshot := &ExponentialSample{
+		factor:    s.factor,
+		logFactor: s.logFactor,
+		counts:    make(map[int]int64, len(s.counts)),
+		zeros:     s.zeros,
+		stats:     s.stats,
+	}
+	for i, c := range s.counts {
+		snapshot.counts[i] = c
+	}
+	return snapshot
+}
+
+// StdDev returns the standard deviation of the values recorded.
+func (s *ExponentialSample) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// Sum returns the sum of the values recorded.
+func (s *ExponentialSample) Sum() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.sum
+}
+
+// Update records a new value.
+func (s *ExponentialSample) Update(v int64) {
+	if v < 0 {
+		v = 0
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if 0 == v {
+		s.zeros++
+	} else {
+		s.counts[s.index(v)]++
+	}
+	s.stats.update(v)
+}
+
+// Values returns the upper bound of its bucket, clamped to the maximum, for
+// each value recorded.  This allocates one int64 per recorded value.
+func (s *ExponentialSample) Values() []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	values := make([]int64, s.zeros, s.stats.count)
+	for _, i := range s.indexes() {
+		v := int64(math.Min(s.upperBound(i), float64(s.stats.max)))
+		for c := s.counts[i]; c > 0; c-- {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Variance returns the variance of the values recorded.
+func (s *ExponentialSample) Variance() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.variance()
+}
+
+// indexes returns the indexes of the non-empty buckets in ascending order.
+// It must be called with the mutex held.
+func (s *ExponentialSample) indexes() []int {
+	indexes := make([]int, 0, len(s.counts))
+	for i, c := range s.counts {
+		if 0 != c {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// index returns the index of the bucket holding the given positive value.
+// The quotient of logarithms may round to either side of an integer at an
+// exact power of the factor, so it is corrected against the bucket bounds.
+func (s *ExponentialSample) index(v int64) int {
+	f := float64(v)
+	i := int(math.Ceil(math.Log(f) / s.logFactor))
+	for f > s.upperBound(i) {
+		i++
+	}
+	for f <= s.upperBound(i-1) {
+		i--
+	}
+	return i
+}
+
+func (s *ExponentialSample) upperBound(i int) float64 {
+	return math.Pow(s.factor, float64(i))
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func BenchmarkExponentialSample(b *testing.B) {
+	benchmarkSample(b, NewExponentialSample(1.1))
+}
+
+func TestExponentialSample(t *testing.T) {
+	s := NewExponentialSample(2)
+	for _, v := range []int64{0, 1, 3, 5, 100, -1} {
+		s.Update(v)
+	}
+	if count := s.Count(); 6 != count {
+		t.Errorf("s.Count(): 6 != %v\n", count)
+	}
+	if min := s.Min(); 0 != min {
+		t.Errorf("s.Min(): 0 != %v\n", min)
+	}
+	if max := s.Max(); 100 != max {
+		t.Errorf("s.Max(): 100 != %v\n", max)
+	}
+	if sum := s.Sum(); 109 != sum {
+		t.Errorf("s.Sum(): 109 != %v\n", sum)
+	}
+	// 0, 0, 1, (2, 4], (4, 8], (64, 128] clamped to 100
+	ps := s.Percentiles([]float64{0.25, 0.5, 0.75, 1.0})
+	if 0 != ps[0] || 1 != ps[1] || 8 != ps[2] || 100 != ps[3] {
+		t.Fatal(ps)
+	}
+	values := s.Values()
+	if 6 != len(values) || 0 != values[0] || 4 != values[3] || 100 != values[5] {
+		t.Fatal(values)
+	}
+	s.Clear()
+	if count := s.Count(); 0 != count {
+		t.Errorf("s.Count(): 0 != %v\n", count)
+	}
+}
+
+func TestExponentialSampleError(t *testing.T) {
+	s := NewExponentialSample(1.1)
+	for i := int64(1); i <= 10000; i++ {
+		s.Update(i)
+	}
+	for _, p := range []float64{0.5, 0.9, 0.99} {
+		exact := p * 10000
+		if v := s.Percentile(p); v < exact || v > exact*1.1 {
+			t.Errorf("s.Percentile(%v): %v not within 10%% above %v\n", p, v, exact)
+		}
+	}
+	if mean := s.Mean(); math.Abs(5000.5-mean) > 1e-6 {
+		t.Errorf("s.Mean(): 5000.5 != %v\n", mean)
+	}
+}
+
+func TestExponentialSampleMerge(t *testing.T) {
+	a, b := NewExponentialSample(2).(*ExponentialSample), NewExponentialSample(2).(*ExponentialSample)
+	for i := int64(1); i <= 10; i++ {
+		a.Update(i)
+		b.Update(i + 10)
+	}
+	if err := a.Merge(b); nil != err {
+		t.Fatal(err)
+	}
+	if count := a.Count(); 20 != count {
+		t.Errorf("a.Count(): 20 != %v\n", count)
+	}
+	if min, max := a.Min(), a.Max(); 1 != min || 20 != max {
+		t.Fatal(min, max)
+	}
+	if mean := a.Mean(); math.Abs(10.5-mean) > 1e-9 {
+		t.Errorf("a.Mean(): 10.5 != %v\n", mean)
+	}
+	if variance := a.Variance(); math.Abs(33.25-variance) > 1e-9 {
+		t.Errorf("a.Variance(): 33.25 != %v\n", variance)
+	}
+	if err := a.Merge(a); nil != err {
+		t.Fatal(err)
+	}
+	if count := a.Count(); 40 != count {
+		t.Errorf("a.Count(): 40 != %v\n", count)
+	}
+	if err := a.Merge(NewExponentialSample(1.5).(*ExponentialSample)); ErrIncompatibleSamples != err {
+		t.Fatal(err)
+	}
+}
+
+func TestExponentialSampleClearReusesBuckets(t *testing.T) {
+	s := NewExponentialSample(2).(*ExponentialSample)
+	s.Update(3)
+	s.Update(100)
+	s.Clear()
+	if 2 != len(s.counts) {
+		t.Fatal("buckets discarded by Clear")
+	}
+	if p := s.Percentile(0.5); 0 != p {
+		t.Errorf("s.Percentile(0.5): 0 != %v\n", p)
+	}
+	s.Update(3)
+	if count, max := s.Count(), s.Max(); 1 != count || 3 != max {
+		t.Fatal(count, max)
+	}
+	if p := s.Percentile(1.0); 3 != p {
+		t.Errorf("s.Percentile(1.0): 3 != %v\n", p)
+	}
+}
+
+func TestExponentialSampleExactPowers(t *testing.T) {
+	for _, factor := range []float64{2, 3, 10, 1.1} {
+		s := NewExponentialSample(factor).(*ExponentialSample)
+		for i := 1; s.upperBound(i) < 1<<53; i++ { // exactly representable
+			v := int64(s.upperBound(i))
+			if float64(v) != s.upperBound(i) {
+				continue
+			}
+			if j := s.index(v); i != j {
+				t.Errorf("s.index(%v) with factor %v: %v != %v\n", v, factor, i, j)
+			}
+			if j := s.index(v + 1); i+1 != j {
+				t.Errorf("s.index(%v) with factor %v: %v != %v\n", v+1, factor, i+1, j)
+			}
+		}
+	}
+	s := NewExponentialSample(2)
+	s.Update(1024)
+	s.Update(1025)
+	if values := s.Values(); 1024 != values[0] || 1025 != values[1] {
+		t.Fatal(values)
+	}
+}
+
+func TestExponentialSampleInvalidFactor(t *testing.T) {
+	for _, factor := range []float64{1, 0.5, -2, math.NaN(), math.Inf(1)} {
+		if s, err := NewExponentialSampleChecked(factor); ErrInvalidExponentialFactor != err || nil != s {
+			t.Errorf("NewExponentialSampleChecked(%v): %v, %v\n", factor, s, err)
+		}
+		if f := NewExponentialSample(factor).(*ExponentialSample).Factor(); DefaultExponentialSampleFactor != f {
+			t.Errorf("NewExponentialSample(%v).Factor(): %v != %v\n", factor, DefaultExponentialSampleFactor, f)
+		}
+	}
+	if s, err := NewExponentialSampleChecked(1.5); nil != err || 1.5 != s.(*ExponentialSample).Factor() {
+		t.Fatal(s, err)
+	}
+}
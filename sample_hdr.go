@@ -0,0 +1,256 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// HdrSample is a Sample which counts every value in a log-linear bucketed
+// histogram, after HdrHistogram, rather than keeping a reservoir.  Each value
+// is recorded to within a relative error of 10^-sigfigs, so percentiles
+// carry no sampling error and tail values are never evicted.  Values below
+// zero are recorded as zero and values above the sample's maximum as the
+// maximum.  Count is exact, and Max, Mean, Min, StdDev, Sum and Variance
+// are exact for the values as recorded, so only if no value was clamped.
+//
+// <http://hdrhistogram.org/>
+type HdrSample struct {
+	mutex sync.Mutex
+
+	max                         int64
+	subBucketHalfCountMagnitude uint
+	subBucketHalfCount          int64
+	subBucketMask               int64
+	counts                      []int64
+
+	stats runningStats
+}
+
+// NewHdrSample constructs a new HdrSample tracking values from 0 to max with
+// the given number of significant decimal digits.  It panics if max is less
+// than 1 or sigfigs is not between 1 and 5.  Values recorded outside the
+// range from 0 to max are clamped to it.
+func NewHdrSample(max int64, sigfigs int) Sample {
+	if UseNilMetrics {
+		return NilSample{}
+	}
+	if 1 > max {
+		panic(fmt.Sprintf("metrics: HdrSample max %d is less than 1", max))
+	}
+	if 1 > sigfigs || 5 < sigfigs {
+		panic(fmt.Sprintf("metrics: HdrSample sigfigs %d is not between 1 and 5", sigfigs))
+	}
+
+	// Values below subBucketCount are recorded exactly; each further bucket
+	// covers twice the range of the last at half the resolution.
+	largestValueWithSingleUnitResolution := 2 * int64(math.Pow10(sigfigs))
+	subBucketCountMagnitude := bitLength(largestValueWithSingleUnitResolution - 1)
+	subBucketCount := int64(1) << subBucketCountMagnitude
+	bucketCount := 1
+	for smallestUntrackable := subBucketCount; smallestUntrackable <= max; bucketCount++ {
+		if smallestUntrackable > math.MaxInt64/2 {
+			bucketCount++
+			break
+		}
+		smallestUntrackable <<= 1
+	}
+
+	return &HdrSample{
+		max:                         max,
+		subBucketHalfCountMagnitude: subBucketCountMagnitude - 1,
+		subBucketHalfCount:          subBucketCount / 2,
+		subBucketMask:               subBucketCount - 1,
+		counts:                      make([]int64, (bucketCount+1)*int(subBucketCount/2)),
+	}
+}
+
+// Clear clears all samples, zeroing the bucket counts in place so that the
+// buckets are reused rather than reallocated.
+func (s *HdrSample) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.counts {
+		s.counts[i] = 0
+	}
+	s.stats = runningStats{}
+}
+
+// Count returns the number of samples recorded.
+func (s *HdrSample) Count() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.count
+}
+
+// Max returns the maximum value recorded.
+func (s *HdrSample) Max() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.max
+}
+
+// Mean returns the mean of the values recorded.
+func (s *HdrSample) Mean() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.mean
+}
+
+// Min returns the minimum value recorded.
+func (s *HdrSample) Min() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.min
+}
+
+// Percentile returns an arbitrary percentile of values recorded.
+func (s *HdrSample) Percentile(p float64) float64 {
+	return s.Percentiles([]float64{p})[0]
+}
+
+// Percentiles returns a slice of arbitrary percentiles of values recorded.
+func (s *HdrSample) Percentiles(ps []float64) []float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	scores := make([]float64, len(ps))
+	if 0 == s.stats.count {
+		return scores
+	}
+	for i, p := range ps {
+		rank := int64(math.Ceil(p * float64(s.stats.count)))
+		if rank < 1 {
+			rank = 1
+		}
+		var seen int64
+		for j, c := range s.counts {
+			if seen += c; seen >= rank {
+				scores[i] = float64(s.highestEquivalentValue(s.valueFromIndex(j)))
+				break
+			}
+		}
+		if scores[i] > float64(s.stats.max) {
+			scores[i] = float64(s.stats.max)
+		} else if scores[i] < float64(s.stats.min) {
+			scores[i] = float64(s.stats.min)
+		}
+	}
+	return scores
+}
+
+// Size returns the number of samples recorded, since every value is kept.
+func (s *HdrSample) Size() int {
+	return int(s.Count())
+}
+
+// Snapshot returns an independent copy of the sample.
+func (s *HdrSample) Snapshot() Sample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	snapshot := &HdrSample{
+		max:                         s.max,
+		subBucketHalfCountMagnitude: s.subBucketHalfCountMagnitude,
+		subBucketHalfCount:          s.subBucketHalfCount,
+		subBucketMask:               s.subBucketMask,
+		counts:                      make([]int64, len(s.counts)),
+		stats:                       s.stats,
+	}
+	copy(snapshot.counts, s.counts)
+	return snapshot
+}
+
+// StdDev returns the standard deviation of the values recorded.
+func (s *HdrSample) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// Sum returns the sum of the values recorded.
+func (s *HdrSample) Sum() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.sum
+}
+
+// Update records a new value, clamped to the range from 0 to max.
+func (s *HdrSample) Update(v int64) {
+	if v < 0 {
+		v = 0
+	} else if v > s.max {
+		v = s.max
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.counts[s.countsIndex(v)]++
+	s.stats.update(v)
+}
+
+// Values returns a value equivalent to each value recorded, to within the
+// sample's precision.  This allocates one int64 per recorded value.
+func (s *HdrSample) Values() []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	values := make([]int64, 0, s.stats.count)
+	for i, c := range s.counts {
+		if 0 == c {
+			continue
+		}
+		v := s.medianEquivalentValue(s.valueFromIndex(i))
+		for ; c > 0; c-- {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Variance returns the variance of the values recorded.
+func (s *HdrSample) Variance() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats.variance()
+}
+
+func (s *HdrSample) bucketIndex(v int64) uint {
+	return bitLength(v|s.subBucketMask) - (s.subBucketHalfCountMagnitude + 1)
+}
+
+func (s *HdrSample) countsIndex(v int64) int {
+	bucketIndex := s.bucketIndex(v)
+	subBucketIndex := v >> bucketIndex
+	return int(int64(bucketIndex+1)<<s.subBucketHalfCountMagnitude + subBucketIndex - s.subBucketHalfCount)
+}
+
+// valueFromIndex returns the lowest value recorded at the given index.
+func (s *HdrSample) valueFromIndex(i int) int64 {
+	bucketIndex := i>>s.subBucketHalfCountMagnitude - 1
+	subBucketIndex := int64(i)&(s.subBucketHalfCount-1) + s.subBucketHalfCount
+	if bucketIndex < 0 {
+		subBucketIndex -= s.subBucketHalfCount
+		bucketIndex = 0
+	}
+	return subBucketIndex << uint(bucketIndex)
+}
+
+func (s *HdrSample) equivalentRange(v int64) int64 {
+	bucketIndex := s.bucketIndex(v)
+	if v>>bucketIndex > s.subBucketMask {
+		bucketIndex++
+	}
+	return int64(1) << bucketIndex
+}
+
+func (s *HdrSample) highestEquivalentValue(v int64) int64 {
+	return v + s.equivalentRange(v) - 1
+}
+
+func (s *HdrSample) medianEquivalentValue(v int64) int64 {
+	return v + s.equivalentRange(v)>>1
+}
+
+// bitLength returns the number of bits required to represent v.
+func bitLength(v int64) uint {
+	var n uint
+	for ; 0 != v; v >>= 1 {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func BenchmarkHdrSample(b *testing.B) {
+	benchmarkSample(b, NewHdrSample(3600e9, 3))
+}
+
+func TestHdrSample(t *testing.T) {
+	s := NewHdrSample(1000, 3)
+	for i := 1; i <= 1000; i++ {
+		s.Update(int64(i))
+	}
+	if size := s.Count(); 1000 != size {
+		t.Errorf("s.Count(): 1000 != %v\n", size)
+	}
+	if size := s.Size(); 1000 != size {
+		t.Errorf("s.Size(): 1000 != %v\n", size)
+	}
+	if min := s.Min(); 1 != min {
+		t.Errorf("s.Min(): 1 != %v\n", min)
+	}
+	if max := s.Max(); 1000 != max {
+		t.Errorf("s.Max(): 1000 != %v\n", max)
+	}
+	if mean := s.Mean(); 500.5 != mean {
+		t.Errorf("s.Mean(): 500.5 != %v\n", mean)
+	}
+	if sum := s.Sum(); 500500 != sum {
+		t.Errorf("s.Sum(): 500500 != %v\n", sum)
+	}
+	if variance := s.Variance(); 83333.25 != variance {
+		t.Errorf("s.Variance(): 83333.25 != %v\n", variance)
+	}
+	ps := s.Percentiles([]float64{0.5, 0.75, 0.99})
+	if 500.0 != ps[0] {
+		t.Errorf("median: 500.0 != %v\n", ps[0])
+	}
+	if 750.0 != ps[1] {
+		t.Errorf("75th percentile: 750.0 != %v\n", ps[1])
+	}
+	if 990.0 != ps[2] {
+		t.Errorf("99th percentile: 990.0 != %v\n", ps[2])
+	}
+}
+
+func TestHdrSamplePercentileAccuracy(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, sigfigs := range []int{1, 2, 3, 4} {
+		s := NewHdrSample(3600e9, sigfigs)
+		values := make([]int64, 100000)
+		for i := range values {
+			// Log-normal latencies centered on a millisecond, in nanoseconds.
+			values[i] = int64(math.Exp(r.NormFloat64()*2 + math.Log(1e6)))
+			s.Update(values[i])
+		}
+		sort.Sort(int64Slice(values))
+		bound := math.Pow10(-sigfigs)
+		for _, p := range []float64{0.01, 0.1, 0.5, 0.9, 0.99, 0.999, 0.9999} {
+			expected := float64(values[int(math.Ceil(p*float64(len(values))))-1])
+			if actual := s.Percentile(p); math.Abs(actual-expected)/expected > bound {
+				t.Errorf("sigfigs %d, percentile %v: |%v - %v| / %v > %v\n", sigfigs, p, actual, expected, expected, bound)
+			}
+		}
+	}
+}
+
+func TestHdrSampleClamp(t *testing.T) {
+	s := NewHdrSample(1000, 2)
+	s.Update(-1)
+	s.Update(1e9)
+	if min := s.Min(); 0 != min {
+		t.Errorf("s.Min(): 0 != %v\n", min)
+	}
+	if max := s.Max(); 1000 != max {
+		t.Errorf("s.Max(): 1000 != %v\n", max)
+	}
+	if p := s.Percentile(1.0); 1000.0 != p {
+		t.Errorf("s.Percentile(1.0): 1000.0 != %v\n", p)
+	}
+}
+
+func TestHdrSampleClear(t *testing.T) {
+	s := NewHdrSample(1000, 3)
+	s.Update(47)
+	s.Clear()
+	if count := s.Count(); 0 != count {
+		t.Errorf("s.Count(): 0 != %v\n", count)
+	}
+	if p := s.Percentile(0.5); 0.0 != p {
+		t.Errorf("s.Percentile(0.5): 0.0 != %v\n", p)
+	}
+}
+
+func TestHdrSampleClearReusesBuckets(t *testing.T) {
+	s := NewHdrSample(1000, 3).(*HdrSample)
+	s.Update(47)
+	counts := s.counts
+	s.Clear()
+	s.Update(48)
+	if &counts[0] != &s.counts[0] || len(counts) != len(s.counts) {
+		t.Fatal("buckets reallocated by Clear")
+	}
+	if count := s.Count(); 1 != count {
+		t.Errorf("s.Count(): 1 != %v\n", count)
+	}
+	if min := s.Min(); 48 != min {
+		t.Errorf("s.Min(): 48 != %v\n", min)
+	}
+}
+
+func TestHdrSampleHistogram(t *testing.T) {
+	h := NewHistogram(NewHdrSample(1000, 3))
+	for i := 1; i <= 100; i++ {
+		h.Update(int64(i))
+	}
+	snapshot := h.Snapshot()
+	h.Update(1000)
+	if count := snapshot.Count(); 100 != count {
+		t.Errorf("snapshot.Count(): 100 != %v\n", count)
+	}
+	if p := snapshot.Percentile(0.99); 99.0 != p {
+		t.Errorf("snapshot.Percentile(0.99): 99.0 != %v\n", p)
+	}
+	if values := snapshot.Sample().Values(); 100 != len(values) || 1 != values[0] || 100 != values[99] {
+		t.Fatal(values)
+	}
+}
+
+func TestNewHdrSampleInvalid(t *testing.T) {
+	for _, c := range []struct {
+		max     int64
+		sigfigs int
+	}{{0, 3}, {1000, 0}, {1000, 6}} {
+		func() {
+			defer func() {
+				if nil == recover() {
+					t.Errorf("NewHdrSample(%v, %v) did not panic\n", c.max, c.sigfigs)
+				}
+			}()
+			NewHdrSample(c.max, c.sigfigs)
+		}()
+	}
+}
@@ -0,0 +1,144 @@
+package metrics
+
+import "sync"
+
+// SlidingWindowSample is a Sample which keeps exactly the most recent
+// values in a ring buffer, overwriting the oldest, so that its statistics
+// describe the last n values recorded regardless of when they were recorded.
+type SlidingWindowSample struct {
+	count  int64
+	mutex  sync.Mutex
+	next   int
+	values []int64
+	window int
+}
+
+// NewSlidingWindowSample constructs a new sliding window sample which keeps
+// the most recent n values.  A size less than one is replaced by one.
+func NewSlidingWindowSample(n int) Sample {
+	if UseNilMetrics {
+		return NilSample{}
+	}
+	if n < 1 {
+		n = 1
+	}
+	return &SlidingWindowSample{
+		values: make([]int64, 0, n),
+		window: n,
+	}
+}
+
+// Clear clears all samples.
+func (s *SlidingWindowSample) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.count = 0
+	s.next = 0
+	s.values = make([]int64, 0, s.window)
+}
+
+// Count returns the number of samples recorded, which may exceed the window
+// size.
+func (s *SlidingWindowSample) Count() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.count
+}
+
+// Max returns the maximum value in the window.
+func (s *SlidingWindowSample) Max() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return SampleMax(s.values)
+}
+
+// Mean returns the mean of the values in the window.
+func (s *SlidingWindowSample) Mean() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return SampleMean(s.values)
+}
+
+// Min returns the minimum value in the window.
+func (s *SlidingWindowSample) Min() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return SampleMin(s.values)
+}
+
+// Percentile returns an arbitrary percentile of values in the window.
+func (s *SlidingWindowSample) Percentile(p float64) float64 {
+	return s.Percentiles([]float64{p})[0]
+}
+
+// Percentiles returns a slice of arbitrary percentiles of values in the
+// window.  The values are sorted in a copy, leaving the ring buffer in the
+// order the values were recorded.
+func (s *SlidingWindowSample) Percentiles(ps []float64) []float64 {
+	return SamplePercentiles(s.Values(), ps)
+}
+
+// Size returns the number of values in the window, which is at most n.
+func (s *SlidingWindowSample) Size() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.values)
+}
+
+// Snapshot returns a read-only copy of the sample.
+func (s *SlidingWindowSample) Snapshot() Sample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return &SampleSnapshot{
+		count:  s.count,
+		values: s.ordered(),
+	}
+}
+
+// StdDev returns the standard deviation of the values in the window.
+func (s *SlidingWindowSample) StdDev() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return SampleStdDev(s.values)
+}
+
+// Sum returns the sum of the values in the window.
+func (s *SlidingWindowSample) Sum() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return SampleSum(s.values)
+}
+
+// Update samples a new value, evicting the oldest if the window is full.
+func (s *SlidingWindowSample) Update(v int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.count++
+	if len(s.values) < s.window {
+		s.values = append(s.values, v)
+		return
+	}
+	s.values[s.next] = v
+	s.next = (s.next + 1) % s.window
+}
+
+// Values returns a copy of the values in the window, oldest first.
+func (s *SlidingWindowSample) Values() []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.ordered()
+}
+
+// Variance returns the variance of the values in the window.
+func (s *SlidingWindowSample) Variance() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return SampleVariance(s.values)
+}
+
+// ordered must be called with the mutex held.
+func (s *SlidingWindowSample) ordered() []int64 {
+	values := make([]int64, 0, len(s.values))
+	values = append(values, s.values[s.next:]...)
+	return append(values, s.values[:s.next]...)
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import "testing"
+
+func TestSlidingWindowSample(t *testing.T) {
+	s := NewSlidingWindowSample(100)
+	for i := 0; i < 200; i++ {
+		s.Update(int64(i))
+	}
+	if count := s.Count(); 200 != count {
+		t.Errorf("s.Count(): 200 != %v\n", count)
+	}
+	if size := s.Size(); 100 != size {
+		t.Errorf("s.Size(): 100 != %v\n", size)
+	}
+	if min := s.Min(); 100 != min {
+		t.Errorf("s.Min(): 100 != %v\n", min)
+	}
+	if max := s.Max(); 199 != max {
+		t.Errorf("s.Max(): 199 != %v\n", max)
+	}
+	ps := s.Percentiles([]float64{0.0, 0.5, 1.0})
+	if 100 != ps[0] || 149.5 != ps[1] || 199 != ps[2] {
+		t.Errorf("s.Percentiles(): [100 149.5 199] != %v\n", ps)
+	}
+	values := s.Values()
+	for i, v := range values {
+		if int64(100+i) != v {
+			t.Fatalf("s.Values()[%d]: %d != %v\n", i, 100+i, v)
+		}
+	}
+}
+
+func TestSlidingWindowSampleSnapshot(t *testing.T) {
+	s := NewSlidingWindowSample(3)
+	for i := 1; i <= 5; i++ {
+		s.Update(int64(i))
+	}
+	snapshot := s.Snapshot()
+	s.Update(6)
+	if v := snapshot.Values(); 3 != v[0] || 5 != v[2] {
+		t.Errorf("snapshot.Values(): [3 4 5] != %v\n", v)
+	}
+	if mean := snapshot.Mean(); 4 != mean {
+		t.Errorf("snapshot.Mean(): 4 != %v\n", mean)
+	}
+	if p := s.Percentile(0.5); 5 != p {
+		t.Errorf("s.Percentile(0.5): 5 != %v\n", p)
+	}
+}
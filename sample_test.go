@@ -1,8 +1,14 @@
 package metrics
 
 import (
+	"bytes"
+	"log"
+	"math"
 	"math/rand"
+	"os"
+	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 )
@@ -78,6 +84,35 @@ func BenchmarkUniformSample1028(b *testing.B) {
 	benchmarkSample(b, NewUniformSample(1028))
 }
 
+// BenchmarkSamplePercentile1028 and BenchmarkSamplePercentiles1028 compare
+// the five sorts of scraping five percentiles one at a time with the single
+// sort of scraping them at once.
+func BenchmarkSamplePercentile1028(b *testing.B) {
+	s := newPercentileBenchmarkSample()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, p := range []float64{0.5, 0.75, 0.95, 0.99, 0.999} {
+			s.Percentile(p)
+		}
+	}
+}
+
+func BenchmarkSamplePercentiles1028(b *testing.B) {
+	s := newPercentileBenchmarkSample()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
+	}
+}
+
+func newPercentileBenchmarkSample() Sample {
+	s := NewExpDecaySample(1028, 0.015)
+	for i := 0; i < 1028; i++ {
+		s.Update(rand.Int63n(1000000))
+	}
+	return s
+}
+
 func TestExpDecaySample10(t *testing.T) {
 	rand.Seed(1)
 	s := NewExpDecaySample(100, 0.99)
@@ -180,6 +215,33 @@ func TestExpDecaySampleRescale(t *testing.T) {
 	}
 }
 
+type manualClock struct {
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time { return c.now }
+
+func (c *manualClock) Add(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestExpDecaySampleWithClock(t *testing.T) {
+	rand.Seed(1)
+	clock := &manualClock{time.Unix(0, 0)}
+	s := NewExpDecaySampleWithClock(10, 0.015, clock)
+	for i := 0; i < 10; i++ {
+		s.Update(1)
+	}
+	clock.Add(2 * time.Hour)
+	for i := 0; i < 10; i++ {
+		s.Update(1000)
+	}
+	if min := s.Min(); 1000 != min {
+		t.Errorf("s.Min(): 1000 != %v\n", min)
+	}
+	if t0 := s.(*ExpDecaySample).t0; !t0.Equal(clock.Now()) {
+		t.Errorf("s.t0: %v != %v\n", clock.Now(), t0)
+	}
+}
+
 func TestExpDecaySampleSnapshot(t *testing.T) {
 	now := time.Now()
 	rand.Seed(1)
@@ -252,6 +314,117 @@ func TestUniformSampleSnapshot(t *testing.T) {
 	testUniformSampleStatistics(t, snapshot)
 }
 
+func TestUniformSampleMerge(t *testing.T) {
+	rand.Seed(1)
+	a := NewUniformSample(1000).(*UniformSample)
+	for i := 0; i < 10000; i++ {
+		a.Update(int64(i % 1000))
+	}
+	b := NewUniformSample(1000)
+	for i := 0; i < 30000; i++ {
+		b.Update(int64(1000 + i%1000))
+	}
+	if err := a.Merge(b); nil != err {
+		t.Fatal(err)
+	}
+	if count := a.Count(); 40000 != count {
+		t.Errorf("a.Count(): 40000 != %v\n", count)
+	}
+	if size := a.Size(); 1000 != size {
+		t.Errorf("a.Size(): 1000 != %v\n", size)
+	}
+
+	// A quarter of the union is in [0, 1000) and the rest in [1000, 2000).
+	n := 0
+	for _, v := range a.Values() {
+		if v < 1000 {
+			n++
+		}
+	}
+	if n < 200 || n > 300 {
+		t.Errorf("values < 1000: 250 != %v\n", n)
+	}
+	ps := a.Percentiles([]float64{0.5, 0.9})
+	for i, expected := range []float64{1333, 1867} {
+		if math.Abs(ps[i]-expected) > 60 {
+			t.Errorf("ps[%d]: %v != %v\n", i, expected, ps[i])
+		}
+	}
+}
+
+func TestUniformSampleMergeUnfilled(t *testing.T) {
+	a := NewUniformSample(100).(*UniformSample)
+	b := NewUniformSample(100)
+	for i := 0; i < 10; i++ {
+		a.Update(1)
+		b.Update(2)
+	}
+	if err := a.Merge(b); nil != err {
+		t.Fatal(err)
+	}
+	if size := a.Size(); 20 != size {
+		t.Errorf("a.Size(): 20 != %v\n", size)
+	}
+	if sum := a.Sum(); 30 != sum {
+		t.Errorf("a.Sum(): 30 != %v\n", sum)
+	}
+	if err := a.Merge(a); nil != err || 40 != a.Count() {
+		t.Fatal(err, a.Count())
+	}
+}
+
+func TestUniformSampleMergeIncompatible(t *testing.T) {
+	a := NewUniformSample(100).(*UniformSample)
+	a.Update(1)
+	if err := a.Merge(NewExpDecaySample(100, 0.99)); ErrIncompatibleSamples != err {
+		t.Fatal(err)
+	}
+	if count := a.Count(); 1 != count {
+		t.Errorf("a.Count(): 1 != %v\n", count)
+	}
+}
+
+func TestUniformSampleResize(t *testing.T) {
+	s := NewUniformSample(10).(*UniformSample)
+	for i := 0; i < 100; i++ {
+		s.Update(int64(i))
+	}
+	s.Resize(50)
+	if size := s.Size(); 10 != size {
+		t.Errorf("s.Size(): 10 != %v\n", size)
+	}
+	for i := 100; i < 1000; i++ {
+		s.Update(int64(i))
+	}
+	if size := s.Size(); 50 != size {
+		t.Errorf("s.Size(): 50 != %v\n", size)
+	}
+	if count := s.Count(); 1000 != count {
+		t.Errorf("s.Count(): 1000 != %v\n", count)
+	}
+
+	before := make(map[int64]bool)
+	for _, v := range s.Values() {
+		before[v] = true
+	}
+	s.Resize(5)
+	if size := s.Size(); 5 != size {
+		t.Errorf("s.Size(): 5 != %v\n", size)
+	}
+	for _, v := range s.Values() {
+		if !before[v] {
+			t.Errorf("%v was not in the sample before shrinking\n", v)
+		}
+	}
+	s.Update(1000)
+	if size := s.Size(); 5 != size {
+		t.Errorf("s.Size(): 5 != %v\n", size)
+	}
+	if count := s.Count(); 1001 != count {
+		t.Errorf("s.Count(): 1001 != %v\n", count)
+	}
+}
+
 func TestUniformSampleStatistics(t *testing.T) {
 	rand.Seed(1)
 	s := NewUniformSample(100)
@@ -361,3 +534,171 @@ func TestUniformSampleConcurrentUpdateCount(t *testing.T) {
 	}
 	quit <- struct{}{}
 }
+
+func TestSampleValuesCopy(t *testing.T) {
+	for name, s := range map[string]Sample{
+		"CompositeSample":     NewCompositeSample(NewUniformSample(100)),
+		"ExpDecaySample":      NewExpDecaySample(100, 0.99),
+		"ExponentialSample":   NewExponentialSample(2),
+		"HdrSample":           NewHdrSample(1000, 3),
+		"SlidingWindowSample": NewSlidingWindowSample(100),
+		"UniformSample":       NewUniformSample(100),
+	} {
+		s.Update(512)
+		for _, sample := range []Sample{s, s.Snapshot()} {
+			values := sample.Values()
+			if 1 != len(values) {
+				t.Fatalf("%s.Values(): 1 != %v\n", name, len(values))
+			}
+			values[0] = -1
+			if v := sample.Values()[0]; -1 == v {
+				t.Errorf("%s.Values() is not a copy\n", name)
+			}
+		}
+	}
+}
+
+func TestSamplePercentilesWithMethod(t *testing.T) {
+	ps := []float64{0.05, 0.1, 0.3, 0.4, 0.5, 0.9, 1.0}
+	for _, c := range []struct {
+		method   PercentileMethod
+		expected []float64
+	}{
+		{PercentileWeibull, []float64{15, 15, 19, 26, 35, 50, 50}},
+		{PercentileNearestRank, []float64{15, 15, 20, 20, 35, 50, 50}},
+		{PercentileLinear, []float64{16, 17, 23, 29, 35, 46, 50}},
+	} {
+		scores := SamplePercentilesWithMethod([]int64{40, 15, 50, 35, 20}, ps, c.method)
+		for i, p := range ps {
+			if math.Abs(c.expected[i]-scores[i]) > 1e-9 {
+				t.Errorf("method %d, percentile %v: %v != %v\n", c.method, p, c.expected[i], scores[i])
+			}
+		}
+	}
+}
+
+func TestRunningStatsMerge(t *testing.T) {
+	var all, a, b runningStats
+	for i, v := range []int64{3, -1, 8, 0, -5, 12, 7} {
+		all.update(v)
+		if i < 3 {
+			a.update(v)
+		} else {
+			b.update(v)
+		}
+	}
+	a.merge(b)
+	a.merge(runningStats{})
+	if all.count != a.count || all.min != a.min || all.max != a.max || all.sum != a.sum {
+		t.Errorf("merge: %+v != %+v\n", all, a)
+	}
+	if math.Abs(all.mean-a.mean) > 1e-9 || math.Abs(all.variance()-a.variance()) > 1e-9 {
+		t.Errorf("merge: %+v != %+v\n", all, a)
+	}
+}
+
+func TestSampleSignedValues(t *testing.T) {
+	for _, c := range []struct {
+		values   []int64
+		ps       []float64
+		want     []float64
+		mean     float64
+		variance float64
+	}{
+		{[]int64{3, -1, 8, 0, -5}, []float64{0.01, 0.25, 0.5, 0.75, 0.99}, []float64{-5, -3, 0, 5.5, 8}, 1, 18.8},
+		{[]int64{-20, -40, -10, -30}, []float64{0.5}, []float64{-25}, -25, 125},
+		{[]int64{0, 0, 0}, []float64{0.01, 0.5, 0.99}, []float64{0, 0, 0}, 0, 0},
+		{[]int64{-7}, []float64{0.01, 0.5, 0.99}, []float64{-7, -7, -7}, -7, 0},
+	} {
+		values := make([]int64, len(c.values))
+		copy(values, c.values)
+		if ps := SamplePercentiles(values, c.ps); !reflect.DeepEqual(c.want, ps) {
+			t.Errorf("SamplePercentiles(%v): %v != %v\n", c.values, c.want, ps)
+		}
+		if mean := SampleMean(c.values); c.mean != mean {
+			t.Errorf("SampleMean(%v): %v != %v\n", c.values, c.mean, mean)
+		}
+		if variance := SampleVariance(c.values); c.variance != variance {
+			t.Errorf("SampleVariance(%v): %v != %v\n", c.values, c.variance, variance)
+		}
+	}
+	values := []int64{3, -1, 8, 0, -5}
+	if ps := SamplePercentilesWithMethod(values, []float64{0.25, 0.5}, PercentileLinear); -1 != ps[0] || 0 != ps[1] {
+		t.Errorf("PercentileLinear: [-1 0] != %v\n", ps)
+	}
+	if ps := SamplePercentilesWithMethod(values, []float64{0.25, 0.5}, PercentileNearestRank); -1 != ps[0] || 0 != ps[1] {
+		t.Errorf("PercentileNearestRank: [-1 0] != %v\n", ps)
+	}
+}
+
+func TestSampleWithMethod(t *testing.T) {
+	for _, s := range []Sample{
+		NewUniformSampleWithMethod(100, PercentileNearestRank),
+		NewExpDecaySampleWithMethod(100, 0.99, PercentileNearestRank),
+	} {
+		for _, v := range []int64{40, 15, 50, 35, 20} {
+			s.Update(v)
+		}
+		if p := s.Percentile(0.4); 20.0 != p {
+			t.Errorf("s.Percentile(0.4): 20.0 != %v\n", p)
+		}
+		if p := s.Snapshot().Percentile(0.4); 20.0 != p {
+			t.Errorf("s.Snapshot().Percentile(0.4): 20.0 != %v\n", p)
+		}
+	}
+}
+
+func TestUniformSampleChecked(t *testing.T) {
+	for _, size := range []int{0, -1, MaxUniformSampleSize + 1} {
+		if s, err := NewUniformSampleChecked(size); nil == err {
+			t.Errorf("NewUniformSampleChecked(%d): %v\n", size, s)
+		}
+	}
+	if _, err := NewUniformSampleChecked(-1); ErrInvalidReservoirSize != err {
+		t.Fatal(err)
+	}
+	s, err := NewUniformSampleChecked(10)
+	if nil != err {
+		t.Fatal(err)
+	}
+	s.Update(1)
+	if size := s.Size(); 1 != size {
+		t.Errorf("s.Size(): 1 != %v\n", size)
+	}
+}
+
+func TestUniformSampleInvalidSize(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	for _, size := range []int{0, -1} {
+		buf.Reset()
+		s := NewUniformSample(size)
+		if !strings.Contains(buf.String(), "WARNING") {
+			t.Errorf("NewUniformSample(%d) logged %q\n", size, buf.String())
+		}
+		for i := int64(1); i <= 10; i++ {
+			s.Update(i)
+		}
+		if size := s.Size(); 1 != size {
+			t.Errorf("s.Size(): 1 != %v\n", size)
+		}
+		if count := s.Count(); 10 != count {
+			t.Errorf("s.Count(): 10 != %v\n", count)
+		}
+	}
+}
+
+func TestSampleSnapshotPercentiles(t *testing.T) {
+	s := NewSampleSnapshot(5, []int64{5, 1, 4, 2, 3})
+	if p := s.Percentile(0.5); 3 != p {
+		t.Errorf("s.Percentile(0.5): 3 != %v\n", p)
+	}
+	ps := s.Percentiles([]float64{0.0, 1.0})
+	if 1 != ps[0] || 5 != ps[1] {
+		t.Fatal(ps)
+	}
+	if v := s.Values(); 5 != v[0] || 1 != v[1] {
+		t.Errorf("s.Values(): [5 1 4 2 3] != %v\n", v)
+	}
+}
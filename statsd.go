@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+)
+
+// StatsDConfig provides a container with configuration parameters for
+// the StatsD exporter
+type StatsDConfig struct {
+	Addr          *net.UDPAddr  // Network address to send to
+	Registry      Registry      // Registry to be exported
+	FlushInterval time.Duration // Flush interval
+	Prefix        string        // Prefix to be prepended to metric names
+	Percentiles   []float64     // Percentiles to export from histograms
+	MTU           int           // Maximum packet size, DefaultStatsDMTU if zero
+
+	// TimerSampleRate, if between zero and one, is the fraction of timer
+	// values sent, each with a |@rate suffix so that the server scales its
+	// counts back up.  Every value is sent otherwise.  A Timer's rate set
+	// by SetSampleRate, if between zero and one, takes precedence.
+	TimerSampleRate float64
+
+	// Mapper, if not nil, transforms each metric name before it is prefixed
+	// and suffixed.
+	Mapper func(name string) string
+
+	counts map[string]int64 // counts sent by the last successful flush
+}
+
+// StatsD is a blocking exporter function which reports metrics in r
+// to a StatsD server located at addr, flushing them every d duration
+// and prepending metric names with prefix.
+func StatsD(r Registry, d time.Duration, prefix string, addr *net.UDPAddr) {
+	StatsDWithConfig(StatsDConfig{
+		Addr:          addr,
+		Registry:      r,
+		FlushInterval: d,
+		Prefix:        prefix,
+		Percentiles:   []float64{0.5, 0.75, 0.95, 0.99, 0.999},
+	})
+}
+
+// StatsDWithConfig is a blocking exporter function just like StatsD,
+// but it takes a StatsDConfig instead.
+func StatsDWithConfig(c StatsDConfig) {
+	for _ = range time.Tick(c.FlushInterval) {
+		if err := statsD(&c); nil != err {
+			log.Println(err)
+		}
+	}
+}
+
+// StatsDOnce performs a single submission to StatsD, returning a non-nil
+// error on failed connections.  Since every call starts afresh, Counters
+// and Meters are sent as their entire count.
+func StatsDOnce(c StatsDConfig) error {
+	return statsD(&c)
+}
+
+// statsD sends Counters and Meters as StatsD counters of the increase since
+// the last successful flush, Gauges and Histograms as gauges, and Timers as
+// ms values.  Timers are sent the last of the values in their sample, no
+// more than the number of events recorded since the last successful flush,
+// sampled at the rate set by SetSampleRate or c.TimerSampleRate.  These are
+// the most recent values only for a SlidingWindowSample: for a UniformSample
+// or ExpDecaySample they are an arbitrary subset of the reservoir, which may
+// include values sent by earlier flushes and omit values recorded since.
+// A new connection is dialed for every flush, so a failed send is retried on
+// a fresh socket at the next interval.
+func statsD(c *StatsDConfig) error {
+	conn, err := net.DialUDP("udp", nil, c.Addr)
+	if nil != err {
+		return err
+	}
+	defer conn.Close()
+	w := newStatsDPacketWriter(conn, c.MTU)
+	counts := make(map[string]int64)
+	var sampleRateOf func(string) float64
+	if base, _ := findPrefix(c.Registry, ""); nil != base {
+		if s, ok := base.(interface {
+			SampleRate(string) float64
+		}); ok {
+			sampleRateOf = s.SampleRate
+		}
+	}
+	c.Registry.Each(func(name string, i interface{}) {
+		key := name
+		name = mapName(c.Mapper, name)
+		if "" != c.Prefix {
+			name = c.Prefix + "." + name
+		}
+		gauge := func(suffix string, v float64) {
+			w.WriteLine(fmt.Sprintf("%s%s:%s|g", name, suffix, statsDFloat(v)))
+		}
+		// increase records count and returns its increase since the last
+		// successful flush, or all of it if the metric has since been cleared.
+		increase := func(count int64) int64 {
+			counts[key] = count
+			if last := c.counts[key]; last <= count {
+				return count - last
+			}
+			return count
+		}
+		switch metric := i.(type) {
+		case Counter:
+			if n := increase(metric.Count()); 0 != n {
+				w.WriteLine(fmt.Sprintf("%s:%d|c", name, n))
+			}
+		case Gauge:
+			gauge("", float64(metric.Value()))
+		case GaugeFloat64:
+			gauge("", metric.Value())
+		case Histogram:
+			h := metric.Snapshot()
+			ps := h.Percentiles(c.Percentiles)
+			gauge(".count", float64(h.Count()))
+			gauge(".min", float64(h.Min()))
+			gauge(".max", float64(h.Max()))
+			gauge(".mean", h.Mean())
+			gauge(".std-dev", h.StdDev())
+			for psIdx, psKey := range c.Percentiles {
+				gauge("."+percentileKey(psKey), ps[psIdx])
+			}
+		case Meter:
+			if n := increase(metric.Count()); 0 != n {
+				w.WriteLine(fmt.Sprintf("%s:%d|c", name, n))
+			}
+		case Timer:
+			t := metric.Snapshot()
+			var values []int64
+			if s, ok := t.(interface {
+				Values() []int64
+			}); ok {
+				values = s.Values()
+			}
+			if n := increase(t.Count()); n < int64(len(values)) {
+				values = values[int64(len(values))-n:]
+			}
+			rate := c.TimerSampleRate
+			if nil != sampleRateOf {
+				if r := sampleRateOf(key); 0 < r && r < 1 {
+					rate = r
+				}
+			}
+			for _, v := range values {
+				if line, ok := statsDTiming(name, v, rate); ok {
+					w.WriteLine(line)
+				}
+			}
+		}
+	})
+	if err := w.Flush(); nil != err {
+		return err
+	}
+	c.counts = counts
+	return nil
+}
+
+// statsDTiming formats a duration in nanoseconds as a StatsD ms value.  It
+// returns false if the value is skipped by sampling at the given rate.
+func statsDTiming(name string, v int64, rate float64) (string, bool) {
+	ms := statsDFloat(float64(v) / float64(time.Millisecond))
+	if rate <= 0 || rate >= 1 {
+		return fmt.Sprintf("%s:%s|ms", name, ms), true
+	}
+	if rand.Float64() >= rate {
+		return "", false
+	}
+	return fmt.Sprintf("%s:%s|ms|@%s", name, ms, strconv.FormatFloat(rate, 'f', -1, 64)), true
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readStatsD(t *testing.T, conn *net.UDPConn) []string {
+	var lines []string
+	buf := make([]byte, DefaultStatsDMTU)
+	for {
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, _, err := conn.ReadFromUDP(buf)
+		if nil != err {
+			break
+		}
+		lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func TestStatsDOnce(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	r := NewRegistry()
+	counter := NewRegisteredCounter("foo", r)
+	counter.Inc(47)
+	NewRegisteredGauge("bar", r).Update(3)
+	timer := NewCustomTimer(NewHistogram(NewUniformSample(100)), NewMeter())
+	r.Register("baz", timer)
+	timer.Update(2 * time.Millisecond)
+	timer.Update(1500 * time.Microsecond)
+	c := StatsDConfig{
+		Addr:     conn.LocalAddr().(*net.UDPAddr),
+		Registry: r,
+		Prefix:   "app",
+	}
+	if err := statsD(&c); nil != err {
+		t.Fatal(err)
+	}
+	want := []string{"app.bar:3|g", "app.baz:1.5|ms", "app.baz:2|ms", "app.foo:47|c"}
+	if got := readStatsD(t, conn); strings.Join(want, " ") != strings.Join(got, " ") {
+		t.Fatal(got)
+	}
+
+	counter.Inc(3)
+	timer.Update(time.Millisecond)
+	if err := statsD(&c); nil != err {
+		t.Fatal(err)
+	}
+	want = []string{"app.bar:3|g", "app.baz:1|ms", "app.foo:3|c"}
+	if got := readStatsD(t, conn); strings.Join(want, " ") != strings.Join(got, " ") {
+		t.Fatal(got)
+	}
+}
+
+func TestStatsDSampleRate(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	r := NewRegistry().(*StandardRegistry)
+	sampled := NewCustomTimer(NewHistogram(NewUniformSample(1000)), NewMeter())
+	r.Register("db.query", sampled)
+	r.SetSampleRate("db.query", 0.1)
+	all := NewCustomTimer(NewHistogram(NewUniformSample(1000)), NewMeter())
+	r.Register("http.request", all)
+	for i := 0; i < 1000; i++ {
+		sampled.Update(time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		all.Update(time.Millisecond)
+	}
+	c := StatsDConfig{Addr: conn.LocalAddr().(*net.UDPAddr), Registry: r}
+	if err := statsD(&c); nil != err {
+		t.Fatal(err)
+	}
+	var nSampled, nAll int
+	for _, line := range readStatsD(t, conn) {
+		switch line {
+		case "db.query:1|ms|@0.1":
+			nSampled++
+		case "http.request:1|ms":
+			nAll++
+		default:
+			t.Fatal(line)
+		}
+	}
+	if nSampled < 50 || nSampled > 200 {
+		t.Errorf("db.query: 100 != %v\n", nSampled)
+	}
+	if 10 != nAll {
+		t.Errorf("http.request: 10 != %v\n", nAll)
+	}
+}
+
+func TestStatsDTiming(t *testing.T) {
+	if line, ok := statsDTiming("foo", int64(time.Millisecond), 0); !ok || "foo:1|ms" != line {
+		t.Fatal(line, ok)
+	}
+	sent := 0
+	for i := 0; i < 1000; i++ {
+		if line, ok := statsDTiming("foo", int64(time.Millisecond), 0.1); ok {
+			if "foo:1|ms|@0.1" != line {
+				t.Fatal(line)
+			}
+			sent++
+		}
+	}
+	if sent < 50 || sent > 200 {
+		t.Errorf("sent: 100 != %v\n", sent)
+	}
+}
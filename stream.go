@@ -0,0 +1,53 @@
+//go:build go1.7
+// +build go1.7
+
+package metrics
+
+import (
+	"context"
+	"time"
+)
+
+// MetricEvent is the state of one metric at one interval, as sent by
+// StreamEach.
+type MetricEvent struct {
+	Name string    // Name the metric is registered under
+	Type string    // Kind of metric, i.e. "counter", "meter" or "timer"
+	Time time.Time // Time of the interval the values were read at
+
+	// Values are the metric's values, keyed as in Registry.GetAll.
+	Values map[string]interface{}
+}
+
+// StreamEach returns a channel on which an event is sent for each metric in r
+// every interval duration, so that metrics may be fed into a pipeline of the
+// caller's choosing rather than a fixed backend.  Metrics of unsupported
+// kinds are skipped.  Sends block until received, delaying the next interval
+// rather than dropping events.  The channel is closed once ctx is cancelled.
+func StreamEach(ctx context.Context, r Registry, interval time.Duration) <-chan MetricEvent {
+	ch := make(chan MetricEvent)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				for _, nm := range sortedMetrics(r) {
+					kind, values := metricValues(nm.m)
+					if "" == kind {
+						continue
+					}
+					select {
+					case <-ctx.Done():
+						return
+					case ch <- MetricEvent{Name: nm.name, Type: kind, Time: now, Values: values}:
+					}
+				}
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,51 @@
+//go:build go1.7
+// +build go1.7
+
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStreamEach(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("bar", r).Inc(47)
+	NewRegisteredGauge("foo", r).Update(48)
+	r.Register("baz", "unsupported")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := StreamEach(ctx, r, time.Millisecond)
+	e := <-ch
+	if "bar" != e.Name || "counter" != e.Type || int64(47) != e.Values["count"] {
+		t.Fatal(e)
+	}
+	e = <-ch
+	if "foo" != e.Name || "gauge" != e.Type || int64(48) != e.Values["value"] {
+		t.Fatal(e)
+	}
+	if e.Time.IsZero() {
+		t.Fatal(e.Time)
+	}
+}
+
+func TestStreamEachCancel(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("foo", r)
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := StreamEach(ctx, r, time.Millisecond)
+	<-ch
+	cancel()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("channel not closed after cancel")
+		}
+	}
+}
@@ -43,13 +43,15 @@ func Syslog(r Registry, d time.Duration, w *syslog.Writer) {
 			case Meter:
 				m := metric.Snapshot()
 				w.Info(fmt.Sprintf(
-					"meter %s: count: %d 1-min: %.2f 5-min: %.2f 15-min: %.2f mean: %.2f",
+					"meter %s: count: %d 1-min: %.2f 5-min: %.2f 15-min: %.2f mean: %.2f max-rate: %.2f min-rate: %.2f",
 					name,
 					m.Count(),
 					m.Rate1(),
 					m.Rate5(),
 					m.Rate15(),
 					m.RateMean(),
+					m.RateMax(),
+					m.RateMin(),
 				))
 			case Timer:
 				t := metric.Snapshot()
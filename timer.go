@@ -6,10 +6,17 @@ import (
 )
 
 // Timers capture the duration and rate of events.
+//
+// UpdateSince measures with the monotonic clock reading time.Now records in
+// ts, if ts has one, and otherwise with the wall clock, which may jump.
+// UpdateSinceMonotonic only measures with the monotonic clock, so durations
+// are never skewed by changes to the wall clock.  Update records a duration
+// measured by the caller.
 type Timer interface {
 	Count() int64
 	Max() int64
 	Mean() float64
+	MeanIn(time.Duration) float64
 	Min() int64
 	Percentile(float64) float64
 	Percentiles([]float64) []float64
@@ -17,16 +24,30 @@ type Timer interface {
 	Rate5() float64
 	Rate15() float64
 	RateMean() float64
+	Reset()
 	Snapshot() Timer
+	Start() *TimerStopwatch
 	StdDev() float64
 	Stop()
 	Sum() int64
 	Time(func())
+	TimeDefer() func()
 	Update(time.Duration)
 	UpdateSince(time.Time)
+	UpdateSinceMonotonic(time.Time)
 	Variance() float64
 }
 
+// GetTimer returns the Timer registered under the given name, or nil if no
+// metric is registered under the name or the registered metric is not a Timer.
+func GetTimer(name string, r Registry) Timer {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	m, _ := r.Get(name).(Timer)
+	return m
+}
+
 // GetOrRegisterTimer returns an existing Timer or constructs and registers a
 // new StandardTimer.
 // Be sure to unregister the meter from the registry once it is of no use to
@@ -90,6 +111,9 @@ func (NilTimer) Max() int64 { return 0 }
 // Mean is a no-op.
 func (NilTimer) Mean() float64 { return 0.0 }
 
+// MeanIn is a no-op.
+func (NilTimer) MeanIn(time.Duration) float64 { return 0.0 }
+
 // Min is a no-op.
 func (NilTimer) Min() int64 { return 0 }
 
@@ -113,9 +137,15 @@ func (NilTimer) Rate15() float64 { return 0.0 }
 // RateMean is a no-op.
 func (NilTimer) RateMean() float64 { return 0.0 }
 
+// Reset is a no-op.
+func (NilTimer) Reset() {}
+
 // Snapshot is a no-op.
 func (NilTimer) Snapshot() Timer { return NilTimer{} }
 
+// Start is a no-op.  The returned nil stopwatch's Stop is also a no-op.
+func (NilTimer) Start() *TimerStopwatch { return nil }
+
 // StdDev is a no-op.
 func (NilTimer) StdDev() float64 { return 0.0 }
 
@@ -128,12 +158,18 @@ func (NilTimer) Sum() int64 { return 0 }
 // Time is a no-op.
 func (NilTimer) Time(func()) {}
 
+// TimeDefer is a no-op.
+func (NilTimer) TimeDefer() func() { return func() {} }
+
 // Update is a no-op.
 func (NilTimer) Update(time.Duration) {}
 
 // UpdateSince is a no-op.
 func (NilTimer) UpdateSince(time.Time) {}
 
+// UpdateSinceMonotonic is a no-op.
+func (NilTimer) UpdateSinceMonotonic(time.Time) {}
+
 // Variance is a no-op.
 func (NilTimer) Variance() float64 { return 0.0 }
 
@@ -160,6 +196,12 @@ func (t *StandardTimer) Mean() float64 {
 	return t.histogram.Mean()
 }
 
+// MeanIn returns the mean of the values in the sample in the given unit,
+// i.e. in milliseconds for time.Millisecond, or in nanoseconds for zero.
+func (t *StandardTimer) MeanIn(unit time.Duration) float64 {
+	return inDurationUnit(t.Mean(), unit)
+}
+
 // Min returns the minimum value in the sample.
 func (t *StandardTimer) Min() int64 {
 	return t.histogram.Min()
@@ -196,6 +238,20 @@ func (t *StandardTimer) RateMean() float64 {
 	return t.meter.RateMean()
 }
 
+// Reset clears the timer's histogram and, if it is a StandardMeter, zeroes
+// the meter's count and moving averages.  Reset takes the same lock as
+// Update and UpdateSince so it is safe to call concurrently with them: each
+// concurrent update is recorded either entirely before or entirely after the
+// reset.
+func (t *StandardTimer) Reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.histogram.Clear()
+	if m, ok := t.meter.(*StandardMeter); ok {
+		m.reset()
+	}
+}
+
 // Snapshot returns a read-only copy of the timer.
 func (t *StandardTimer) Snapshot() Timer {
 	t.mutex.Lock()
@@ -206,6 +262,17 @@ func (t *StandardTimer) Snapshot() Timer {
 	}
 }
 
+// Start starts timing immediately and returns a stopwatch whose Stop method
+// records the elapsed duration:
+//
+//	sw := t.Start()
+//	defer sw.Stop()
+//
+// Unlike Time and TimeDefer, it allocates no closure.  See TimerStopwatch.
+func (t *StandardTimer) Start() *TimerStopwatch {
+	return startStopwatch(t)
+}
+
 // StdDev returns the standard deviation of the values in the sample.
 func (t *StandardTimer) StdDev() float64 {
 	return t.histogram.StdDev()
@@ -228,6 +295,18 @@ func (t *StandardTimer) Time(f func()) {
 	t.Update(time.Since(ts))
 }
 
+// TimeDefer starts timing immediately and returns a function which records
+// the elapsed duration when called.  Note the double call:
+//
+//	defer t.TimeDefer()()
+//
+// Because deferred calls run while a panic unwinds, the duration is recorded
+// even if the surrounding function panics, unlike Time.
+func (t *StandardTimer) TimeDefer() func() {
+	ts := time.Now()
+	return func() { t.UpdateSince(ts) }
+}
+
 // Record the duration of an event.
 func (t *StandardTimer) Update(d time.Duration) {
 	t.mutex.Lock()
@@ -244,6 +323,16 @@ func (t *StandardTimer) UpdateSince(ts time.Time) {
 	t.meter.Mark(1)
 }
 
+// Record the duration of an event that started at a time and ends now, if
+// the time carries a monotonic clock reading as returned by time.Now.  Times
+// without one, such as those parsed, constructed with time.Unix or stripped
+// by Round(0), are ignored rather than measured with the wall clock.
+func (t *StandardTimer) UpdateSinceMonotonic(ts time.Time) {
+	if hasMonotonic(ts) {
+		t.UpdateSince(ts)
+	}
+}
+
 // Variance returns the variance of the values in the sample.
 func (t *StandardTimer) Variance() float64 {
 	return t.histogram.Variance()
@@ -265,6 +354,12 @@ func (t *TimerSnapshot) Max() int64 { return t.histogram.Max() }
 // Mean returns the mean value at the time the snapshot was taken.
 func (t *TimerSnapshot) Mean() float64 { return t.histogram.Mean() }
 
+// MeanIn returns the mean value at the time the snapshot was taken in the
+// given unit.  See StandardTimer.MeanIn.
+func (t *TimerSnapshot) MeanIn(unit time.Duration) float64 {
+	return inDurationUnit(t.Mean(), unit)
+}
+
 // Min returns the minimum value at the time the snapshot was taken.
 func (t *TimerSnapshot) Min() int64 { return t.histogram.Min() }
 
@@ -296,6 +391,11 @@ func (t *TimerSnapshot) Rate15() float64 { return t.meter.Rate15() }
 // snapshot was taken.
 func (t *TimerSnapshot) RateMean() float64 { return t.meter.RateMean() }
 
+// Reset panics.
+func (*TimerSnapshot) Reset() {
+	panic("Reset called on a TimerSnapshot")
+}
+
 // Snapshot returns the snapshot.
 func (t *TimerSnapshot) Snapshot() Timer { return t }
 
@@ -314,6 +414,16 @@ func (*TimerSnapshot) Time(func()) {
 	panic("Time called on a TimerSnapshot")
 }
 
+// TimeDefer panics.
+func (*TimerSnapshot) TimeDefer() func() {
+	panic("TimeDefer called on a TimerSnapshot")
+}
+
+// Start panics.
+func (*TimerSnapshot) Start() *TimerStopwatch {
+	panic("Start called on a TimerSnapshot")
+}
+
 // Update panics.
 func (*TimerSnapshot) Update(time.Duration) {
 	panic("Update called on a TimerSnapshot")
@@ -324,6 +434,56 @@ func (*TimerSnapshot) UpdateSince(time.Time) {
 	panic("UpdateSince called on a TimerSnapshot")
 }
 
+// UpdateSinceMonotonic panics.
+func (*TimerSnapshot) UpdateSinceMonotonic(time.Time) {
+	panic("UpdateSinceMonotonic called on a TimerSnapshot")
+}
+
+// Values returns a copy of the sampled values at the time the snapshot was
+// taken.
+func (t *TimerSnapshot) Values() []int64 { return t.histogram.Sample().Values() }
+
 // Variance returns the variance of the values at the time the snapshot was
 // taken.
 func (t *TimerSnapshot) Variance() float64 { return t.histogram.Variance() }
+
+// durationUnit returns the given unit, or time.Nanosecond for zero, so that
+// exporters whose DurationUnit is unset report durations in nanoseconds
+// rather than dividing by zero.
+func durationUnit(unit time.Duration) time.Duration {
+	if 0 >= unit {
+		return time.Nanosecond
+	}
+	return unit
+}
+
+// inDurationUnit converts a duration in nanoseconds to the given unit.
+func inDurationUnit(ns float64, unit time.Duration) float64 {
+	return ns / float64(durationUnit(unit))
+}
+
+// durationUnitName returns the conventional abbreviation of the given unit,
+// such as "ms", for the units time defines, and its String otherwise.
+func durationUnitName(unit time.Duration) string {
+	switch unit = durationUnit(unit); unit {
+	case time.Nanosecond:
+		return "ns"
+	case time.Microsecond:
+		return "us"
+	case time.Millisecond:
+		return "ms"
+	case time.Second:
+		return "s"
+	case time.Minute:
+		return "m"
+	case time.Hour:
+		return "h"
+	}
+	return unit.String()
+}
+
+// hasMonotonic reports whether ts carries a monotonic clock reading, which
+// Round(0) strips.
+func hasMonotonic(ts time.Time) bool {
+	return ts != ts.Round(0)
+}
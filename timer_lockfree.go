@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// NewLockFreeTimer constructs a new LockFreeTimer which keeps the most
+// recent window durations, and launches a goroutine.  A window less than
+// one is replaced by one.
+// Be sure to call Stop() once the timer is of no use to allow for garbage collection.
+func NewLockFreeTimer(window int) Timer {
+	if UseNilMetrics {
+		return NilTimer{}
+	}
+	if window < 1 {
+		window = 1
+	}
+	return &LockFreeTimer{
+		meter:  NewMeter(),
+		values: make([]int64, window),
+	}
+}
+
+// NewRegisteredLockFreeTimer constructs and registers a new LockFreeTimer.
+// Be sure to unregister the timer from the registry once it is of no use to
+// allow for garbage collection.
+func NewRegisteredLockFreeTimer(name string, r Registry, window int) Timer {
+	c := NewLockFreeTimer(window)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// LockFreeTimer is a Timer for latency-sensitive paths which records each
+// duration with atomic operations alone, into a ring buffer holding the most
+// recent window durations, rather than under a lock as StandardTimer does.
+// Its statistics describe only that window and are computed from a copy of
+// it on every read, so reads are costlier than StandardTimer's.  A read
+// concurrent with updates may see the duration a slot held before its
+// newest update.
+type LockFreeTimer struct {
+	count  int64 // durations recorded, and so the index of the next slot
+	meter  Meter
+	values []int64
+}
+
+// Count returns the number of events recorded.
+func (t *LockFreeTimer) Count() int64 {
+	return atomic.LoadInt64(&t.count)
+}
+
+// Max returns the maximum value in the window.
+func (t *LockFreeTimer) Max() int64 {
+	return t.Snapshot().Max()
+}
+
+// Mean returns the mean of the values in the window.
+func (t *LockFreeTimer) Mean() float64 {
+	return t.Snapshot().Mean()
+}
+
+// MeanIn returns the mean of the values in the window in the given unit.
+// See StandardTimer.MeanIn.
+func (t *LockFreeTimer) MeanIn(unit time.Duration) float64 {
+	return inDurationUnit(t.Mean(), unit)
+}
+
+// Min returns the minimum value in the window.
+func (t *LockFreeTimer) Min() int64 {
+	return t.Snapshot().Min()
+}
+
+// Percentile returns an arbitrary percentile of the values in the window.
+func (t *LockFreeTimer) Percentile(p float64) float64 {
+	return t.Snapshot().Percentile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of the values in the
+// window.
+func (t *LockFreeTimer) Percentiles(ps []float64) []float64 {
+	return t.Snapshot().Percentiles(ps)
+}
+
+// Rate1 returns the one-minute moving average rate of events per second.
+func (t *LockFreeTimer) Rate1() float64 {
+	return t.meter.Rate1()
+}
+
+// Rate5 returns the five-minute moving average rate of events per second.
+func (t *LockFreeTimer) Rate5() float64 {
+	return t.meter.Rate5()
+}
+
+// Rate15 returns the fifteen-minute moving average rate of events per second.
+func (t *LockFreeTimer) Rate15() float64 {
+	return t.meter.Rate15()
+}
+
+// RateMean returns the meter's mean rate of events per second.
+func (t *LockFreeTimer) RateMean() float64 {
+	return t.meter.RateMean()
+}
+
+// Reset empties the window and zeroes the meter's count and moving
+// averages.  Updates concurrent with Reset may be recorded before or after
+// it, or be lost.
+func (t *LockFreeTimer) Reset() {
+	atomic.StoreInt64(&t.count, 0)
+	if m, ok := t.meter.(*StandardMeter); ok {
+		m.reset()
+	}
+}
+
+// Snapshot returns a read-only copy of the timer, whose values are those in
+// the window from oldest to newest.
+func (t *LockFreeTimer) Snapshot() Timer {
+	count := atomic.LoadInt64(&t.count)
+	window := int64(len(t.values))
+	n, start := count, int64(0)
+	if n > window {
+		n, start = window, count%window
+	}
+	values := make([]int64, n)
+	for i := range values {
+		values[i] = atomic.LoadInt64(&t.values[(start+int64(i))%window])
+	}
+	return &TimerSnapshot{
+		histogram: &HistogramSnapshot{sample: NewSampleSnapshot(count, values)},
+		meter:     t.meter.Snapshot().(*MeterSnapshot),
+	}
+}
+
+// StdDev returns the standard deviation of the values in the window.
+func (t *LockFreeTimer) StdDev() float64 {
+	return t.Snapshot().StdDev()
+}
+
+// Stop stops the meter.
+func (t *LockFreeTimer) Stop() {
+	t.meter.Stop()
+}
+
+// Sum returns the sum of the values in the window.
+func (t *LockFreeTimer) Sum() int64 {
+	return t.Snapshot().Sum()
+}
+
+// Record the duration of the execution of the given function.
+func (t *LockFreeTimer) Time(f func()) {
+	ts := time.Now()
+	f()
+	t.Update(time.Since(ts))
+}
+
+// TimeDefer starts timing immediately and returns a function which records
+// the elapsed duration when called.  See StandardTimer.TimeDefer.
+func (t *LockFreeTimer) TimeDefer() func() {
+	ts := time.Now()
+	return func() { t.UpdateSince(ts) }
+}
+
+// Start starts timing immediately and returns a stopwatch whose Stop method
+// records the elapsed duration.  See StandardTimer.Start.
+func (t *LockFreeTimer) Start() *TimerStopwatch {
+	return startStopwatch(t)
+}
+
+// Record the duration of an event.
+func (t *LockFreeTimer) Update(d time.Duration) {
+	i := atomic.AddInt64(&t.count, 1) - 1
+	atomic.StoreInt64(&t.values[i%int64(len(t.values))], int64(d))
+	t.meter.Mark(1)
+}
+
+// Record the duration of an event that started at a time and ends now.
+func (t *LockFreeTimer) UpdateSince(ts time.Time) {
+	t.Update(time.Since(ts))
+}
+
+// Record the duration of an event that started at a time and ends now, if
+// the time carries a monotonic clock reading.  See
+// StandardTimer.UpdateSinceMonotonic.
+func (t *LockFreeTimer) UpdateSinceMonotonic(ts time.Time) {
+	if hasMonotonic(ts) {
+		t.UpdateSince(ts)
+	}
+}
+
+// Variance returns the variance of the values in the window.
+func (t *LockFreeTimer) Variance() float64 {
+	return t.Snapshot().Variance()
+}
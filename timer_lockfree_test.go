@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func BenchmarkTimerParallel64(b *testing.B) {
+	tm := NewTimer()
+	b.SetParallelism(64)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			tm.Update(1)
+		}
+	})
+}
+
+func BenchmarkLockFreeTimerParallel64(b *testing.B) {
+	tm := NewLockFreeTimer(1028)
+	b.SetParallelism(64)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			tm.Update(1)
+		}
+	})
+}
+
+func TestLockFreeTimer(t *testing.T) {
+	tm := NewLockFreeTimer(100)
+	defer tm.Stop()
+	for i := 1; i <= 10; i++ {
+		tm.Update(10)
+		tm.Update(20)
+	}
+	if count := tm.Count(); 20 != count {
+		t.Errorf("tm.Count(): 20 != %v\n", count)
+	}
+	if min := tm.Min(); 10 != min {
+		t.Errorf("tm.Min(): 10 != %v\n", min)
+	}
+	if max := tm.Max(); 20 != max {
+		t.Errorf("tm.Max(): 20 != %v\n", max)
+	}
+	if mean := tm.Mean(); 15.0 != mean {
+		t.Errorf("tm.Mean(): 15.0 != %v\n", mean)
+	}
+	if p := tm.Percentile(0.99); 20 != p {
+		t.Errorf("tm.Percentile(0.99): 20 != %v\n", p)
+	}
+}
+
+func TestLockFreeTimerWindow(t *testing.T) {
+	tm := NewLockFreeTimer(3)
+	defer tm.Stop()
+	for i := 1; i <= 5; i++ {
+		tm.Update(time.Duration(10 * i))
+	}
+	if count := tm.Count(); 5 != count {
+		t.Errorf("tm.Count(): 5 != %v\n", count)
+	}
+	values := tm.Snapshot().(*TimerSnapshot).Values()
+	if 3 != len(values) || 30 != values[0] || 40 != values[1] || 50 != values[2] {
+		t.Fatal(values)
+	}
+	tm.Reset()
+	if count := tm.Count(); 0 != count {
+		t.Errorf("tm.Count(): 0 != %v\n", count)
+	}
+	if max := tm.Max(); 0 != max {
+		t.Errorf("tm.Max(): 0 != %v\n", max)
+	}
+}
+
+func TestLockFreeTimerConcurrent(t *testing.T) {
+	tm := NewLockFreeTimer(10)
+	defer tm.Stop()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				tm.Update(47)
+				tm.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+	if count := tm.Count(); 4000 != count {
+		t.Errorf("tm.Count(): 4000 != %v\n", count)
+	}
+	if max := tm.Max(); 47 != max {
+		t.Errorf("tm.Max(): 47 != %v\n", max)
+	}
+}
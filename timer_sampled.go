@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// NewSampledTimer constructs a new SampledTimer which records the duration
+// of one in every sample events, using an exponentially-decaying sample with
+// the same reservoir size and alpha as UNIX load averages.
+// Be sure to call Stop() once the timer is of no use to allow for garbage collection.
+func NewSampledTimer(sample int) Timer {
+	if UseNilMetrics {
+		return NilTimer{}
+	}
+	if sample < 1 {
+		sample = 1
+	}
+	return &SampledTimer{
+		histogram: NewHistogram(NewExpDecaySample(1028, 0.015)),
+		meter:     NewMeter(),
+		sample:    int64(sample),
+	}
+}
+
+// NewRegisteredSampledTimer constructs and registers a new SampledTimer.
+// Be sure to unregister the meter from the registry once it is of no use to
+// allow for garbage collection.
+func NewRegisteredSampledTimer(name string, r Registry, sample int) Timer {
+	c := NewSampledTimer(sample)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, c)
+	return c
+}
+
+// SampledTimer is a Timer which marks its meter for every event but only
+// records the duration of one in every sample events in its histogram, and
+// does so without the lock StandardTimer takes around both, so that hot
+// paths contend only on the histogram's sample and only 1/sample as often.
+//
+// Count and the rates are exact.  Percentiles, Mean, StdDev and Variance are
+// estimated from the recorded durations and so are noisier, and Min and Max
+// only reflect recorded durations.  Sum is estimated as Mean times Count.
+type SampledTimer struct {
+	calls     int64
+	sample    int64
+	histogram Histogram
+	meter     Meter
+}
+
+// Count returns the number of events recorded, including those whose
+// durations were not.
+func (t *SampledTimer) Count() int64 { return t.meter.Count() }
+
+// Max returns the maximum recorded duration.
+func (t *SampledTimer) Max() int64 { return t.histogram.Max() }
+
+// Mean returns the mean of the recorded durations.
+func (t *SampledTimer) Mean() float64 { return t.histogram.Mean() }
+
+// MeanIn returns the mean of the recorded durations in the given unit.  See
+// StandardTimer.MeanIn.
+func (t *SampledTimer) MeanIn(unit time.Duration) float64 {
+	return inDurationUnit(t.Mean(), unit)
+}
+
+// Min returns the minimum recorded duration.
+func (t *SampledTimer) Min() int64 { return t.histogram.Min() }
+
+// Percentile returns an arbitrary percentile of the recorded durations.
+func (t *SampledTimer) Percentile(p float64) float64 {
+	return t.histogram.Percentile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of the recorded
+// durations.
+func (t *SampledTimer) Percentiles(ps []float64) []float64 {
+	return t.histogram.Percentiles(ps)
+}
+
+// Rate1 returns the one-minute moving average rate of events per second.
+func (t *SampledTimer) Rate1() float64 { return t.meter.Rate1() }
+
+// Rate5 returns the five-minute moving average rate of events per second.
+func (t *SampledTimer) Rate5() float64 { return t.meter.Rate5() }
+
+// Rate15 returns the fifteen-minute moving average rate of events per second.
+func (t *SampledTimer) Rate15() float64 { return t.meter.Rate15() }
+
+// RateMean returns the meter's mean rate of events per second.
+func (t *SampledTimer) RateMean() float64 { return t.meter.RateMean() }
+
+// Reset clears the timer's histogram and zeroes the meter's count and moving
+// averages.  Unlike StandardTimer.Reset, updates concurrent with Reset may be
+// partially recorded.
+func (t *SampledTimer) Reset() {
+	t.histogram.Clear()
+	if m, ok := t.meter.(*StandardMeter); ok {
+		m.reset()
+	}
+}
+
+// Snapshot returns a read-only copy of the timer.
+func (t *SampledTimer) Snapshot() Timer {
+	return &sampledTimerSnapshot{&TimerSnapshot{
+		histogram: t.histogram.Snapshot().(*HistogramSnapshot),
+		meter:     t.meter.Snapshot().(*MeterSnapshot),
+	}}
+}
+
+// StdDev returns the standard deviation of the recorded durations.
+func (t *SampledTimer) StdDev() float64 { return t.histogram.StdDev() }
+
+// Stop stops the meter.
+func (t *SampledTimer) Stop() { t.meter.Stop() }
+
+// Sum returns the estimated sum of the durations of all events.
+func (t *SampledTimer) Sum() int64 {
+	return int64(t.histogram.Mean() * float64(t.meter.Count()))
+}
+
+// Record the duration of the execution of the given function.
+func (t *SampledTimer) Time(f func()) {
+	ts := time.Now()
+	f()
+	t.Update(time.Since(ts))
+}
+
+// TimeDefer starts timing immediately and returns a function which records
+// the elapsed duration when called.  See StandardTimer.TimeDefer.
+func (t *SampledTimer) TimeDefer() func() {
+	ts := time.Now()
+	return func() { t.UpdateSince(ts) }
+}
+
+// Start starts timing immediately and returns a stopwatch whose Stop method
+// records the elapsed duration.  See StandardTimer.Start.
+func (t *SampledTimer) Start() *TimerStopwatch {
+	return startStopwatch(t)
+}
+
+// Record the duration of an event.
+func (t *SampledTimer) Update(d time.Duration) {
+	t.meter.Mark(1)
+	if 0 == atomic.AddInt64(&t.calls, 1)%t.sample {
+		t.histogram.Update(int64(d))
+	}
+}
+
+// Record the duration of an event that started at a time and ends now.
+func (t *SampledTimer) UpdateSince(ts time.Time) {
+	t.Update(time.Since(ts))
+}
+
+// Record the duration of an event that started at a time and ends now, if
+// the time carries a monotonic clock reading.  See
+// StandardTimer.UpdateSinceMonotonic.
+func (t *SampledTimer) UpdateSinceMonotonic(ts time.Time) {
+	if hasMonotonic(ts) {
+		t.UpdateSince(ts)
+	}
+}
+
+// Variance returns the variance of the recorded durations.
+func (t *SampledTimer) Variance() float64 { return t.histogram.Variance() }
+
+// sampledTimerSnapshot is a TimerSnapshot of a SampledTimer, whose count is
+// the meter's and whose sum is estimated.
+type sampledTimerSnapshot struct {
+	*TimerSnapshot
+}
+
+func (t *sampledTimerSnapshot) Count() int64 { return t.meter.Count() }
+
+func (t *sampledTimerSnapshot) Snapshot() Timer { return t }
+
+func (t *sampledTimerSnapshot) Sum() int64 {
+	return int64(t.histogram.Mean() * float64(t.meter.Count()))
+}
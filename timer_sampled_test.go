@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func BenchmarkTimerParallel(b *testing.B) {
+	tm := NewTimer()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			tm.Update(1)
+		}
+	})
+}
+
+func BenchmarkSampledTimerParallel(b *testing.B) {
+	tm := NewSampledTimer(100)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			tm.Update(1)
+		}
+	})
+}
+
+func TestSampledTimer(t *testing.T) {
+	tm := NewSampledTimer(10)
+	defer tm.Stop()
+	for i := 1; i <= 100; i++ {
+		tm.Update(time.Duration(i))
+	}
+	if count := tm.Count(); 100 != count {
+		t.Errorf("tm.Count(): 100 != %v\n", count)
+	}
+	if min, max := tm.Min(), tm.Max(); 10 != min || 100 != max {
+		t.Errorf("tm.Min(), tm.Max(): 10, 100 != %v, %v\n", min, max)
+	}
+	if sum := tm.Sum(); 5500 != sum {
+		t.Errorf("tm.Sum(): 5500 != %v\n", sum)
+	}
+	snapshot := tm.Snapshot()
+	tm.Update(1)
+	if count := snapshot.Count(); 100 != count {
+		t.Errorf("snapshot.Count(): 100 != %v\n", count)
+	}
+	if sum := snapshot.Sum(); 5500 != sum {
+		t.Errorf("snapshot.Sum(): 5500 != %v\n", sum)
+	}
+}
+
+func TestSampledTimerReset(t *testing.T) {
+	tm := NewSampledTimer(1)
+	defer tm.Stop()
+	tm.Update(time.Second)
+	tm.Reset()
+	if count := tm.Count(); 0 != count {
+		t.Errorf("tm.Count(): 0 != %v\n", count)
+	}
+	if max := tm.Max(); 0 != max {
+		t.Errorf("tm.Max(): 0 != %v\n", max)
+	}
+}
+
+func TestSampledTimerTimeDefer(t *testing.T) {
+	tm := NewSampledTimer(1)
+	defer tm.Stop()
+	func() {
+		defer tm.TimeDefer()()
+	}()
+	if count := tm.Count(); 1 != count {
+		t.Errorf("tm.Count(): 1 != %v\n", count)
+	}
+}
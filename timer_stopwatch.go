@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// TimerStopwatch times a single event for the Timer whose Start method
+// returned it.  Stopwatches are pooled, so Stop must be called exactly once
+// and the stopwatch must not be used afterwards, since it may have been
+// handed out again by another call to Start.  Stop on a nil stopwatch, as
+// returned by NilTimer, is a no-op.
+type TimerStopwatch struct {
+	timer Timer
+	start time.Time
+}
+
+var stopwatchPool = sync.Pool{New: func() interface{} { return new(TimerStopwatch) }}
+
+func startStopwatch(t Timer) *TimerStopwatch {
+	sw := stopwatchPool.Get().(*TimerStopwatch)
+	sw.timer, sw.start = t, time.Now()
+	return sw
+}
+
+// Stop records the duration since the stopwatch was started and returns the
+// stopwatch to the pool.  It panics if the stopwatch was already stopped and
+// has not since been handed out again.
+func (sw *TimerStopwatch) Stop() {
+	if nil == sw {
+		return
+	}
+	t := sw.timer
+	if nil == t {
+		panic("Stop called on a stopped TimerStopwatch")
+	}
+	t.UpdateSince(sw.start)
+	sw.timer = nil
+	stopwatchPool.Put(sw)
+}
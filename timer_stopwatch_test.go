@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func BenchmarkTimerStart(b *testing.B) {
+	tm := NewTimer()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tm.Start().Stop()
+	}
+}
+
+func BenchmarkTimerTime(b *testing.B) {
+	tm := NewTimer()
+	n := 0
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tm.Time(func() { n++ })
+	}
+}
+
+func TestTimerStart(t *testing.T) {
+	tm := NewTimer()
+	sw := tm.Start()
+	time.Sleep(time.Millisecond)
+	sw.Stop()
+	if count := tm.Count(); 1 != count {
+		t.Errorf("tm.Count(): 1 != %v\n", count)
+	}
+	if min := tm.Min(); min < int64(time.Millisecond) {
+		t.Errorf("tm.Min(): %v < 1ms\n", min)
+	}
+}
+
+func TestTimerStartDefer(t *testing.T) {
+	tm := NewLockFreeTimer(10)
+	defer tm.Stop()
+	func() {
+		defer func() { recover() }()
+		sw := tm.Start()
+		defer sw.Stop()
+		panic("boom")
+	}()
+	if count := tm.Count(); 1 != count {
+		t.Errorf("tm.Count(): 1 != %v\n", count)
+	}
+}
+
+func TestTimerStopwatchStopTwice(t *testing.T) {
+	sw := NewTimer().Start()
+	sw.Stop()
+	defer func() {
+		if nil == recover() {
+			t.Fatal("second Stop did not panic")
+		}
+	}()
+	sw.Stop()
+}
+
+func TestNilTimerStart(t *testing.T) {
+	NilTimer{}.Start().Stop()
+}
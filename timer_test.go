@@ -3,6 +3,7 @@ package metrics
 import (
 	"fmt"
 	"math"
+	"sync"
 	"testing"
 	"time"
 )
@@ -23,6 +24,27 @@ func TestGetOrRegisterTimer(t *testing.T) {
 	}
 }
 
+func TestTimerMeanIn(t *testing.T) {
+	tm := NewTimer()
+	tm.Update(1500 * time.Millisecond)
+	for unit, mean := range map[time.Duration]float64{
+		0:                1.5e9,
+		time.Nanosecond:  1.5e9,
+		time.Millisecond: 1500,
+		time.Second:      1.5,
+	} {
+		if m := tm.Snapshot().MeanIn(unit); mean != m {
+			t.Errorf("tm.MeanIn(%v): %v != %v\n", unit, mean, m)
+		}
+	}
+	if m := tm.MeanIn(time.Millisecond); 1500 != m {
+		t.Errorf("tm.MeanIn(time.Millisecond): 1500 != %v\n", m)
+	}
+	if m := (NilTimer{}).MeanIn(time.Millisecond); 0 != m {
+		t.Errorf("NilTimer.MeanIn(time.Millisecond): 0 != %v\n", m)
+	}
+}
+
 func TestTimerExtremes(t *testing.T) {
 	tm := NewTimer()
 	tm.Update(math.MaxInt64)
@@ -32,6 +54,53 @@ func TestTimerExtremes(t *testing.T) {
 	}
 }
 
+func TestTimerReset(t *testing.T) {
+	tm := NewTimer()
+	defer tm.Stop()
+	tm.Update(47)
+	tm.Update(53)
+	tm.(*StandardTimer).meter.(*StandardMeter).tick()
+	if rate1 := tm.Rate1(); 0.0 == rate1 {
+		t.Fatal("tm.Rate1(): 0.0 before reset")
+	}
+	tm.Reset()
+	if count := tm.Count(); 0 != count {
+		t.Errorf("tm.Count(): 0 != %v\n", count)
+	}
+	if max := tm.Max(); 0 != max {
+		t.Errorf("tm.Max(): 0 != %v\n", max)
+	}
+	if rate1 := tm.Rate1(); 0.0 != rate1 {
+		t.Errorf("tm.Rate1(): 0.0 != %v\n", rate1)
+	}
+	if rateMean := tm.RateMean(); 0.0 != rateMean {
+		t.Errorf("tm.RateMean(): 0.0 != %v\n", rateMean)
+	}
+	tm.Update(1)
+	if count := tm.Count(); 1 != count {
+		t.Errorf("tm.Count(): 1 != %v\n", count)
+	}
+}
+
+// exercise race detector
+func TestTimerResetConcurrency(t *testing.T) {
+	tm := NewTimer()
+	defer tm.Stop()
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			tm.Update(1)
+			wg.Done()
+		}()
+		go func() {
+			tm.Reset()
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+}
+
 func TestTimerStop(t *testing.T) {
 	l := len(arbiter.meters)
 	tm := NewTimer()
@@ -52,6 +121,22 @@ func TestTimerFunc(t *testing.T) {
 	}
 }
 
+func TestTimerTimeDefer(t *testing.T) {
+	tm := NewTimer()
+	func() {
+		defer func() { recover() }()
+		defer tm.TimeDefer()()
+		time.Sleep(50e6)
+		panic("boom")
+	}()
+	if count := tm.Count(); 1 != count {
+		t.Errorf("tm.Count(): 1 != %v\n", count)
+	}
+	if max := tm.Max(); 45e6 > max || max > 55e6 {
+		t.Errorf("tm.Max(): 45e6 > %v || %v > 55e6\n", max, max)
+	}
+}
+
 func TestTimerZero(t *testing.T) {
 	tm := NewTimer()
 	if count := tm.Count(); 0 != count {
@@ -99,3 +184,27 @@ func ExampleGetOrRegisterTimer() {
 	t.Update(47)
 	fmt.Println(t.Max()) // Output: 47
 }
+
+func TestTimerUpdateSinceMonotonic(t *testing.T) {
+	tm := NewTimer()
+	defer tm.Stop()
+	tm.UpdateSinceMonotonic(time.Now().Add(-time.Millisecond))
+	if count := tm.Count(); 1 != count {
+		t.Errorf("tm.Count(): 1 != %v\n", count)
+	}
+	if min := tm.Min(); min < int64(time.Millisecond) || min > int64(time.Minute) {
+		t.Errorf("tm.Min(): %v\n", min)
+	}
+
+	// A start time read from the wall clock before it was set back an hour
+	// lies an hour in the future and has no monotonic reading.
+	jumped := time.Now().Round(0).Add(time.Hour)
+	tm.UpdateSinceMonotonic(jumped)
+	if count := tm.Count(); 1 != count {
+		t.Errorf("tm.Count(): 1 != %v\n", count)
+	}
+	tm.UpdateSince(jumped)
+	if min := tm.Min(); min > -int64(59*time.Minute) {
+		t.Errorf("tm.Min(): %v\n", min)
+	}
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+)
+
+// MetricNode is a node in the tree of a registry's metrics returned by
+// Tree, in which the metric named "a.b.c" is held by the node reached from
+// the root through the children named "a", "b" and "c".
+type MetricNode struct {
+	Name     string                 // Last dotted segment of Path, empty at the root
+	Path     string                 // Dotted name of the node, empty at the root
+	Metric   interface{}            // Metric registered under Path, if any
+	Children map[string]*MetricNode // Children by Name
+}
+
+// Tree returns the tree of the registered metrics, organized by the dotted
+// segments of their names.  The tree is built from a copy of the registry
+// taken by Each, so later registrations and unregistrations do not change
+// it, though the metrics themselves are not copied.
+func (r *StandardRegistry) Tree() *MetricNode {
+	return tree(r)
+}
+
+// Tree returns the tree of the metrics with the registry's prefix, whose
+// names include the prefix.  See StandardRegistry.Tree.
+func (r *PrefixedRegistry) Tree() *MetricNode {
+	return tree(r)
+}
+
+// Tree returns the tree of the metrics in the union.  See
+// StandardRegistry.Tree.
+func (r *mergedRegistry) Tree() *MetricNode {
+	return tree(r)
+}
+
+func tree(r Registry) *MetricNode {
+	root := &MetricNode{Children: make(map[string]*MetricNode)}
+	r.Each(func(name string, i interface{}) {
+		node := root
+		for _, segment := range strings.Split(name, ".") {
+			child, ok := node.Children[segment]
+			if !ok {
+				path := segment
+				if node != root {
+					path = node.Path + "." + segment
+				}
+				child = &MetricNode{
+					Name:     segment,
+					Path:     path,
+					Children: make(map[string]*MetricNode),
+				}
+				node.Children[segment] = child
+			}
+			node = child
+		}
+		node.Metric = i
+	})
+	return root
+}
+
+// Walk calls f for the node and then for each of its descendants, depth
+// first with siblings in lexicographic order of name.
+func (n *MetricNode) Walk(f func(*MetricNode)) {
+	f(n)
+	names := make([]string, 0, len(n.Children))
+	for name := range n.Children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		n.Children[name].Walk(f)
+	}
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTree(t *testing.T) {
+	r := NewRegistry()
+	c := NewRegisteredCounter("app.http.requests", r)
+	NewRegisteredTimer("app.http.latency", r)
+	NewRegisteredGauge("app.db", r)
+	NewRegisteredGauge("app.db.connections", r)
+	NewRegisteredMeter("runtime", r)
+	root := r.(*StandardRegistry).Tree()
+	if "" != root.Path || nil != root.Metric || 2 != len(root.Children) {
+		t.Fatal(root)
+	}
+	http := root.Children["app"].Children["http"]
+	if "app.http" != http.Path || nil != http.Metric || 2 != len(http.Children) {
+		t.Fatal(http)
+	}
+	if node := http.Children["requests"]; "requests" != node.Name || c != node.Metric || 0 != len(node.Children) {
+		t.Fatal(node)
+	}
+	db := root.Children["app"].Children["db"]
+	if _, ok := db.Metric.(Gauge); !ok || 1 != len(db.Children) {
+		t.Fatal(db)
+	}
+	if _, ok := root.Children["runtime"].Metric.(Meter); !ok {
+		t.Fatal(root.Children["runtime"])
+	}
+	r.Unregister("runtime")
+	if nil == root.Children["runtime"] {
+		t.Fatal("tree changed by Unregister")
+	}
+}
+
+func TestMetricNodeWalk(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("b.y", r)
+	NewRegisteredCounter("b.x", r)
+	NewRegisteredCounter("a", r)
+	var paths []string
+	r.(*StandardRegistry).Tree().Walk(func(n *MetricNode) {
+		paths = append(paths, n.Path)
+	})
+	if s := strings.Join(paths, ","); ",a,b,b.x,b.y" != s {
+		t.Fatal(s)
+	}
+}
+
+func TestPrefixedRegistryTree(t *testing.T) {
+	r := NewRegistry()
+	NewRegisteredCounter("other", r)
+	p := NewPrefixedChildRegistry(r, "app.").(*PrefixedRegistry)
+	NewRegisteredCounter("requests", p)
+	root := p.Tree()
+	if 1 != len(root.Children) || nil == root.Children["app"].Children["requests"] {
+		t.Fatal(root)
+	}
+	if root := MergedRegistry(r).(*mergedRegistry).Tree(); 2 != len(root.Children) {
+		t.Fatal(root)
+	}
+}
@@ -18,12 +18,7 @@ func Write(r Registry, d time.Duration, w io.Writer) {
 // WriteOnce sorts and writes metrics in the given registry to the given
 // io.Writer.
 func WriteOnce(r Registry, w io.Writer) {
-	var namedMetrics namedMetricSlice
-	r.Each(func(name string, i interface{}) {
-		namedMetrics = append(namedMetrics, namedMetric{name, i})
-	})
-
-	sort.Sort(namedMetrics)
+	namedMetrics := sortedMetrics(r)
 	for _, namedMetric := range namedMetrics {
 		switch metric := namedMetric.m.(type) {
 		case Counter:
@@ -61,6 +56,8 @@ func WriteOnce(r Registry, w io.Writer) {
 			fmt.Fprintf(w, "  5-min rate:  %12.2f\n", m.Rate5())
 			fmt.Fprintf(w, "  15-min rate: %12.2f\n", m.Rate15())
 			fmt.Fprintf(w, "  mean rate:   %12.2f\n", m.RateMean())
+			fmt.Fprintf(w, "  max rate:    %12.2f\n", m.RateMax())
+			fmt.Fprintf(w, "  min rate:    %12.2f\n", m.RateMin())
 		case Timer:
 			t := metric.Snapshot()
 			ps := t.Percentiles([]float64{0.5, 0.75, 0.95, 0.99, 0.999})
@@ -98,3 +95,32 @@ func (nms namedMetricSlice) Swap(i, j int) { nms[i], nms[j] = nms[j], nms[i] }
 func (nms namedMetricSlice) Less(i, j int) bool {
 	return nms[i].name < nms[j].name
 }
+
+// sortedMetrics returns the metrics in the given registry sorted by name.
+func sortedMetrics(r Registry) namedMetricSlice {
+	var namedMetrics namedMetricSlice
+	r.Each(func(name string, i interface{}) {
+		namedMetrics = append(namedMetrics, namedMetric{name, i})
+	})
+	sort.Sort(namedMetrics)
+	return namedMetrics
+}
+
+// eachMatching calls f for each metric in the given registry for which pred
+// returns true.
+func eachMatching(r Registry, pred func(string, interface{}) bool, f func(string, interface{})) {
+	r.Each(func(name string, i interface{}) {
+		if pred(name, i) {
+			f(name, i)
+		}
+	})
+}
+
+// eachSorted calls the given function for each metric in the given registry
+// in lexicographic order of name.  The metrics are copied and sorted first,
+// so no lock is held while f is called.
+func eachSorted(r Registry, f func(string, interface{})) {
+	for _, namedMetric := range sortedMetrics(r) {
+		f(namedMetric.name, namedMetric.m)
+	}
+}